@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	appsv1apply "k8s.io/client-go/applyconfigurations/apps/v1"
+	corev1apply "k8s.io/client-go/applyconfigurations/core/v1"
+	metav1apply "k8s.io/client-go/applyconfigurations/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"log"
+	"strings"
+)
+
+// fieldManager is the manager recorded by the API server for the fields set by server-side apply. It must stay
+// stable across runs, otherwise every run would conflict with the previous one.
+const fieldManager = "k8s-trial"
+
+func newDeploymentApplyConfiguration(namespace string, input deploymentInput) *appsv1apply.DeploymentApplyConfiguration {
+	labels := map[string]string{"app": input.appName}
+
+	container := corev1apply.Container().
+		WithName(input.containerName).
+		WithImage(input.image)
+	for _, p := range input.ports {
+		port := corev1apply.ContainerPort().
+			WithContainerPort(p.ContainerPort).
+			WithProtocol(p.Protocol)
+		if p.Name != "" {
+			port.WithName(p.Name)
+		}
+		container.WithPorts(port)
+	}
+
+	return appsv1apply.Deployment(input.deploymentName, namespace).
+		WithSpec(appsv1apply.DeploymentSpec().
+			WithReplicas(input.replicas).
+			WithSelector(metav1apply.LabelSelector().WithMatchLabels(labels)).
+			WithTemplate(corev1apply.PodTemplateSpec().
+				WithLabels(labels).
+				WithSpec(corev1apply.PodSpec().WithContainers(container))))
+}
+
+// applyK8sDeployment creates the deployment or updates it to match the input with server-side apply, so that running
+// it again converges instead of failing with AlreadyExists. Fields owned by another manager are only taken over when
+// force is set.
+func applyK8sDeployment(ctx context.Context, clientset kubernetes.Interface, input deploymentInput, force bool) error {
+	namespace := input.targetNamespace()
+	if errs := input.validate(); len(errs) > 0 {
+		return invalidDeployment(input.deploymentName, errs)
+	}
+
+	deployment := newDeploymentApplyConfiguration(namespace, input)
+	result, err := clientset.AppsV1().Deployments(namespace).Apply(ctx, deployment, metav1.ApplyOptions{
+		FieldManager: fieldManager,
+		Force:        force,
+	})
+	if err != nil {
+		return applyError(input.deploymentName, namespace, err)
+	}
+	log.Printf("Applied deployment %v (generation %d).", result.Name, result.Generation)
+	return nil
+}
+
+// applyError wraps the error of a failed apply, listing the conflicting fields if other managers own some of them.
+func applyError(deploymentName string, namespace string, err error) error {
+	if conflicts := applyConflicts(err); len(conflicts) > 0 {
+		return fmt.Errorf("cannot apply deployment %v in namespace %v, fields owned by other managers:\n%v\n%w",
+			deploymentName, namespace, strings.Join(conflicts, "\n"), err)
+	}
+	return fmt.Errorf("cannot apply deployment %v in namespace %v: %w", deploymentName, namespace, err)
+}
+
+// applyConflicts lists the fields of a failed server-side apply that are owned by other field managers.
+func applyConflicts(err error) []string {
+	var status apierrors.APIStatus
+	if !errors.As(err, &status) || status.Status().Details == nil {
+		return nil
+	}
+	var conflicts []string
+	for _, cause := range status.Status().Details.Causes {
+		if cause.Type == metav1.CauseTypeFieldManagerConflict {
+			conflicts = append(conflicts, fmt.Sprintf("  %v: %v", cause.Field, cause.Message))
+		}
+	}
+	return conflicts
+}
+
+func isApplyConflict(err error) bool {
+	return apierrors.IsConflict(err) && len(applyConflicts(err)) > 0
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"strings"
+	"testing"
+)
+
+func newSampleInput(replicas int32) deploymentInput {
+	return deploymentInput{
+		namespace:      "default",
+		appName:        "demo",
+		deploymentName: "demo",
+		containerName:  "demo",
+		image:          "nginx",
+		replicas:       replicas,
+		ports:          []v1.ContainerPort{{Name: "http", ContainerPort: 8080, Protocol: v1.ProtocolTCP}},
+	}
+}
+
+func TestApplyConverges(t *testing.T) {
+	clientset, server := newFakeClientset(t, newNamespace("default"))
+
+	for i := 0; i < 2; i++ {
+		if err := applyK8sDeployment(context.TODO(), clientset, newSampleInput(2), false); err != nil {
+			t.Fatalf("Cannot apply deployment, run %d: %v", i+1, err.Error())
+		}
+	}
+	d := server.get("deployments", "default", "demo")
+	if d == nil {
+		t.Fatalf("Deployment was not created by apply.")
+	}
+	if d.GetGeneration() != 1 {
+		t.Errorf("Generation after applying the same input twice, got: %d, want: %d.", d.GetGeneration(), 1)
+	}
+
+	if err := applyK8sDeployment(context.TODO(), clientset, newSampleInput(3), false); err != nil {
+		t.Fatalf("Cannot apply deployment with more replicas: %v", err.Error())
+	}
+	d = server.get("deployments", "default", "demo")
+	if replicas, _, _ := unstructured.NestedInt64(d.Object, "spec", "replicas"); replicas != 3 {
+		t.Errorf("Replicas after apply, got: %d, want: %d.", replicas, 3)
+	}
+
+	for _, r := range server.requestsOf("PATCH", "deployments") {
+		if got := r.query["fieldManager"]; len(got) != 1 || got[0] != fieldManager {
+			t.Errorf("Field manager of apply, got: %v, want: %v.", got, fieldManager)
+		}
+		if got := r.query["force"]; len(got) > 0 && got[0] == "true" {
+			t.Errorf("Apply was forced without asking for it.")
+		}
+	}
+}
+
+func TestApplyConflict(t *testing.T) {
+	clientset, server := newFakeClientset(t, newNamespace("default"))
+	server.failNext("PATCH", "deployments", apierrors.NewApplyConflict([]metav1.StatusCause{{
+		Type:    metav1.CauseTypeFieldManagerConflict,
+		Message: `conflict with "kubectl-edit" using apps/v1`,
+		Field:   ".spec.replicas",
+	}}, "Apply failed with 1 conflict"))
+
+	err := applyK8sDeployment(context.TODO(), clientset, newSampleInput(2), false)
+	if !isApplyConflict(err) || exitCodeForError(err) != exitConflict {
+		t.Fatalf("Error of a conflicting apply, got: %v, want an apply conflict.", err)
+	}
+	if !strings.Contains(err.Error(), ".spec.replicas") || !strings.Contains(err.Error(), "kubectl-edit") {
+		t.Errorf("Error of a conflicting apply does not name the field and its manager: %v", err)
+	}
+
+	if err := applyK8sDeployment(context.TODO(), clientset, newSampleInput(2), true); err != nil {
+		t.Fatalf("Cannot apply deployment with force: %v", err.Error())
+	}
+	requests := server.requestsOf("PATCH", "deployments")
+	if got := requests[len(requests)-1].query["force"]; len(got) != 1 || got[0] != "true" {
+		t.Errorf("Force of apply, got: %v, want: true.", got)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+)
+
+const defaultTimeout = time.Minute
+
+// runCancellable runs the API calls of a single command with a context that is cancelled by Ctrl-C, or once the
+// timeout elapses when it is positive. Ctrl-C only ends the command: the caller decides whether to go on.
+func runCancellable(timeout time.Duration, command func(ctx context.Context) error) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	err := command(ctx)
+	if err != nil && ctx.Err() != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("command timed out after %v: %w", timeout, ctx.Err())
+		}
+		return fmt.Errorf("command interrupted: %w", ctx.Err())
+	}
+	return err
+}
+
+// gather runs the tasks concurrently and waits for all of them, cancelling the others once one fails. It returns the
+// error of the first one that failed.
+func gather(ctx context.Context, tasks ...func(ctx context.Context) error) error {
+	return gatherLimited(ctx, len(tasks), tasks...)
+}
+
+// gatherLimited is gather running at most limit tasks at a time. The tasks not started yet are skipped once one fails
+// or the context ends, in which case the error of the context is returned.
+func gatherLimited(ctx context.Context, limit int, tasks ...func(ctx context.Context) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var firstErr error
+	var once sync.Once
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, limit)
+	started := 0
+	for _, task := range tasks {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		started++
+		wg.Add(1)
+		go func(task func(ctx context.Context) error) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			if err := task(ctx); err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}(task)
+	}
+	wg.Wait()
+	if firstErr == nil && started < len(tasks) {
+		return ctx.Err()
+	}
+	return firstErr
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRunCancellableTimeout(t *testing.T) {
+	clientset, server := newFakeClientset(t, newNamespace("default"))
+	server.hang()
+
+	start := time.Now()
+	err := runCancellable(100*time.Millisecond, func(ctx context.Context) error {
+		_, err := getPods(ctx, clientset, "default", metav1.ListOptions{})
+		return err
+	})
+	if exitCodeForError(err) != exitTimeout {
+		t.Errorf("Error of a hung API server, got: %v, want a timeout.", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Time to give up on a hung API server, got: %v, want: about %v.", elapsed, 100*time.Millisecond)
+	}
+}
+
+func TestRunCancellableInterrupt(t *testing.T) {
+	clientset, server := newFakeClientset(t, newNamespace("default"))
+	server.hang()
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		_ = syscall.Kill(os.Getpid(), syscall.SIGINT)
+	}()
+	err := runCancellable(0, func(ctx context.Context) error {
+		_, err := getPods(ctx, clientset, "default", metav1.ListOptions{})
+		return err
+	})
+	if exitCodeForError(err) != exitInterrupted {
+		t.Errorf("Error of an interrupted command, got: %v, want an interruption.", err)
+	}
+
+	err = runCancellable(0, func(ctx context.Context) error {
+		return ctx.Err()
+	})
+	if err != nil {
+		t.Errorf("Error of the command after an interrupted one, got: %v, want: nil.", err)
+	}
+}
+
+func TestGatherLimited(t *testing.T) {
+	var mu sync.Mutex
+	running, most := 0, 0
+	var tasks []func(ctx context.Context) error
+	for i := 0; i < 10; i++ {
+		tasks = append(tasks, func(ctx context.Context) error {
+			mu.Lock()
+			running++
+			if running > most {
+				most = running
+			}
+			mu.Unlock()
+			time.Sleep(10 * time.Millisecond)
+			mu.Lock()
+			running--
+			mu.Unlock()
+			return nil
+		})
+	}
+	if err := gatherLimited(context.TODO(), 3, tasks...); err != nil {
+		t.Fatalf("Cannot gather the tasks: %v", err)
+	}
+	if most != 3 {
+		t.Errorf("Tasks running at the same time, got: %d, want: 3.", most)
+	}
+
+	failure := errors.New("failed")
+	started := 0
+	err := gatherLimited(context.TODO(), 1,
+		func(ctx context.Context) error {
+			started++
+			return failure
+		},
+		func(ctx context.Context) error {
+			started++
+			return nil
+		})
+	if !errors.Is(err, failure) || started != 1 {
+		t.Errorf("Gathering after a failure, got: %v with %d started, want: %v with 1 started.", err, started, failure)
+	}
+}
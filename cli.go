@@ -0,0 +1,800 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/spf13/pflag"
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/kubernetes"
+	"os"
+	"strings"
+)
+
+// Exit codes returned by the non-interactive subcommands.
+const (
+	exitOK      = 0
+	exitFailure = 1
+	exitUsage   = 2
+)
+
+const rootUsage = `Usage: k8s-trial [command] [flags]
+
+Run without a command to start the interactive prompt. The connection flags below are accepted by every command.
+
+Commands:
+  view           List pods, or objects of another kind, of a namespace (or of all namespaces)
+  api-resources  List the kinds of objects that view can list
+  create         Create a deployment, or the objects of a manifest with -f
+  apply          Create or update a deployment, or the objects of a manifest with -f, with server-side apply
+  update         Change the image, env or resources of a deployment and follow the rollout
+  describe       Show a deployment with its ReplicaSets, pods and events
+  diagnose       Tell why pods are not running, and how to fix it
+  tree           Show the objects a deployment, job, service and the like own or select
+  nodes          Show the nodes with what their pods request, and where deployments run
+  logs           Follow the logs of the pods of a deployment
+  history        List the revisions of a deployment
+  rollback       Roll a deployment back to a previous revision
+  scale          Change the number of replicas of a deployment
+  pause          Pause the rollout of a deployment
+  resume         Resume the paused rollout of a deployment
+  restart        Restart the pods of a deployment with a rolling update
+  delete         Delete a deployment
+
+Run "k8s-trial <command> --help" for the flags of a command.
+
+Flags:
+`
+
+// runSubcommand runs a single non-interactive command and returns the exit code of the process.
+func runSubcommand(args []string) int {
+	command, rest := args[0], args[1:]
+	if command == "view" {
+		return runView(rest)
+	} else if command == "api-resources" {
+		return runAPIResources(rest)
+	} else if command == "create" {
+		return runCreate(rest)
+	} else if command == "apply" {
+		return runApply(rest)
+	} else if command == "update" {
+		return runUpdate(rest)
+	} else if command == "describe" {
+		return runDescribe(rest)
+	} else if command == "diagnose" {
+		return runDiagnose(rest)
+	} else if command == "tree" {
+		return runTree(rest)
+	} else if command == "nodes" {
+		return runNodes(rest)
+	} else if command == "logs" {
+		return runLogs(rest)
+	} else if command == "history" {
+		return runHistory(rest)
+	} else if command == "rollback" {
+		return runRollback(rest)
+	} else if command == "scale" {
+		return runScale(rest)
+	} else if command == "pause" || command == "resume" || command == "restart" {
+		return runRolloutCommand(command, rest)
+	} else if command == "delete" {
+		return runDelete(rest)
+	} else if command == "help" {
+		flags, _ := newInteractiveFlagSet()
+		flags.Usage()
+		return exitOK
+	}
+	fmt.Fprintf(os.Stderr, "Unknown command %q.\n\n", command)
+	flags, _ := newInteractiveFlagSet()
+	flags.Usage()
+	return exitUsage
+}
+
+func newInteractiveFlagSet() (*pflag.FlagSet, *connectionOptions) {
+	var options connectionOptions
+	flags := pflag.NewFlagSet("k8s-trial", pflag.ContinueOnError)
+	flags.SetOutput(os.Stderr)
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, rootUsage)
+		flags.PrintDefaults()
+	}
+	options.addFlags(flags)
+	return flags, &options
+}
+
+// parseInteractiveFlags parses the flags given without a command, returning false together with the exit code when
+// the interactive prompt should not start.
+func parseInteractiveFlags(args []string) (connectionOptions, bool, int) {
+	flags, options := newInteractiveFlagSet()
+	ok, code := parseFlags(flags, args)
+	return *options, ok, code
+}
+
+func newFlagSet(command string) (*pflag.FlagSet, *connectionOptions) {
+	var options connectionOptions
+	flags := pflag.NewFlagSet(command, pflag.ContinueOnError)
+	flags.SetOutput(os.Stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: k8s-trial %s [flags]\n\nFlags:\n", command)
+		flags.PrintDefaults()
+	}
+	options.addFlags(flags)
+	return flags, &options
+}
+
+// connect connects with the connection flags of a command, returning false together with the exit code when the
+// connection cannot be set up.
+func connect(options *connectionOptions) (kubernetes.Interface, bool, int) {
+	clientset, err := connectToK8s(*options)
+	if err != nil {
+		reportError(err)
+		return nil, false, exitCodeForError(err)
+	}
+	return clientset, true, exitOK
+}
+
+// parseFlags parses the arguments of a command, returning false together with the exit code when the command
+// should not proceed.
+func parseFlags(flags *pflag.FlagSet, args []string) (bool, int) {
+	return parseFlagsAndArgs(flags, args, 0)
+}
+
+// parseFlagsAndArgs is parseFlags for commands taking up to maxArgs arguments besides the flags.
+func parseFlagsAndArgs(flags *pflag.FlagSet, args []string, maxArgs int) (bool, int) {
+	if err := flags.Parse(args); err != nil {
+		if err == pflag.ErrHelp {
+			return false, exitOK
+		}
+		return false, exitUsage
+	}
+	if flags.NArg() > maxArgs {
+		fmt.Fprintf(os.Stderr, "Unexpected arguments: %v\n", flags.Args())
+		flags.Usage()
+		return false, exitUsage
+	}
+	return true, exitOK
+}
+
+// requireFlags checks that each of the given flags has a non-empty value, printing the usage otherwise.
+func requireFlags(flags *pflag.FlagSet, names ...string) bool {
+	for _, name := range names {
+		if flags.Lookup(name).Value.String() == "" {
+			fmt.Fprintf(os.Stderr, "Flag --%s is required.\n", name)
+			flags.Usage()
+			return false
+		}
+	}
+	return true
+}
+
+// rejectFlags fails when any of the named flags is set together with the flag that replaces them.
+func rejectFlags(flags *pflag.FlagSet, replacement string, names ...string) bool {
+	for _, name := range names {
+		if flags.Changed(name) {
+			fmt.Fprintf(os.Stderr, "Flag --%s cannot be combined with --%s.\n", name, replacement)
+			flags.Usage()
+			return false
+		}
+	}
+	return true
+}
+
+func addDryRunFlag(flags *pflag.FlagSet) *bool {
+	return flags.Bool("dry-run", false, "only show what would change, computed by a server-side dry run")
+}
+
+func addWaitFlag(flags *pflag.FlagSet) *bool {
+	return flags.Bool("wait", false, "wait until the rollout completes, within --timeout")
+}
+
+// printPreview prints the preview of a dry run, unless computing it failed.
+func printPreview(changes string, err error) error {
+	if err == nil {
+		fmt.Print(changes)
+	}
+	return err
+}
+
+func runView(args []string) int {
+	flags, options := newFlagSet("view")
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, "Usage: k8s-trial view [kind] [flags]\n\n"+
+			"The kind is pods unless given, e.g. deployments, svc or nodes.\n\nFlags:\n")
+		flags.PrintDefaults()
+	}
+	namespace := flags.StringP("namespace", "n", "", "namespace to list objects of (empty for all)")
+	labelSelector := flags.StringP("selector", "l", "", "label selector, e.g. app=demo,tier!=db")
+	fieldSelector := flags.String("field-selector", "", "field selector, e.g. status.phase=Running,spec.nodeName=node-1")
+	output := flags.StringP("output", "o", "", "output format: "+strings.Join(outputFormats, ", "))
+	chunkSize := flags.Int64("chunk-size", defaultPageSize, "number of objects to list per page, printed as they arrive")
+	watchChanges := flags.BoolP("watch", "w", false, "after listing pods, keep watching them for changes until Ctrl-C")
+	if ok, code := parseFlagsAndArgs(flags, args, 1); !ok {
+		return code
+	}
+	if _, err := newPrinter(os.Stdout, *output, podColumns); err != nil {
+		fmt.Fprintf(os.Stderr, "Flag --output: %v\n", err)
+		return exitUsage
+	}
+	if *watchChanges && *output != "" && *output != "wide" {
+		fmt.Fprintln(os.Stderr, "Flag --watch only works with the table output, or -o wide.")
+		return exitUsage
+	}
+	if *chunkSize <= 0 {
+		fmt.Fprintln(os.Stderr, "Flag --chunk-size must be greater than 0.")
+		return exitUsage
+	}
+
+	clientset, ok, code := connect(options)
+	if !ok {
+		return code
+	}
+	resource := podsResource
+	if flags.NArg() == 1 {
+		err := runCancellable(options.timeout, func(ctx context.Context) error {
+			var err error
+			resource, err = resolveResource(clientset.Discovery(), flags.Arg(0))
+			return err
+		})
+		if err != nil {
+			reportError(err)
+			return exitCodeForError(err)
+		}
+	}
+	if *watchChanges && !resource.isPods() {
+		fmt.Fprintf(os.Stderr, "Flag --watch only works for pods, not %v.\n", resource)
+		return exitUsage
+	}
+	listOptions, err := resource.listOptions(*labelSelector, *fieldSelector)
+	if err != nil {
+		reportError(err)
+		return exitCodeForError(err)
+	}
+	listOptions.Limit = *chunkSize
+
+	timeout := options.timeout
+	if *watchChanges && !flags.Changed("timeout") {
+		// A watch is meant to stay open, so it only ends with Ctrl-C unless a timeout is asked for.
+		timeout = 0
+	}
+	err = runCancellable(timeout, func(ctx context.Context) error {
+		if *watchChanges {
+			return watchPods(ctx, clientset, *namespace, listOptions, os.Stdout, *output)
+		}
+		return viewResources(ctx, clientset, resource, *namespace, listOptions, os.Stdout, *output)
+	})
+	if err != nil {
+		reportError(err)
+	}
+	return exitCodeForError(err)
+}
+
+func runAPIResources(args []string) int {
+	flags, options := newFlagSet("api-resources")
+	if ok, code := parseFlags(flags, args); !ok {
+		return code
+	}
+
+	clientset, ok, code := connect(options)
+	if !ok {
+		return code
+	}
+	err := runCancellable(options.timeout, func(ctx context.Context) error {
+		types, err := getResourceTypes(clientset.Discovery())
+		if err != nil {
+			return err
+		}
+		return printResourceTypes(os.Stdout, types)
+	})
+	if err != nil {
+		reportError(err)
+	}
+	return exitCodeForError(err)
+}
+
+// deploymentFlags are the flags matching the prompts of the create task.
+type deploymentFlags struct {
+	input       deploymentInput
+	portEntries []string
+}
+
+// deploymentFlagNames are the flags describing the deployment, which a manifest given with --filename replaces.
+var deploymentFlagNames = []string{"app", "name", "container", "image", "replicas", "ports"}
+
+func (f *deploymentFlags) addFlags(flags *pflag.FlagSet) {
+	flags.StringVarP(&f.input.namespace, "namespace", "n", "default", "namespace of the deployment")
+	flags.StringVar(&f.input.appName, "app", "", "value of the app label (defaults to the deployment name)")
+	flags.StringVar(&f.input.deploymentName, "name", "", "deployment name")
+	flags.StringVar(&f.input.containerName, "container", "", "container name (defaults to the deployment name)")
+	flags.StringVar(&f.input.image, "image", "", "container image")
+	flags.Int32Var(&f.input.replicas, "replicas", defaultReplicas, "number of replicas")
+	flags.StringSliceVar(&f.portEntries, "ports", []string{defaultPorts},
+		"container ports as name:port/protocol, repeated or comma separated")
+}
+
+// complete checks the required flags, fills in the defaults and parses the ports, returning false together with the
+// exit code when the command should not proceed.
+func (f *deploymentFlags) complete(flags *pflag.FlagSet) (bool, int) {
+	if !requireFlags(flags, "name", "image") {
+		return false, exitUsage
+	}
+	if f.input.appName == "" {
+		f.input.appName = f.input.deploymentName
+	}
+	if f.input.containerName == "" {
+		f.input.containerName = f.input.deploymentName
+	}
+	ports, errs := parsePorts(f.portEntries)
+	if len(errs) > 0 {
+		err := invalidDeployment(f.input.deploymentName, errs)
+		reportError(err)
+		return false, exitCodeForError(err)
+	}
+	f.input.ports = ports
+	return true, exitOK
+}
+
+func runCreate(args []string) int {
+	flags, options := newFlagSet("create")
+	var deployment deploymentFlags
+	deployment.addFlags(flags)
+	filename := flags.StringP("filename", "f", "",
+		"YAML or JSON manifest to create the objects of, '-' for stdin (replaces the flags above)")
+	dryRun := addDryRunFlag(flags)
+	wait := addWaitFlag(flags)
+	if ok, code := parseFlags(flags, args); !ok {
+		return code
+	}
+	if *filename != "" {
+		if !rejectFlags(flags, "filename", append(deploymentFlagNames, "dry-run", "wait")...) {
+			return exitUsage
+		}
+		return runFromManifest(options, func(ctx context.Context, clientset kubernetes.Interface) error {
+			return createFromManifest(ctx, clientset, *filename, deployment.input.namespace)
+		})
+	}
+	if ok, code := deployment.complete(flags); !ok {
+		return code
+	}
+
+	clientset, ok, code := connect(options)
+	if !ok {
+		return code
+	}
+	err := runCancellable(options.timeout, func(ctx context.Context) error {
+		if *dryRun {
+			return printPreview(previewCreate(ctx, clientset, deployment.input))
+		}
+		if err := launchK8sDeployment(ctx, clientset, deployment.input); err != nil || !*wait {
+			return err
+		}
+		return waitForRollout(ctx, clientset, deployment.input.targetNamespace(), deployment.input.deploymentName)
+	})
+	if err != nil {
+		reportError(err)
+	}
+	return exitCodeForError(err)
+}
+
+func runApply(args []string) int {
+	flags, options := newFlagSet("apply")
+	var deployment deploymentFlags
+	deployment.addFlags(flags)
+	filename := flags.StringP("filename", "f", "",
+		"YAML or JSON manifest to apply the objects of, '-' for stdin (replaces the flags above)")
+	force := flags.Bool("force", false, "take over fields owned by other field managers")
+	dryRun := addDryRunFlag(flags)
+	wait := addWaitFlag(flags)
+	if ok, code := parseFlags(flags, args); !ok {
+		return code
+	}
+	if *filename != "" {
+		if !rejectFlags(flags, "filename", append(deploymentFlagNames, "dry-run", "wait")...) {
+			return exitUsage
+		}
+		return runFromManifest(options, func(ctx context.Context, clientset kubernetes.Interface) error {
+			return applyFromManifest(ctx, clientset, *filename, deployment.input.namespace, *force)
+		})
+	}
+	if ok, code := deployment.complete(flags); !ok {
+		return code
+	}
+
+	clientset, ok, code := connect(options)
+	if !ok {
+		return code
+	}
+	err := runCancellable(options.timeout, func(ctx context.Context) error {
+		if *dryRun {
+			return printPreview(previewApply(ctx, clientset, deployment.input, *force))
+		}
+		if err := applyK8sDeployment(ctx, clientset, deployment.input, *force); err != nil || !*wait {
+			return err
+		}
+		return waitForRollout(ctx, clientset, deployment.input.targetNamespace(), deployment.input.deploymentName)
+	})
+	if err != nil {
+		reportError(err)
+	}
+	return exitCodeForError(err)
+}
+
+func runUpdate(args []string) int {
+	flags, options := newFlagSet("update")
+	var input updateInput
+	var envEntries, requestEntries, limitEntries []string
+	flags.StringVarP(&input.namespace, "namespace", "n", "default", "namespace of the deployment")
+	flags.StringVar(&input.deploymentName, "name", "", "deployment name")
+	flags.StringVar(&input.containerName, "container", "", "container to update (may be left out with a single container)")
+	flags.StringVar(&input.image, "image", "", "new container image")
+	flags.StringSliceVar(&envEntries, "env", nil, "env variables to set as NAME=value, repeated or comma separated")
+	flags.StringSliceVar(&requestEntries, "requests", nil, "resource requests as name=quantity, e.g. cpu=250m,memory=128Mi")
+	flags.StringSliceVar(&limitEntries, "limits", nil, "resource limits as name=quantity, e.g. cpu=500m,memory=256Mi")
+	dryRun := addDryRunFlag(flags)
+	wait := flags.Bool("wait", true, "follow the rollout until it completes, within --timeout")
+	if ok, code := parseFlags(flags, args); !ok {
+		return code
+	}
+	if !requireFlags(flags, "name") {
+		return exitUsage
+	}
+	env, errs := parseEnv(envEntries)
+	requests, requestErrs := parseResources(requestEntries, requestsPath)
+	limits, limitErrs := parseResources(limitEntries, limitsPath)
+	if errs = append(append(errs, requestErrs...), limitErrs...); len(errs) > 0 {
+		err := invalidDeployment(input.deploymentName, errs)
+		reportError(err)
+		return exitCodeForError(err)
+	}
+	input.env, input.requests, input.limits = env, requests, limits
+
+	clientset, ok, code := connect(options)
+	if !ok {
+		return code
+	}
+	err := runCancellable(options.timeout, func(ctx context.Context) error {
+		if *dryRun {
+			return printPreview(previewUpdate(ctx, clientset, input))
+		}
+		if err := updateK8sDeployment(ctx, clientset, input); err != nil || !*wait {
+			return err
+		}
+		return waitForRollout(ctx, clientset, input.targetNamespace(), input.deploymentName)
+	})
+	if err != nil {
+		reportError(err)
+	}
+	return exitCodeForError(err)
+}
+
+func runDescribe(args []string) int {
+	flags, options := newFlagSet("describe")
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, "Usage: k8s-trial describe deployment <name> [flags]\n\nFlags:\n")
+		flags.PrintDefaults()
+	}
+	namespace := flags.StringP("namespace", "n", "default", "namespace of the deployment")
+	if ok, code := parseFlagsAndArgs(flags, args, 2); !ok {
+		return code
+	}
+	if flags.NArg() != 2 {
+		fmt.Fprintln(os.Stderr, "The kind and the name of the object to describe are required.")
+		flags.Usage()
+		return exitUsage
+	}
+
+	clientset, ok, code := connect(options)
+	if !ok {
+		return code
+	}
+	err := runCancellable(options.timeout, func(ctx context.Context) error {
+		resource, err := resolveResource(clientset.Discovery(), flags.Arg(0))
+		if err != nil {
+			return err
+		} else if resource.GroupResource() != appsv1.Resource("deployments") {
+			return apierrors.NewBadRequest(fmt.Sprintf("only deployments can be described, not %v", resource))
+		}
+		return describeDeployment(ctx, clientset, *namespace, flags.Arg(1), os.Stdout)
+	})
+	if err != nil {
+		reportError(err)
+	}
+	return exitCodeForError(err)
+}
+
+func runDiagnose(args []string) int {
+	flags, options := newFlagSet("diagnose")
+	namespace := flags.StringP("namespace", "n", "", "namespace of the pods to diagnose (empty for all)")
+	labelSelector := flags.StringP("selector", "l", "", "label selector, e.g. app=demo,tier!=db")
+	fieldSelector := flags.String("field-selector", "", "field selector, e.g. spec.nodeName=node-1")
+	if ok, code := parseFlags(flags, args); !ok {
+		return code
+	}
+	listOptions, err := podListOptions(*labelSelector, *fieldSelector)
+	if err != nil {
+		reportError(err)
+		return exitCodeForError(err)
+	}
+
+	clientset, ok, code := connect(options)
+	if !ok {
+		return code
+	}
+	err = runCancellable(options.timeout, func(ctx context.Context) error {
+		return diagnosePods(ctx, clientset, *namespace, listOptions, os.Stdout)
+	})
+	if err != nil {
+		reportError(err)
+	}
+	return exitCodeForError(err)
+}
+
+func runTree(args []string) int {
+	flags, options := newFlagSet("tree")
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, "Usage: k8s-trial tree <kind> <name> [flags]\n\n"+
+			"The kind is a deployment, statefulset, daemonset, replicaset, job, cronjob or service.\n\nFlags:\n")
+		flags.PrintDefaults()
+	}
+	namespace := flags.StringP("namespace", "n", "default", "namespace of the object")
+	if ok, code := parseFlagsAndArgs(flags, args, 2); !ok {
+		return code
+	}
+	if flags.NArg() != 2 {
+		fmt.Fprintln(os.Stderr, "The kind and the name of the object to start from are required.")
+		flags.Usage()
+		return exitUsage
+	}
+
+	clientset, ok, code := connect(options)
+	if !ok {
+		return code
+	}
+	err := runCancellable(options.timeout, func(ctx context.Context) error {
+		resource, err := resolveResource(clientset.Discovery(), flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		return printTree(ctx, clientset, resource, *namespace, flags.Arg(1), os.Stdout)
+	})
+	if err != nil {
+		reportError(err)
+	}
+	return exitCodeForError(err)
+}
+
+func runNodes(args []string) int {
+	flags, options := newFlagSet("nodes")
+	placement := flags.Bool("placement", false, "also show on which nodes the pods of each deployment run")
+	namespace := flags.StringP("namespace", "n", "", "namespace of the deployments to place (empty for all)")
+	if ok, code := parseFlags(flags, args); !ok {
+		return code
+	}
+
+	clientset, ok, code := connect(options)
+	if !ok {
+		return code
+	}
+	err := runCancellable(options.timeout, func(ctx context.Context) error {
+		return printNodes(ctx, clientset, *placement, *namespace, os.Stdout)
+	})
+	if err != nil {
+		reportError(err)
+	}
+	return exitCodeForError(err)
+}
+
+func runLogs(args []string) int {
+	flags, options := newFlagSet("logs")
+	namespace := flags.StringP("namespace", "n", "default", "namespace of the deployment")
+	deploymentName := flags.String("name", "", "deployment name")
+	since := flags.Duration("since", 0, "only lines newer than this, e.g. 10m (0 for the whole log)")
+	tail := flags.Int64("tail", -1, "lines to show from the end of each log (-1 for all)")
+	previous := flags.Bool("previous", false, "show the logs of the containers that restarted, instead of following")
+	filter := flags.String("filter", "", "only lines matching this regular expression")
+	if ok, code := parseFlags(flags, args); !ok {
+		return code
+	}
+	if !requireFlags(flags, "name") {
+		return exitUsage
+	}
+	logOptions, err := newLogOptions(*since, *tail, *filter, *previous)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Flags: %v\n", err)
+		return exitUsage
+	}
+
+	clientset, ok, code := connect(options)
+	if !ok {
+		return code
+	}
+	timeout := options.timeout
+	if !*previous && !flags.Changed("timeout") {
+		// Logs are followed until Ctrl-C unless a timeout is asked for.
+		timeout = 0
+	}
+	err = runCancellable(timeout, func(ctx context.Context) error {
+		return streamDeploymentLogs(ctx, clientset, *namespace, *deploymentName, logOptions, os.Stdout)
+	})
+	if err != nil {
+		reportError(err)
+	}
+	return exitCodeForError(err)
+}
+
+func runHistory(args []string) int {
+	flags, options := newFlagSet("history")
+	namespace := flags.StringP("namespace", "n", "default", "namespace of the deployment")
+	deploymentName := flags.String("name", "", "deployment name")
+	if ok, code := parseFlags(flags, args); !ok {
+		return code
+	}
+	if !requireFlags(flags, "name") {
+		return exitUsage
+	}
+
+	clientset, ok, code := connect(options)
+	if !ok {
+		return code
+	}
+	err := runCancellable(options.timeout, func(ctx context.Context) error {
+		return printHistory(ctx, clientset, *namespace, *deploymentName)
+	})
+	if err != nil {
+		reportError(err)
+	}
+	return exitCodeForError(err)
+}
+
+func runRollback(args []string) int {
+	flags, options := newFlagSet("rollback")
+	namespace := flags.StringP("namespace", "n", "default", "namespace of the deployment")
+	deploymentName := flags.String("name", "", "deployment name")
+	revision := flags.Int64("to-revision", 0, "revision to roll back to (0 for the previous one)")
+	dryRun := addDryRunFlag(flags)
+	wait := addWaitFlag(flags)
+	if ok, code := parseFlags(flags, args); !ok {
+		return code
+	}
+	if !requireFlags(flags, "name") {
+		return exitUsage
+	}
+	if *revision < 0 {
+		err := invalidDeployment(*deploymentName, field.ErrorList{
+			field.Invalid(revisionPath, *revision, "must be a positive integer"),
+		})
+		reportError(err)
+		return exitCodeForError(err)
+	}
+
+	clientset, ok, code := connect(options)
+	if !ok {
+		return code
+	}
+	err := runCancellable(options.timeout, func(ctx context.Context) error {
+		if *dryRun {
+			return printPreview(previewRollback(ctx, clientset, *namespace, *deploymentName, *revision))
+		}
+		if err := rollbackK8sDeployment(ctx, clientset, *namespace, *deploymentName, *revision); err != nil || !*wait {
+			return err
+		}
+		return waitForRollout(ctx, clientset, *namespace, *deploymentName)
+	})
+	if err != nil {
+		reportError(err)
+	}
+	return exitCodeForError(err)
+}
+
+func addSettleFlag(flags *pflag.FlagSet) *bool {
+	return flags.Bool("wait", false, "wait until the deployment settles, within --timeout")
+}
+
+func runScale(args []string) int {
+	flags, options := newFlagSet("scale")
+	namespace := flags.StringP("namespace", "n", "default", "namespace of the deployment")
+	deploymentName := flags.String("name", "", "deployment name")
+	replicas := flags.Int32("replicas", 0, "number of replicas")
+	wait := addSettleFlag(flags)
+	if ok, code := parseFlags(flags, args); !ok {
+		return code
+	}
+	if !requireFlags(flags, "name") {
+		return exitUsage
+	}
+	if !flags.Changed("replicas") {
+		fmt.Fprintln(os.Stderr, "Flag --replicas is required.")
+		flags.Usage()
+		return exitUsage
+	}
+
+	clientset, ok, code := connect(options)
+	if !ok {
+		return code
+	}
+	err := runCancellable(options.timeout, func(ctx context.Context) error {
+		return scaleK8sDeployment(ctx, clientset, *namespace, *deploymentName, *replicas, *wait)
+	})
+	if err != nil {
+		reportError(err)
+	}
+	return exitCodeForError(err)
+}
+
+// runRolloutCommand runs pause, resume or restart, which take the same flags.
+func runRolloutCommand(command string, args []string) int {
+	flags, options := newFlagSet(command)
+	namespace := flags.StringP("namespace", "n", "default", "namespace of the deployment")
+	deploymentName := flags.String("name", "", "deployment name")
+	wait := addSettleFlag(flags)
+	if ok, code := parseFlags(flags, args); !ok {
+		return code
+	}
+	if !requireFlags(flags, "name") {
+		return exitUsage
+	}
+
+	clientset, ok, code := connect(options)
+	if !ok {
+		return code
+	}
+	err := runCancellable(options.timeout, func(ctx context.Context) error {
+		if command == "restart" {
+			return restartK8sDeployment(ctx, clientset, *namespace, *deploymentName, *wait)
+		}
+		return pauseK8sDeployment(ctx, clientset, *namespace, *deploymentName, command == "pause", *wait)
+	})
+	if err != nil {
+		reportError(err)
+	}
+	return exitCodeForError(err)
+}
+
+// runFromManifest connects and creates or applies the objects of a manifest with handle.
+func runFromManifest(
+	options *connectionOptions,
+	handle func(ctx context.Context, clientset kubernetes.Interface) error) int {
+	clientset, ok, code := connect(options)
+	if !ok {
+		return code
+	}
+	err := runCancellable(options.timeout, func(ctx context.Context) error {
+		return handle(ctx, clientset)
+	})
+	if err != nil {
+		reportError(err)
+	}
+	return exitCodeForError(err)
+}
+
+func runDelete(args []string) int {
+	flags, options := newFlagSet("delete")
+	namespace := flags.StringP("namespace", "n", "default", "namespace of the deployment")
+	deploymentName := flags.String("name", "", "deployment name")
+	dryRun := addDryRunFlag(flags)
+	wait := flags.Bool("wait", false, "wait until the deployment and its pods are gone, within --timeout")
+	if ok, code := parseFlags(flags, args); !ok {
+		return code
+	}
+	if !requireFlags(flags, "name") {
+		return exitUsage
+	}
+
+	clientset, ok, code := connect(options)
+	if !ok {
+		return code
+	}
+	err := runCancellable(options.timeout, func(ctx context.Context) error {
+		if *dryRun {
+			return printPreview(previewDelete(ctx, clientset, *namespace, *deploymentName))
+		}
+		if *wait {
+			return deleteK8sDeploymentAndWait(ctx, clientset, *namespace, *deploymentName)
+		}
+		return deleteK8sDeployment(ctx, clientset, *namespace, *deploymentName)
+	})
+	if err != nil {
+		reportError(err)
+	}
+	return exitCodeForError(err)
+}
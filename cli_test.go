@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestSubcommandUsage(t *testing.T) {
+	cases := []struct {
+		args []string
+		want int
+	}{
+		{[]string{"help"}, exitOK},
+		{[]string{"view", "--help"}, exitOK},
+		{[]string{"unknown"}, exitUsage},
+		{[]string{"view", "--unknown-flag"}, exitUsage},
+		{[]string{"view", "pods", "extra-argument"}, exitUsage},
+		{[]string{"create", "--image", "nginx"}, exitUsage},
+		{[]string{"create", "--name", "demo"}, exitUsage},
+		{[]string{"create", "-f", "manifest.yaml", "--image", "nginx"}, exitUsage},
+		{[]string{"create", "-f", "manifest.yaml", "--wait"}, exitUsage},
+		{[]string{"apply", "-f", "manifest.yaml", "--replicas", "2"}, exitUsage},
+		{[]string{"delete"}, exitUsage},
+	}
+	for _, c := range cases {
+		if got := runSubcommand(c.args); got != c.want {
+			t.Errorf("Exit code of %v, got: %d, want: %d.", c.args, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,448 @@
+package main
+
+import (
+	"fmt"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"sort"
+	"strings"
+)
+
+var (
+	namespaceColumn = metaColumn("NAMESPACE", func(obj metav1.Object) string { return obj.GetNamespace() })
+	nameColumn      = metaColumn("NAME", func(obj metav1.Object) string { return obj.GetName() })
+	ageColumn       = metaColumn("AGE", func(obj metav1.Object) string { return age(obj.GetCreationTimestamp().Time) })
+)
+
+// kindColumns are the columns after NAME of the kinds the client knows, the same ones kubectl get shows. Objects of
+// other kinds only show their age.
+var kindColumns = map[schema.GroupVersionKind][]column{
+	appsv1.SchemeGroupVersion.WithKind("Deployment"): {
+		{header: "READY", value: func(obj runtime.Object) string {
+			d := obj.(*appsv1.Deployment)
+			return fmt.Sprintf("%d/%d", d.Status.ReadyReplicas, replicasOrDefault(d.Spec.Replicas))
+		}},
+		{header: "UP-TO-DATE", value: func(obj runtime.Object) string {
+			return fmt.Sprint(obj.(*appsv1.Deployment).Status.UpdatedReplicas)
+		}},
+		{header: "AVAILABLE", value: func(obj runtime.Object) string {
+			return fmt.Sprint(obj.(*appsv1.Deployment).Status.AvailableReplicas)
+		}},
+		ageColumn,
+		templateColumn("CONTAINERS", containerNames, func(obj runtime.Object) *v1.PodTemplateSpec {
+			return &obj.(*appsv1.Deployment).Spec.Template
+		}),
+		templateColumn("IMAGES", containerImages, func(obj runtime.Object) *v1.PodTemplateSpec {
+			return &obj.(*appsv1.Deployment).Spec.Template
+		}),
+		{header: "SELECTOR", wide: true, value: func(obj runtime.Object) string {
+			return metav1.FormatLabelSelector(obj.(*appsv1.Deployment).Spec.Selector)
+		}},
+	},
+	appsv1.SchemeGroupVersion.WithKind("ReplicaSet"): {
+		{header: "DESIRED", value: func(obj runtime.Object) string {
+			return fmt.Sprint(replicasOrDefault(obj.(*appsv1.ReplicaSet).Spec.Replicas))
+		}},
+		{header: "CURRENT", value: func(obj runtime.Object) string {
+			return fmt.Sprint(obj.(*appsv1.ReplicaSet).Status.Replicas)
+		}},
+		{header: "READY", value: func(obj runtime.Object) string {
+			return fmt.Sprint(obj.(*appsv1.ReplicaSet).Status.ReadyReplicas)
+		}},
+		ageColumn,
+		templateColumn("CONTAINERS", containerNames, func(obj runtime.Object) *v1.PodTemplateSpec {
+			return &obj.(*appsv1.ReplicaSet).Spec.Template
+		}),
+		templateColumn("IMAGES", containerImages, func(obj runtime.Object) *v1.PodTemplateSpec {
+			return &obj.(*appsv1.ReplicaSet).Spec.Template
+		}),
+		{header: "SELECTOR", wide: true, value: func(obj runtime.Object) string {
+			return metav1.FormatLabelSelector(obj.(*appsv1.ReplicaSet).Spec.Selector)
+		}},
+	},
+	appsv1.SchemeGroupVersion.WithKind("StatefulSet"): {
+		{header: "READY", value: func(obj runtime.Object) string {
+			s := obj.(*appsv1.StatefulSet)
+			return fmt.Sprintf("%d/%d", s.Status.ReadyReplicas, replicasOrDefault(s.Spec.Replicas))
+		}},
+		ageColumn,
+		templateColumn("CONTAINERS", containerNames, func(obj runtime.Object) *v1.PodTemplateSpec {
+			return &obj.(*appsv1.StatefulSet).Spec.Template
+		}),
+		templateColumn("IMAGES", containerImages, func(obj runtime.Object) *v1.PodTemplateSpec {
+			return &obj.(*appsv1.StatefulSet).Spec.Template
+		}),
+	},
+	appsv1.SchemeGroupVersion.WithKind("DaemonSet"): {
+		{header: "DESIRED", value: func(obj runtime.Object) string {
+			return fmt.Sprint(obj.(*appsv1.DaemonSet).Status.DesiredNumberScheduled)
+		}},
+		{header: "CURRENT", value: func(obj runtime.Object) string {
+			return fmt.Sprint(obj.(*appsv1.DaemonSet).Status.CurrentNumberScheduled)
+		}},
+		{header: "READY", value: func(obj runtime.Object) string {
+			return fmt.Sprint(obj.(*appsv1.DaemonSet).Status.NumberReady)
+		}},
+		{header: "UP-TO-DATE", value: func(obj runtime.Object) string {
+			return fmt.Sprint(obj.(*appsv1.DaemonSet).Status.UpdatedNumberScheduled)
+		}},
+		{header: "AVAILABLE", value: func(obj runtime.Object) string {
+			return fmt.Sprint(obj.(*appsv1.DaemonSet).Status.NumberAvailable)
+		}},
+		{header: "NODE SELECTOR", value: func(obj runtime.Object) string {
+			return formatLabels(obj.(*appsv1.DaemonSet).Spec.Template.Spec.NodeSelector)
+		}},
+		ageColumn,
+		templateColumn("CONTAINERS", containerNames, func(obj runtime.Object) *v1.PodTemplateSpec {
+			return &obj.(*appsv1.DaemonSet).Spec.Template
+		}),
+		templateColumn("IMAGES", containerImages, func(obj runtime.Object) *v1.PodTemplateSpec {
+			return &obj.(*appsv1.DaemonSet).Spec.Template
+		}),
+	},
+	batchv1.SchemeGroupVersion.WithKind("Job"): {
+		{header: "COMPLETIONS", value: func(obj runtime.Object) string {
+			j := obj.(*batchv1.Job)
+			completions := "1"
+			if j.Spec.Completions != nil {
+				completions = fmt.Sprint(*j.Spec.Completions)
+			}
+			return fmt.Sprintf("%d/%v", j.Status.Succeeded, completions)
+		}},
+		{header: "DURATION", value: func(obj runtime.Object) string {
+			j := obj.(*batchv1.Job)
+			if j.Status.StartTime == nil {
+				return "<none>"
+			} else if j.Status.CompletionTime == nil {
+				return age(j.Status.StartTime.Time)
+			}
+			return duration.HumanDuration(j.Status.CompletionTime.Sub(j.Status.StartTime.Time))
+		}},
+		ageColumn,
+		templateColumn("CONTAINERS", containerNames, func(obj runtime.Object) *v1.PodTemplateSpec {
+			return &obj.(*batchv1.Job).Spec.Template
+		}),
+		templateColumn("IMAGES", containerImages, func(obj runtime.Object) *v1.PodTemplateSpec {
+			return &obj.(*batchv1.Job).Spec.Template
+		}),
+	},
+	batchv1.SchemeGroupVersion.WithKind("CronJob"): {
+		{header: "SCHEDULE", value: func(obj runtime.Object) string {
+			return obj.(*batchv1.CronJob).Spec.Schedule
+		}},
+		{header: "SUSPEND", value: func(obj runtime.Object) string {
+			suspend := obj.(*batchv1.CronJob).Spec.Suspend
+			return fmt.Sprint(suspend != nil && *suspend)
+		}},
+		{header: "ACTIVE", value: func(obj runtime.Object) string {
+			return fmt.Sprint(len(obj.(*batchv1.CronJob).Status.Active))
+		}},
+		{header: "LAST SCHEDULE", value: func(obj runtime.Object) string {
+			last := obj.(*batchv1.CronJob).Status.LastScheduleTime
+			if last == nil {
+				return "<none>"
+			}
+			return age(last.Time)
+		}},
+		ageColumn,
+		templateColumn("CONTAINERS", containerNames, func(obj runtime.Object) *v1.PodTemplateSpec {
+			return &obj.(*batchv1.CronJob).Spec.JobTemplate.Spec.Template
+		}),
+		templateColumn("IMAGES", containerImages, func(obj runtime.Object) *v1.PodTemplateSpec {
+			return &obj.(*batchv1.CronJob).Spec.JobTemplate.Spec.Template
+		}),
+	},
+	v1.SchemeGroupVersion.WithKind("Service"): {
+		{header: "TYPE", value: func(obj runtime.Object) string {
+			return string(obj.(*v1.Service).Spec.Type)
+		}},
+		{header: "CLUSTER-IP", value: func(obj runtime.Object) string {
+			return orNone(obj.(*v1.Service).Spec.ClusterIP)
+		}},
+		{header: "EXTERNAL-IP", value: func(obj runtime.Object) string {
+			return serviceExternalIP(obj.(*v1.Service))
+		}},
+		{header: "PORT(S)", value: func(obj runtime.Object) string {
+			var ports []string
+			for _, p := range obj.(*v1.Service).Spec.Ports {
+				port := fmt.Sprintf("%d/%s", p.Port, p.Protocol)
+				if p.NodePort != 0 {
+					port = fmt.Sprintf("%d:%d/%s", p.Port, p.NodePort, p.Protocol)
+				}
+				ports = append(ports, port)
+			}
+			return orNone(strings.Join(ports, ","))
+		}},
+		ageColumn,
+		{header: "SELECTOR", wide: true, value: func(obj runtime.Object) string {
+			return formatLabels(obj.(*v1.Service).Spec.Selector)
+		}},
+	},
+	networkingv1.SchemeGroupVersion.WithKind("Ingress"): {
+		{header: "CLASS", value: func(obj runtime.Object) string {
+			class := obj.(*networkingv1.Ingress).Spec.IngressClassName
+			if class == nil {
+				return "<none>"
+			}
+			return *class
+		}},
+		{header: "HOSTS", value: func(obj runtime.Object) string {
+			var hosts []string
+			for _, rule := range obj.(*networkingv1.Ingress).Spec.Rules {
+				if rule.Host != "" {
+					hosts = append(hosts, rule.Host)
+				}
+			}
+			if len(hosts) == 0 {
+				return "*"
+			}
+			return strings.Join(hosts, ",")
+		}},
+		{header: "ADDRESS", value: func(obj runtime.Object) string {
+			return loadBalancerAddresses(obj.(*networkingv1.Ingress).Status.LoadBalancer)
+		}},
+		{header: "PORTS", value: func(obj runtime.Object) string {
+			if len(obj.(*networkingv1.Ingress).Spec.TLS) > 0 {
+				return "80, 443"
+			}
+			return "80"
+		}},
+		ageColumn,
+	},
+	v1.SchemeGroupVersion.WithKind("ConfigMap"): {
+		{header: "DATA", value: func(obj runtime.Object) string {
+			c := obj.(*v1.ConfigMap)
+			return fmt.Sprint(len(c.Data) + len(c.BinaryData))
+		}},
+		ageColumn,
+	},
+	v1.SchemeGroupVersion.WithKind("Secret"): {
+		{header: "TYPE", value: func(obj runtime.Object) string {
+			return string(obj.(*v1.Secret).Type)
+		}},
+		{header: "DATA", value: func(obj runtime.Object) string {
+			return fmt.Sprint(len(obj.(*v1.Secret).Data))
+		}},
+		ageColumn,
+	},
+	v1.SchemeGroupVersion.WithKind("ServiceAccount"): {
+		{header: "SECRETS", value: func(obj runtime.Object) string {
+			return fmt.Sprint(len(obj.(*v1.ServiceAccount).Secrets))
+		}},
+		ageColumn,
+	},
+	v1.SchemeGroupVersion.WithKind("PersistentVolumeClaim"): {
+		{header: "STATUS", value: func(obj runtime.Object) string {
+			return string(obj.(*v1.PersistentVolumeClaim).Status.Phase)
+		}},
+		{header: "VOLUME", value: func(obj runtime.Object) string {
+			return obj.(*v1.PersistentVolumeClaim).Spec.VolumeName
+		}},
+		{header: "CAPACITY", value: func(obj runtime.Object) string {
+			capacity, ok := obj.(*v1.PersistentVolumeClaim).Status.Capacity[v1.ResourceStorage]
+			if !ok {
+				return ""
+			}
+			return capacity.String()
+		}},
+		{header: "ACCESS MODES", value: func(obj runtime.Object) string {
+			abbreviations := map[v1.PersistentVolumeAccessMode]string{
+				v1.ReadWriteOnce: "RWO", v1.ReadOnlyMany: "ROX", v1.ReadWriteMany: "RWX",
+			}
+			var modes []string
+			for _, mode := range obj.(*v1.PersistentVolumeClaim).Status.AccessModes {
+				modes = append(modes, abbreviations[mode])
+			}
+			return strings.Join(modes, ",")
+		}},
+		{header: "STORAGECLASS", value: func(obj runtime.Object) string {
+			class := obj.(*v1.PersistentVolumeClaim).Spec.StorageClassName
+			if class == nil {
+				return ""
+			}
+			return *class
+		}},
+		ageColumn,
+	},
+	v1.SchemeGroupVersion.WithKind("Namespace"): {
+		{header: "STATUS", value: func(obj runtime.Object) string {
+			return string(obj.(*v1.Namespace).Status.Phase)
+		}},
+		ageColumn,
+	},
+	v1.SchemeGroupVersion.WithKind("Node"): {
+		{header: "STATUS", value: func(obj runtime.Object) string {
+			return nodeStatus(obj.(*v1.Node))
+		}},
+		{header: "ROLES", value: func(obj runtime.Object) string {
+			return orNone(strings.Join(nodeRoles(obj.(*v1.Node)), ","))
+		}},
+		ageColumn,
+		{header: "VERSION", value: func(obj runtime.Object) string {
+			return obj.(*v1.Node).Status.NodeInfo.KubeletVersion
+		}},
+		{header: "INTERNAL-IP", wide: true, value: func(obj runtime.Object) string {
+			return nodeAddress(obj.(*v1.Node), v1.NodeInternalIP)
+		}},
+		{header: "EXTERNAL-IP", wide: true, value: func(obj runtime.Object) string {
+			return nodeAddress(obj.(*v1.Node), v1.NodeExternalIP)
+		}},
+		{header: "OS-IMAGE", wide: true, value: func(obj runtime.Object) string {
+			return obj.(*v1.Node).Status.NodeInfo.OSImage
+		}},
+		{header: "KERNEL-VERSION", wide: true, value: func(obj runtime.Object) string {
+			return obj.(*v1.Node).Status.NodeInfo.KernelVersion
+		}},
+		{header: "CONTAINER-RUNTIME", wide: true, value: func(obj runtime.Object) string {
+			return obj.(*v1.Node).Status.NodeInfo.ContainerRuntimeVersion
+		}},
+	},
+}
+
+// resourceColumns returns the columns of the table of a type: the namespace of namespaced types, the name and the
+// columns of its kind.
+func resourceColumns(resource resourceType) []column {
+	if resource.isPods() {
+		return podColumns
+	}
+	var columns []column
+	if resource.namespaced {
+		columns = append(columns, namespaceColumn)
+	}
+	columns = append(columns, nameColumn)
+	if kind, ok := kindColumns[resource.GroupVersion().WithKind(resource.kind)]; ok {
+		return append(columns, kind...)
+	}
+	return append(columns, ageColumn)
+}
+
+// metaColumn is a column computed from the metadata of an object, which objects of every kind have.
+func metaColumn(header string, value func(obj metav1.Object) string) column {
+	return column{header: header, value: func(obj runtime.Object) string {
+		accessor, err := meta.Accessor(obj)
+		if err != nil {
+			return "<unknown>"
+		}
+		return value(accessor)
+	}}
+}
+
+// templateColumn is a wide column computed from the containers of the pod template of an object.
+func templateColumn(
+	header string,
+	value func(containers []v1.Container) string,
+	template func(obj runtime.Object) *v1.PodTemplateSpec) column {
+	return column{header: header, wide: true, value: func(obj runtime.Object) string {
+		return value(template(obj).Spec.Containers)
+	}}
+}
+
+func containerNames(containers []v1.Container) string {
+	var names []string
+	for _, c := range containers {
+		names = append(names, c.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+func containerImages(containers []v1.Container) string {
+	var images []string
+	for _, c := range containers {
+		images = append(images, c.Image)
+	}
+	return strings.Join(images, ",")
+}
+
+// replicasOrDefault returns the replicas of a spec, which the API server defaults to 1.
+func replicasOrDefault(replicas *int32) int32 {
+	if replicas == nil {
+		return 1
+	}
+	return *replicas
+}
+
+// formatLabels writes labels sorted by key, e.g. "app=demo,tier=web", or "<none>" when there are none.
+func formatLabels(set map[string]string) string {
+	if len(set) == 0 {
+		return "<none>"
+	}
+	return labels.SelectorFromSet(set).String()
+}
+
+// serviceExternalIP tells how a service is reached from outside the cluster, the way kubectl does.
+func serviceExternalIP(service *v1.Service) string {
+	switch service.Spec.Type {
+	case v1.ServiceTypeExternalName:
+		return service.Spec.ExternalName
+	case v1.ServiceTypeLoadBalancer:
+		addresses := append([]string{}, service.Spec.ExternalIPs...)
+		if balancer := loadBalancerAddresses(service.Status.LoadBalancer); balancer != "" {
+			addresses = append(addresses, balancer)
+		}
+		if len(addresses) == 0 {
+			return "<pending>"
+		}
+		return strings.Join(addresses, ",")
+	}
+	return orNone(strings.Join(service.Spec.ExternalIPs, ","))
+}
+
+// loadBalancerAddresses joins the IPs or host names of a load balancer.
+func loadBalancerAddresses(status v1.LoadBalancerStatus) string {
+	var addresses []string
+	for _, ingress := range status.Ingress {
+		if ingress.IP != "" {
+			addresses = append(addresses, ingress.IP)
+		} else if ingress.Hostname != "" {
+			addresses = append(addresses, ingress.Hostname)
+		}
+	}
+	return strings.Join(addresses, ",")
+}
+
+// nodeStatus tells whether the node is ready, e.g. "Ready" or "NotReady,SchedulingDisabled".
+func nodeStatus(node *v1.Node) string {
+	status := "Unknown"
+	for _, c := range node.Status.Conditions {
+		if c.Type == v1.NodeReady && c.Status == v1.ConditionTrue {
+			status = "Ready"
+		} else if c.Type == v1.NodeReady && c.Status == v1.ConditionFalse {
+			status = "NotReady"
+		}
+	}
+	if node.Spec.Unschedulable {
+		status += ",SchedulingDisabled"
+	}
+	return status
+}
+
+// nodeRoles returns the roles of the node from its node-role.kubernetes.io/<role> labels, sorted.
+func nodeRoles(node *v1.Node) []string {
+	var roles []string
+	for key, value := range node.Labels {
+		if strings.HasPrefix(key, "node-role.kubernetes.io/") {
+			roles = append(roles, strings.TrimPrefix(key, "node-role.kubernetes.io/"))
+		} else if key == "kubernetes.io/role" && value != "" {
+			roles = append(roles, value)
+		}
+	}
+	sort.Strings(roles)
+	return roles
+}
+
+func nodeAddress(node *v1.Node, addressType v1.NodeAddressType) string {
+	for _, address := range node.Status.Addresses {
+		if address.Type == addressType {
+			return address.Address
+		}
+	}
+	return "<none>"
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// gcFinalizers are the finalizers of the garbage collector, which go away by themselves once the dependents are
+// deleted or orphaned. Any other finalizer waits for a controller that may not exist.
+var gcFinalizers = sets.NewString(metav1.FinalizerDeleteDependents, metav1.FinalizerOrphanDependents)
+
+// watchRestartBackoff spaces out the restarts of watches that keep ending, so that a watch the API server refuses to
+// resume does not turn into a busy loop of requests.
+var watchRestartBackoff = wait.Backoff{
+	Duration: 200 * time.Millisecond,
+	Factor:   2,
+	Jitter:   0.5,
+	Steps:    6,
+	Cap:      5 * time.Second,
+}
+
+// deletionState tracks the objects of a deployment that are still to be deleted.
+type deletionState struct {
+	uid         types.UID
+	deployment  *appsv1.Deployment
+	replicaSets map[types.UID]*appsv1.ReplicaSet
+	pods        map[types.UID]*v1.Pod
+	// owners are the UIDs of every ReplicaSet of the deployment seen so far, deleted or not, to recognize its pods.
+	owners sets.String
+}
+
+// deleteK8sDeploymentAndWait deletes the deployment and waits until it is gone together with its ReplicaSets and
+// Pods.
+func deleteK8sDeploymentAndWait(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	namespace string,
+	deploymentName string) error {
+	deployment, err := clientset.AppsV1().Deployments(namespace).Get(ctx, deploymentName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("cannot get deployment %v in namespace %v: %w", deploymentName, namespace, err)
+	}
+	if err := deleteK8sDeployment(ctx, clientset, namespace, deploymentName); err != nil {
+		return err
+	}
+	return waitForDeletion(ctx, clientset, deployment)
+}
+
+// waitForDeletion watches the deployment, its ReplicaSets and its Pods until all of them are gone, counting down the
+// pods that remain. Finalizers that hold one of them back are reported as soon as they show up, and again in the
+// error when the context ends first.
+func waitForDeletion(ctx context.Context, clientset kubernetes.Interface, deployment *appsv1.Deployment) error {
+	namespace, name := deployment.Namespace, deployment.Name
+	options, err := selectorListOptions(deployment.Spec.Selector)
+	if err != nil {
+		return fmt.Errorf("invalid selector of deployment %v: %w", name, err)
+	}
+	state := &deletionState{
+		uid:         deployment.UID,
+		deployment:  deployment,
+		replicaSets: make(map[types.UID]*appsv1.ReplicaSet),
+		pods:        make(map[types.UID]*v1.Pod),
+		owners:      sets.NewString(),
+	}
+
+	// The ReplicaSets and Pods are listed before watching them from the resource version of the list, so that the
+	// state is complete before the first event. Listing again resets the state when a watch has to be restarted. The
+	// deployment is watched from the version last got, which is fetched again before restarting its watch.
+	watchDeployment := func() (watch.Interface, error) {
+		return clientset.AppsV1().Deployments(namespace).Watch(ctx, metav1.ListOptions{
+			FieldSelector:   fields.OneTermEqualSelector("metadata.name", name).String(),
+			ResourceVersion: state.deployment.ResourceVersion,
+		})
+	}
+	watchReplicaSets := func() (watch.Interface, error) {
+		list, err := clientset.AppsV1().ReplicaSets(namespace).List(ctx, options)
+		if err != nil {
+			return nil, err
+		}
+		state.replicaSets = make(map[types.UID]*appsv1.ReplicaSet)
+		for i := range list.Items {
+			state.addReplicaSet(&list.Items[i])
+		}
+		watchOptions := options
+		watchOptions.ResourceVersion = list.ResourceVersion
+		return clientset.AppsV1().ReplicaSets(namespace).Watch(ctx, watchOptions)
+	}
+	watchPods := func() (watch.Interface, error) {
+		list, err := clientset.CoreV1().Pods(namespace).List(ctx, options)
+		if err != nil {
+			return nil, err
+		}
+		state.pods = make(map[types.UID]*v1.Pod)
+		for i := range list.Items {
+			state.pods[list.Items[i].UID] = &list.Items[i]
+		}
+		watchOptions := options
+		watchOptions.ResourceVersion = list.ResourceVersion
+		return clientset.CoreV1().Pods(namespace).Watch(ctx, watchOptions)
+	}
+	deploymentWatch, err := watchDeployment()
+	if err != nil {
+		return fmt.Errorf("cannot watch deployment %v: %w", name, err)
+	}
+	defer func() { deploymentWatch.Stop() }()
+	replicaSetWatch, err := watchReplicaSets()
+	if err != nil {
+		return fmt.Errorf("cannot watch replica sets of deployment %v: %w", name, err)
+	}
+	defer func() { replicaSetWatch.Stop() }()
+	podWatch, err := watchPods()
+	if err != nil {
+		return fmt.Errorf("cannot watch pods of deployment %v: %w", name, err)
+	}
+	defer func() { podWatch.Stop() }()
+
+	incomplete := func() error {
+		if blocked := state.blockingFinalizers(); len(blocked) > 0 {
+			return fmt.Errorf("deletion of deployment %v did not complete, %v, %v: %w",
+				name, state.remaining(), strings.Join(blocked, ", "), ctx.Err())
+		}
+		return fmt.Errorf("deletion of deployment %v did not complete, %v: %w", name, state.remaining(), ctx.Err())
+	}
+	// Each watch backs off on its own, from the start again once it delivers an event. The events of the deployment
+	// are no longer received once it is gone, since a nil channel never fires.
+	deploymentBackoff, replicaSetBackoff, podBackoff := watchRestartBackoff, watchRestartBackoff, watchRestartBackoff
+	deploymentEvents := deploymentWatch.ResultChan()
+	progress := ""
+	reported := sets.NewString()
+	for {
+		for _, blocked := range state.blockingFinalizers() {
+			if !reported.Has(blocked) {
+				log.Printf("Deletion of %v.", blocked)
+				reported.Insert(blocked)
+			}
+		}
+		current := state.remaining()
+		if state.done() {
+			log.Printf("Deleted deployment %v together with its replica sets and pods.", name)
+			return nil
+		}
+		if current != progress {
+			log.Printf("Deleting deployment %v: %v.", name, current)
+			progress = current
+		}
+
+		select {
+		case event, ok := <-deploymentEvents:
+			if !ok || event.Type == watch.Error {
+				deploymentWatch.Stop()
+				if err := waitForBackoff(ctx, &deploymentBackoff); err != nil {
+					return incomplete()
+				}
+				d, err := clientset.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+				if apierrors.IsNotFound(err) {
+					state.deployment = nil
+					deploymentEvents = nil
+					continue
+				} else if err != nil {
+					return fmt.Errorf("cannot get deployment %v in namespace %v: %w", name, namespace, err)
+				}
+				state.deployment = d
+				if deploymentWatch, err = watchDeployment(); err != nil {
+					return fmt.Errorf("cannot watch deployment %v: %w", name, err)
+				}
+				deploymentEvents = deploymentWatch.ResultChan()
+				continue
+			}
+			deploymentBackoff = watchRestartBackoff
+			if event.Type == watch.Deleted {
+				state.deployment = nil
+			} else if d, ok := event.Object.(*appsv1.Deployment); ok {
+				state.deployment = d
+			}
+		case event, ok := <-replicaSetWatch.ResultChan():
+			if !ok || event.Type == watch.Error {
+				replicaSetWatch.Stop()
+				if err := waitForBackoff(ctx, &replicaSetBackoff); err != nil {
+					return incomplete()
+				}
+				if replicaSetWatch, err = watchReplicaSets(); err != nil {
+					return fmt.Errorf("cannot watch replica sets of deployment %v: %w", name, err)
+				}
+				continue
+			}
+			replicaSetBackoff = watchRestartBackoff
+			if rs, ok := event.Object.(*appsv1.ReplicaSet); ok && event.Type == watch.Deleted {
+				delete(state.replicaSets, rs.UID)
+			} else if ok {
+				state.addReplicaSet(rs)
+			}
+		case event, ok := <-podWatch.ResultChan():
+			if !ok || event.Type == watch.Error {
+				podWatch.Stop()
+				if err := waitForBackoff(ctx, &podBackoff); err != nil {
+					return incomplete()
+				}
+				if podWatch, err = watchPods(); err != nil {
+					return fmt.Errorf("cannot watch pods of deployment %v: %w", name, err)
+				}
+				continue
+			}
+			podBackoff = watchRestartBackoff
+			if pod, ok := event.Object.(*v1.Pod); ok {
+				if event.Type == watch.Deleted {
+					delete(state.pods, pod.UID)
+				} else {
+					state.pods[pod.UID] = pod
+				}
+			}
+		case <-ctx.Done():
+			return incomplete()
+		}
+	}
+}
+
+// waitForBackoff waits for the next step of the backoff, or returns the error of the context when it ends first.
+func waitForBackoff(ctx context.Context, backoff *wait.Backoff) error {
+	timer := time.NewTimer(backoff.Step())
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// addReplicaSet tracks a ReplicaSet if the deployment controls it.
+func (state *deletionState) addReplicaSet(rs *appsv1.ReplicaSet) {
+	if ref := metav1.GetControllerOf(rs); ref != nil && ref.UID == state.uid {
+		state.owners.Insert(string(rs.UID))
+		state.replicaSets[rs.UID] = rs
+	}
+}
+
+// done tells whether the deployment, its ReplicaSets and its Pods are all gone.
+func (state *deletionState) done() bool {
+	return state.deployment == nil && len(state.replicaSets) == 0 && len(state.ownedPods()) == 0
+}
+
+// ownedPods returns the pods of the ReplicaSets of the deployment, sorted by name.
+func (state *deletionState) ownedPods() []*v1.Pod {
+	var pods []*v1.Pod
+	for _, pod := range state.pods {
+		if ref := metav1.GetControllerOf(pod); ref != nil && state.owners.Has(string(ref.UID)) {
+			pods = append(pods, pod)
+		}
+	}
+	sort.Slice(pods, func(i, j int) bool { return pods[i].Name < pods[j].Name })
+	return pods
+}
+
+// remaining counts down what is left to delete, e.g. "1 replica set and 3 pods remaining".
+func (state *deletionState) remaining() string {
+	replicaSets, pods := len(state.replicaSets), len(state.ownedPods())
+	return fmt.Sprintf("%v and %v remaining", plural(replicaSets, "replica set"), plural(pods, "pod"))
+}
+
+// blockingFinalizers describes the objects being deleted that wait for finalizers other than those of the garbage
+// collector, e.g. `pod/demo-5d4f-abcde is blocked by finalizers [example.com/protect]`.
+func (state *deletionState) blockingFinalizers() []string {
+	var blocked []string
+	describe := func(kind schema.GroupVersionKind, object metav1.Object) {
+		if object.GetDeletionTimestamp() == nil {
+			return
+		}
+		var finalizers []string
+		for _, f := range object.GetFinalizers() {
+			if !gcFinalizers.Has(f) {
+				finalizers = append(finalizers, f)
+			}
+		}
+		if len(finalizers) > 0 {
+			blocked = append(blocked, fmt.Sprintf("%v is blocked by finalizers %v",
+				describeObject(kind, object.GetName()), finalizers))
+		}
+	}
+	if state.deployment != nil {
+		describe(deploymentKind, state.deployment)
+	}
+	var replicaSets []*appsv1.ReplicaSet
+	for _, rs := range state.replicaSets {
+		replicaSets = append(replicaSets, rs)
+	}
+	sort.Slice(replicaSets, func(i, j int) bool { return replicaSets[i].Name < replicaSets[j].Name })
+	for _, rs := range replicaSets {
+		describe(appsv1.SchemeGroupVersion.WithKind("ReplicaSet"), rs)
+	}
+	for _, pod := range state.ownedPods() {
+		describe(v1.SchemeGroupVersion.WithKind("Pod"), pod)
+	}
+	return blocked
+}
+
+// plural counts things in English, e.g. "1 pod" or "3 pods".
+func plural(n int, thing string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %v", n, thing)
+	}
+	return fmt.Sprintf("%d %vs", n, thing)
+}
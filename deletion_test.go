@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWaitForDeletion(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	clientset, server := newFakeClientset(t, newNamespace("default"))
+	if err := createSampleDeployment(clientset, "demo", "demo"); err != nil {
+		t.Fatalf("Cannot create deployment: %v", err.Error())
+	}
+	rs := newOwnedReplicaSet(getSampleDeployment(t, server, "demo"), "demo-5d4f")
+	server.seed(rs)
+	pods := []string{"demo-5d4f-abcde", "demo-5d4f-fghij", "demo-5d4f-klmno"}
+	for _, name := range pods {
+		server.seed(newOwnedPod(rs, name))
+	}
+	unrelated := newPod("default", "unrelated")
+	unrelated.Labels = rs.Labels
+	server.seed(unrelated)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- deleteK8sDeploymentAndWait(context.TODO(), clientset, "default", "demo")
+	}()
+	server.waitForWatches("pods", 1)
+	for _, name := range pods {
+		server.remove("pods", "default", name)
+	}
+	server.remove("replicasets", "default", "demo-5d4f")
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Cannot wait for the deletion: %v", err.Error())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Waiting for the deletion did not end after the pods were deleted.")
+	}
+	// The events of pods and replica sets arrive on separate watches, so only the countdown of pods is ordered.
+	for _, want := range []string{
+		"1 replica set and 3 pods remaining",
+		" 2 pods remaining",
+		" 1 pod remaining",
+		"Deleted deployment demo together with its replica sets and pods.",
+	} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("Progress of the deletion does not contain %q, got:\n%v", want, logs.String())
+		}
+	}
+}
+
+func TestWaitForDeletionBlockedByFinalizer(t *testing.T) {
+	clientset, server := newFakeClientset(t, newNamespace("default"))
+	if err := createSampleDeployment(clientset, "demo", "demo"); err != nil {
+		t.Fatalf("Cannot create deployment: %v", err.Error())
+	}
+	rs := newOwnedReplicaSet(getSampleDeployment(t, server, "demo"), "demo-5d4f")
+	server.seed(rs)
+	pod := newOwnedPod(rs, "demo-5d4f-abcde")
+	deleted := metav1.Now()
+	pod.DeletionTimestamp = &deleted
+	pod.Finalizers = []string{"example.com/protect"}
+	server.seed(pod)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	err := deleteK8sDeploymentAndWait(ctx, clientset, "default", "demo")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Error of a blocked deletion, got: %v, want a timeout.", err)
+	}
+	want := "pod/demo-5d4f-abcde is blocked by finalizers [example.com/protect]"
+	if !strings.Contains(err.Error(), want) {
+		t.Errorf("Error of a blocked deletion does not contain %q, got: %v", want, err)
+	}
+}
+
+func TestWaitForDeletionResumesFromLatestVersion(t *testing.T) {
+	clientset, server := newFakeClientset(t, newNamespace("default"))
+	if err := createSampleDeployment(clientset, "demo", "demo"); err != nil {
+		t.Fatalf("Cannot create deployment: %v", err.Error())
+	}
+	deployment := getSampleDeployment(t, server, "demo")
+	rs := newOwnedReplicaSet(deployment, "demo-5d4f")
+	server.seed(rs)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- deleteK8sDeploymentAndWait(context.TODO(), clientset, "default", "demo")
+	}()
+	server.waitForWatches("pods", 1)
+	// The deployment waits for the foreground deletion of its dependents, and meanwhile its watch cannot resume.
+	deleted := metav1.Now()
+	deployment.DeletionTimestamp = &deleted
+	deployment.Finalizers = []string{metav1.FinalizerDeleteDependents}
+	server.seed(deployment)
+	latest := server.get("deployments", "default", "demo").GetResourceVersion()
+	server.failNext("WATCH", "deployments", apierrors.NewResourceExpired("too old resource version"))
+	server.endWatches("deployments")
+	// The ended watch is restarted, fails, and is restarted once more.
+	for deadline := time.Now().Add(5 * time.Second); len(server.requestsOf("WATCH", "deployments")) < 3; {
+		if time.Now().After(deadline) {
+			t.Fatalf("The watch of the deployment was not restarted twice.")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	server.waitForWatches("deployments", 1)
+	server.remove("replicasets", "default", "demo-5d4f")
+	server.remove("deployments", "default", "demo")
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Cannot wait for the deletion: %v", err.Error())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Waiting for the deletion did not end after the deployment was deleted.")
+	}
+	watches := server.requestsOf("WATCH", "deployments")
+	if len(watches) != 3 {
+		t.Fatalf("Watches of the deployment, got: %d, want: 3.", len(watches))
+	}
+	for _, w := range watches[1:] {
+		if got := url.Values(w.query).Get("resourceVersion"); got != latest {
+			t.Errorf("Resource version of a restarted watch of the deployment, got: %v, want: %v.", got, latest)
+		}
+	}
+}
+
+func TestWaitForDeletionStopsWatchingDeletedDeployment(t *testing.T) {
+	clientset, server := newFakeClientset(t, newNamespace("default"))
+	if err := createSampleDeployment(clientset, "demo", "demo"); err != nil {
+		t.Fatalf("Cannot create deployment: %v", err.Error())
+	}
+	rs := newOwnedReplicaSet(getSampleDeployment(t, server, "demo"), "demo-5d4f")
+	server.seed(rs)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- deleteK8sDeploymentAndWait(context.TODO(), clientset, "default", "demo")
+	}()
+	server.waitForWatches("pods", 1)
+	// Once the deployment is gone, a dropped watch of it is not restarted while the replica set is still deleted.
+	server.endWatches("deployments")
+	time.Sleep(time.Second)
+	if gets := len(server.requestsOf("GET", "deployments")); gets > 2 {
+		t.Errorf("Gets of the deleted deployment, got: %d, want at most 2.", gets)
+	}
+	if watches := len(server.requestsOf("WATCH", "deployments")); watches != 1 {
+		t.Errorf("Watches of the deleted deployment, got: %d, want: 1.", watches)
+	}
+	server.remove("replicasets", "default", "demo-5d4f")
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Cannot wait for the deletion: %v", err.Error())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Waiting for the deletion did not end after the replica set was deleted.")
+	}
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/kubernetes"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// deploymentDescription is what describe shows of a deployment: the deployment, its ReplicaSets from the newest
+// revision to the oldest, its pods by name and the events of all of them from the oldest to the newest.
+type deploymentDescription struct {
+	deployment  *appsv1.Deployment
+	replicaSets []appsv1.ReplicaSet
+	pods        []v1.Pod
+	events      []v1.Event
+}
+
+// describeDeployment writes what is known of a deployment and of the objects it controls, like kubectl describe but
+// in one place.
+func describeDeployment(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	namespace string,
+	deploymentName string,
+	out io.Writer) error {
+	description, err := getDeploymentDescription(ctx, clientset, namespace, deploymentName)
+	if err != nil {
+		return err
+	}
+	return writeDeploymentDescription(out, description)
+}
+
+// getDeploymentDescription gets the deployment, then its ReplicaSets and its pods concurrently, keeping the pods of
+// its ReplicaSets, and finally the events of the three of them.
+func getDeploymentDescription(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	namespace string,
+	deploymentName string) (*deploymentDescription, error) {
+	deployment, err := clientset.AppsV1().Deployments(namespace).Get(ctx, deploymentName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("cannot get deployment %v in namespace %v: %w", deploymentName, namespace, err)
+	}
+	podOptions, err := selectorListOptions(deployment.Spec.Selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector of deployment %v: %w", deploymentName, err)
+	}
+
+	description := &deploymentDescription{deployment: deployment}
+	var pods []v1.Pod
+	err = gather(ctx,
+		func(ctx context.Context) error {
+			var err error
+			description.replicaSets, err = getOwnedReplicaSets(ctx, clientset, deployment)
+			return err
+		},
+		func(ctx context.Context) error {
+			list, err := clientset.CoreV1().Pods(namespace).List(ctx, podOptions)
+			if err != nil {
+				return fmt.Errorf("cannot get pods of deployment %v: %w", deploymentName, err)
+			}
+			pods = list.Items
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	description.pods = controlledPods(pods, description.replicaSets)
+	sort.Slice(description.replicaSets, func(i, j int) bool {
+		return replicaSetRevision(&description.replicaSets[i]) > replicaSetRevision(&description.replicaSets[j])
+	})
+	sort.Slice(description.pods, func(i, j int) bool {
+		return description.pods[i].Name < description.pods[j].Name
+	})
+	objects := []metav1.Object{deployment}
+	for i := range description.replicaSets {
+		objects = append(objects, &description.replicaSets[i])
+	}
+	for i := range description.pods {
+		objects = append(objects, &description.pods[i])
+	}
+	if description.events, err = getEventsOf(ctx, clientset, namespace, objects); err != nil {
+		return nil, err
+	}
+	return description, nil
+}
+
+// getEventsOf lists the events of each object, listWorkers objects at a time, from the oldest to the newest. Each
+// list selects the events of one object by its name and UID, so that the API server filters the events of a busy
+// namespace instead of sending all of them.
+func getEventsOf(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	namespace string,
+	objects []metav1.Object) ([]v1.Event, error) {
+	lists := make([][]v1.Event, len(objects))
+	tasks := make([]func(ctx context.Context) error, len(objects))
+	for i, object := range objects {
+		i, object := i, object
+		tasks[i] = func(ctx context.Context) error {
+			selector := fields.SelectorFromSet(fields.Set{
+				"involvedObject.name": object.GetName(),
+				"involvedObject.uid":  string(object.GetUID()),
+			})
+			list, err := clientset.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{
+				FieldSelector: selector.String(),
+			})
+			if err != nil {
+				return fmt.Errorf("cannot get events of %v in namespace %v: %w", object.GetName(), namespace, err)
+			}
+			lists[i] = list.Items
+			return nil
+		}
+	}
+	if err := gatherLimited(ctx, listWorkers, tasks...); err != nil {
+		return nil, err
+	}
+	var events []v1.Event
+	for _, list := range lists {
+		events = append(events, list...)
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return eventTime(&events[i]).Before(eventTime(&events[j]))
+	})
+	return events, nil
+}
+
+// eventTime is when an event was last seen, falling back to when it was first seen or created.
+func eventTime(event *v1.Event) time.Time {
+	switch {
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	case !event.FirstTimestamp.IsZero():
+		return event.FirstTimestamp.Time
+	}
+	return event.CreationTimestamp.Time
+}
+
+// writeDeploymentDescription writes the summary of the deployment spec followed by one section per kind of object.
+func writeDeploymentDescription(out io.Writer, description *deploymentDescription) error {
+	deployment := description.deployment
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Name:\t%s\n", deployment.Name)
+	fmt.Fprintf(w, "Namespace:\t%s\n", deployment.Namespace)
+	fmt.Fprintf(w, "Created:\t%s (%s ago)\n", deployment.CreationTimestamp.Format("2006-01-02 15:04:05"),
+		age(deployment.CreationTimestamp.Time))
+	fmt.Fprintf(w, "Labels:\t%s\n", formatLabels(deployment.Labels))
+	fmt.Fprintf(w, "Selector:\t%s\n", orNone(metav1.FormatLabelSelector(deployment.Spec.Selector)))
+	fmt.Fprintf(w, "Replicas:\t%s\n", replicaCounts(deployment))
+	fmt.Fprintf(w, "Strategy:\t%s\n", deploymentStrategy(deployment.Spec.Strategy))
+	fmt.Fprintf(w, "Paused:\t%t\n", deployment.Spec.Paused)
+	for _, c := range deployment.Spec.Template.Spec.Containers {
+		fmt.Fprintf(w, "Container %s:\t%s\n", c.Name, containerSummary(c))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	var conditions [][]string
+	for _, c := range deployment.Status.Conditions {
+		conditions = append(conditions, []string{string(c.Type), string(c.Status), orNone(c.Reason), c.Message})
+	}
+	var replicaSets [][]string
+	current := currentReplicaSet(description.replicaSets)
+	for i := range description.replicaSets {
+		rs := &description.replicaSets[i]
+		marker := ""
+		if rs == current {
+			marker = "*"
+		}
+		replicaSets = append(replicaSets, []string{
+			fmt.Sprintf("%s%d", marker, replicaSetRevision(rs)),
+			rs.Name,
+			fmt.Sprintf("%d/%d", rs.Status.ReadyReplicas, replicasOrDefault(rs.Spec.Replicas)),
+			containerImages(rs.Spec.Template.Spec.Containers),
+			age(rs.CreationTimestamp.Time),
+		})
+	}
+	var pods, containers [][]string
+	for i := range description.pods {
+		pod := &description.pods[i]
+		pods = append(pods, []string{pod.Name, podStatus(pod), podReady(pod), fmt.Sprint(podRestarts(pod)),
+			orNone(pod.Spec.NodeName), age(pod.CreationTimestamp.Time)})
+		for _, c := range containerStatuses(pod) {
+			containers = append(containers, []string{pod.Name, c.Name, containerState(c), lastTermination(c)})
+		}
+	}
+	var events [][]string
+	for _, event := range description.events {
+		events = append(events, []string{age(eventTime(&event)), event.Type, event.Reason,
+			strings.ToLower(event.InvolvedObject.Kind) + "/" + event.InvolvedObject.Name, event.Message})
+	}
+
+	sections := []struct {
+		title   string
+		headers []string
+		rows    [][]string
+	}{
+		{"Conditions", []string{"TYPE", "STATUS", "REASON", "MESSAGE"}, conditions},
+		{"ReplicaSets", []string{"REVISION", "NAME", "READY", "IMAGES", "AGE"}, replicaSets},
+		{"Pods", []string{"NAME", "STATUS", "READY", "RESTARTS", "NODE", "AGE"}, pods},
+		{"Containers", []string{"POD", "CONTAINER", "STATE", "LAST TERMINATION"}, containers},
+		{"Events", []string{"LAST SEEN", "TYPE", "REASON", "OBJECT", "MESSAGE"}, events},
+	}
+	for _, section := range sections {
+		fmt.Fprintf(out, "\n%s:\n", section.title)
+		if len(section.rows) == 0 {
+			fmt.Fprintln(out, "  <none>")
+			continue
+		}
+		w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+		fmt.Fprintf(w, "  %s\n", strings.Join(section.headers, "\t"))
+		for _, row := range section.rows {
+			fmt.Fprintf(w, "  %s\n", strings.Join(row, "\t"))
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deploymentStrategy describes how a deployment replaces its pods, e.g. "RollingUpdate, 25% max unavailable, 25% max
+// surge".
+func deploymentStrategy(strategy appsv1.DeploymentStrategy) string {
+	if strategy.Type == "" {
+		return "<none>"
+	} else if strategy.RollingUpdate == nil {
+		return string(strategy.Type)
+	}
+	description := string(strategy.Type)
+	if maxUnavailable := strategy.RollingUpdate.MaxUnavailable; maxUnavailable != nil {
+		description += fmt.Sprintf(", %s max unavailable", maxUnavailable.String())
+	}
+	if maxSurge := strategy.RollingUpdate.MaxSurge; maxSurge != nil {
+		description += fmt.Sprintf(", %s max surge", maxSurge.String())
+	}
+	return description
+}
+
+// containerSummary describes a container of a pod template, e.g. "nginx:1.21, ports http:80/TCP, requests
+// cpu=250m, limits memory=256Mi".
+func containerSummary(c v1.Container) string {
+	parts := []string{orNone(c.Image)}
+	var ports []string
+	for _, p := range c.Ports {
+		port := fmt.Sprintf("%d/%s", p.ContainerPort, p.Protocol)
+		if p.Name != "" {
+			port = p.Name + ":" + port
+		}
+		ports = append(ports, port)
+	}
+	if len(ports) > 0 {
+		parts = append(parts, "ports "+strings.Join(ports, ","))
+	}
+	if len(c.Resources.Requests) > 0 {
+		parts = append(parts, "requests "+formatResources(c.Resources.Requests))
+	}
+	if len(c.Resources.Limits) > 0 {
+		parts = append(parts, "limits "+formatResources(c.Resources.Limits))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// lastTermination tells why the previous instance of a container terminated and how long ago, e.g. "OOMKilled (exit
+// code 137) 5m ago", or "<none>" when it did not restart.
+func lastTermination(c v1.ContainerStatus) string {
+	terminated := c.LastTerminationState.Terminated
+	if terminated == nil {
+		return "<none>"
+	}
+	description := fmt.Sprintf("%s (exit code %d)", orNone(terminated.Reason), terminated.ExitCode)
+	if !terminated.FinishedAt.IsZero() {
+		description += " " + age(terminated.FinishedAt.Time) + " ago"
+	}
+	return description
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newRevision(deployment *appsv1.Deployment, name string, revision string, ready int32) *appsv1.ReplicaSet {
+	rs := newOwnedReplicaSet(deployment, name)
+	rs.Annotations = map[string]string{revisionAnnotation: revision}
+	rs.Spec.Replicas = deployment.Spec.Replicas
+	rs.Spec.Template = deployment.Spec.Template
+	rs.Status.ReadyReplicas = ready
+	return rs
+}
+
+func newEvent(object metav1.Object, kind string, reason string, message string, seen time.Time) *v1.Event {
+	return &v1.Event{
+		ObjectMeta: metav1.ObjectMeta{Namespace: object.GetNamespace(), Name: object.GetName() + "." + reason},
+		InvolvedObject: v1.ObjectReference{
+			Kind:      kind,
+			Namespace: object.GetNamespace(),
+			Name:      object.GetName(),
+			UID:       object.GetUID(),
+		},
+		Type:          v1.EventTypeWarning,
+		Reason:        reason,
+		Message:       message,
+		LastTimestamp: metav1.NewTime(seen),
+	}
+}
+
+func TestDescribeDeployment(t *testing.T) {
+	clientset, server := newFakeClientset(t, newNamespace("default"))
+	if err := createSampleDeployment(clientset, "demo", "kubernetes-bootcamp"); err != nil {
+		t.Fatalf("Cannot create the sample deployment: %v", err.Error())
+	}
+	deployment := getSampleDeployment(t, server, "kubernetes-bootcamp")
+	old := newRevision(deployment, "kubernetes-bootcamp-1", "1", 0)
+	current := newRevision(deployment, "kubernetes-bootcamp-2", "2", 3)
+	crashed := newOwnedPod(current, "kubernetes-bootcamp-2-a")
+	crashed.UID = types.UID("uid-kubernetes-bootcamp-2-a")
+	crashed.Spec = current.Spec.Template.Spec
+	crashed.Spec.NodeName = "node-1"
+	crashed.Status.ContainerStatuses = []v1.ContainerStatus{{
+		Name:         "kubernetes-bootcamp",
+		Ready:        true,
+		RestartCount: 2,
+		State:        v1.ContainerState{Running: &v1.ContainerStateRunning{}},
+		LastTerminationState: v1.ContainerState{
+			Terminated: &v1.ContainerStateTerminated{Reason: "OOMKilled", ExitCode: 137},
+		},
+	}}
+	stray := newPod("default", "stray")
+	stray.Labels = deployment.Spec.Selector.MatchLabels
+	now := time.Now()
+	for _, obj := range []runtime.Object{
+		old, current, crashed, stray,
+		newEvent(crashed, "Pod", "BackOff", "Back-off restarting failed container", now.Add(-time.Minute)),
+		newEvent(deployment, "Deployment", "ScalingReplicaSet", "Scaled up replica set kubernetes-bootcamp-2 to 4",
+			now.Add(-time.Hour)),
+		newEvent(stray, "Pod", "Pulled", "Container image already present", now),
+	} {
+		server.seed(obj)
+	}
+
+	var out bytes.Buffer
+	if err := describeDeployment(context.TODO(), clientset, "default", "kubernetes-bootcamp", &out); err != nil {
+		t.Fatalf("Cannot describe the deployment: %v", err)
+	}
+	for _, want := range []string{
+		`(?m)^Name: +kubernetes-bootcamp$`,
+		`(?m)^Selector: +app=demo$`,
+		`(?m)^Replicas: +4 desired, 0 updated, 0 ready, 0 available$`,
+		`(?m)^Container kubernetes-bootcamp: +gcr.io/google-samples/kubernetes-bootcamp:v1, ports http:80/TCP$`,
+		`(?m)^ReplicaSets:\n +REVISION +NAME +READY +IMAGES +AGE\n +\*2 +kubernetes-bootcamp-2 +3/4 .*\n +1 +kubernetes-bootcamp-1 +0/4 `,
+		`(?m)^Pods:\n +NAME +STATUS +READY +RESTARTS +NODE +AGE\n +kubernetes-bootcamp-2-a +Pending +1/1 +2 +node-1 +\S+\n\n`,
+		`(?m)^ +kubernetes-bootcamp-2-a +kubernetes-bootcamp +running, ready, 2 restarts +OOMKilled \(exit code 137\)$`,
+		`(?m)^Events:\n.*\n +\S+ +Warning +ScalingReplicaSet +deployment/kubernetes-bootcamp .*\n +\S+ +Warning +BackOff +pod/kubernetes-bootcamp-2-a `,
+		`(?m)^Conditions:\n +<none>$`,
+	} {
+		if !regexp.MustCompile(want).MatchString(out.String()) {
+			t.Errorf("Description does not match %q, got:\n%v", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "stray") {
+		t.Errorf("Description shows a pod not controlled by the deployment, got:\n%v", out.String())
+	}
+	// One list of events for the deployment, each of its two ReplicaSets and its pod.
+	lists := server.requestsOf("GET", "events")
+	if len(lists) != 4 {
+		t.Errorf("Lists of events, got: %d, want: 4.", len(lists))
+	}
+	for _, r := range lists {
+		if selector := url.Values(r.query).Get("fieldSelector"); !strings.Contains(selector, "involvedObject.uid=") {
+			t.Errorf("Field selector of a list of events, got: %q, want one selecting the object by UID.", selector)
+		}
+	}
+}
+
+func TestDescribeDeploymentFailure(t *testing.T) {
+	clientset, server := newFakeClientset(t, newNamespace("default"))
+	if err := createSampleDeployment(clientset, "demo", "kubernetes-bootcamp"); err != nil {
+		t.Fatalf("Cannot create the sample deployment: %v", err.Error())
+	}
+	server.failNext("GET", "events",
+		apierrors.NewForbidden(schema.GroupResource{Resource: "events"}, "", errors.New("denied")))
+	err := describeDeployment(context.TODO(), clientset, "default", "kubernetes-bootcamp", &bytes.Buffer{})
+	if !apierrors.IsForbidden(err) || !strings.Contains(err.Error(), "cannot get events of kubernetes-bootcamp in namespace default") {
+		t.Errorf("Error describing without access to events, got: %v, want a forbidden error.", err)
+	}
+	if _, err := getDeploymentDescription(context.TODO(), clientset, "default", "missing"); !apierrors.IsNotFound(err) {
+		t.Errorf("Error describing a missing deployment, got: %v, want not found.", err)
+	}
+}
@@ -0,0 +1,484 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/kubernetes"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// diagnosisLogLines is the number of lines shown of the log of a crashing container.
+const diagnosisLogLines = 5
+
+// podFacts are what the diagnosis rules know about a pod: the pod, its events from the oldest to the newest, the node
+// it was scheduled on when it is known, and a way to read the last lines logged by the previous instance of one of
+// its containers.
+type podFacts struct {
+	pod         *v1.Pod
+	events      []v1.Event
+	node        *v1.Node
+	previousLog func(container string) ([]string, error)
+}
+
+// finding is what a rule found wrong with a pod: a verdict in plain language, details backing it such as log lines,
+// and what to do about it.
+type finding struct {
+	rule    string
+	verdict string
+	details []string
+	fix     string
+}
+
+// diagnosisRule recognizes one reason why a pod does not run. check returns what it found, nothing when the rule
+// does not apply to the pod.
+type diagnosisRule struct {
+	name  string
+	check func(facts *podFacts) []finding
+}
+
+// diagnosisRules are checked against every pod, in this order. A check of our own is one more entry.
+var diagnosisRules = []diagnosisRule{
+	{"unschedulable", checkUnschedulable},
+	{"image-pull", checkImagePull},
+	{"container-config", checkContainerConfig},
+	{"oom-killed", checkOOMKilled},
+	{"crash-loop", checkCrashLoop},
+	{"probe", checkProbes},
+	{"evicted", checkEvicted},
+	{"node", checkNode},
+}
+
+// schedulingPredicates recognize why the scheduler found no node in its message, e.g. "0/3 nodes are available: 3
+// Insufficient memory.", each with what to do about it, where $1 stands for the first group of the pattern.
+var schedulingPredicates = []struct {
+	pattern *regexp.Regexp
+	fix     string
+}{
+	{regexp.MustCompile(`Insufficient ([\w./-]*\w)`),
+		"No node has enough free $1 for the requests of the pod: lower its $1 requests, scale down other workloads " +
+			"or add nodes."},
+	{regexp.MustCompile(`didn't match (Pod's )?node affinity|node\(s\) didn't match node selector`),
+		"No node has the labels the nodeSelector or node affinity of the pod asks for: label a node or fix the " +
+			"selector."},
+	{regexp.MustCompile(`didn't match pod (anti-)?affinity`),
+		"The pod affinity rules of the pod leave no node: relax them or add nodes."},
+	{regexp.MustCompile(`had (untolerated )?taint`),
+		"The nodes have taints the pod does not tolerate: add a toleration to the pod or remove the taint from a node."},
+	{regexp.MustCompile(`node\(s\) were unschedulable`),
+		"Nodes are cordoned: uncordon one once its maintenance is over."},
+	{regexp.MustCompile(`didn't have free ports`),
+		"The host port the pod asks for is taken on every node: drop the hostPort or run fewer replicas."},
+	{regexp.MustCompile(`unbound immediate PersistentVolumeClaims|persistentvolumeclaim "[^"]*" not found`),
+		"A PersistentVolumeClaim of the pod is missing or not bound: check that it exists and that its storage " +
+			"class can provision a volume."},
+}
+
+// imagePullReasons are the reasons a container waits for when its image cannot be pulled.
+var imagePullReasons = sets.NewString("ErrImagePull", "ImagePullBackOff", "InvalidImageName", "ErrImageNeverPull")
+
+// probeFixes tell what to do about each kind of failing probe, by the way the kubelet names it in its events.
+var probeFixes = map[string]string{
+	"Liveness": "The container is restarted while it fails. Check that the probe matches what the app serves, and " +
+		"give a slow start more time with initialDelaySeconds or a startupProbe.",
+	"Readiness": "The pod gets no traffic from its services while it fails. Check that the probe matches what the " +
+		"app serves and that what the app depends on is reachable.",
+	"Startup": "The container is restarted unless it passes within failureThreshold times periodSeconds. Raise them " +
+		"if the app starts slowly, or check that the probe matches what the app serves.",
+}
+
+// checkUnschedulable explains why the scheduler cannot place the pod, with the fix of each failing predicate.
+func checkUnschedulable(facts *podFacts) []finding {
+	message := ""
+	for _, c := range facts.pod.Status.Conditions {
+		if c.Type == v1.PodScheduled && c.Status == v1.ConditionFalse && c.Reason == v1.PodReasonUnschedulable {
+			message = c.Message
+		}
+	}
+	if event := lastEvent(facts.events, "FailedScheduling"); event != nil && message == "" {
+		message = event.Message
+	}
+	if message == "" {
+		return nil
+	}
+	var fixes []string
+	for _, p := range schedulingPredicates {
+		if match := p.pattern.FindStringSubmatchIndex(message); match != nil {
+			fixes = append(fixes, string(p.pattern.ExpandString(nil, p.fix, message, match)))
+		}
+	}
+	if len(fixes) == 0 {
+		fixes = append(fixes, "Compare what the pod asks for with the nodes, e.g. with the view task for nodes.")
+	}
+	return []finding{{verdict: "The pod cannot be scheduled: " + message, fix: strings.Join(fixes, " ")}}
+}
+
+// checkImagePull names the image a container cannot pull, with the error of the last pull.
+func checkImagePull(facts *podFacts) []finding {
+	var findings []finding
+	for _, c := range containerStatuses(facts.pod) {
+		if c.State.Waiting == nil || !imagePullReasons.Has(c.State.Waiting.Reason) {
+			continue
+		}
+		image := c.Image
+		if spec := podContainer(facts.pod, c.Name); spec != nil {
+			image = spec.Image
+		}
+		f := finding{
+			verdict: fmt.Sprintf("Container %v cannot pull its image %q (%v).", c.Name, image, c.State.Waiting.Reason),
+			fix: "Check that the image and its tag exist in the registry and fix them with the update task. " +
+				"Images of a private registry need imagePullSecrets on the pod.",
+		}
+		if event := lastEvent(facts.events, "Failed"); event != nil && strings.Contains(event.Message, image) {
+			f.details = append(f.details, event.Message)
+		} else if c.State.Waiting.Message != "" {
+			f.details = append(f.details, c.State.Waiting.Message)
+		}
+		findings = append(findings, f)
+	}
+	return findings
+}
+
+// checkContainerConfig explains why a container cannot be created, most often a missing ConfigMap or Secret.
+func checkContainerConfig(facts *podFacts) []finding {
+	var findings []finding
+	for _, c := range containerStatuses(facts.pod) {
+		w := c.State.Waiting
+		if w != nil && (w.Reason == "CreateContainerConfigError" || w.Reason == "CreateContainerError") {
+			findings = append(findings, finding{
+				verdict: fmt.Sprintf("Container %v cannot be created: %v", c.Name, orNone(w.Message)),
+				fix:     "Create the ConfigMap or Secret the container refers to, or fix the reference in the pod template.",
+			})
+		}
+	}
+	return findings
+}
+
+// checkOOMKilled tells which containers ran out of memory, with their memory limit.
+func checkOOMKilled(facts *podFacts) []finding {
+	var findings []finding
+	for _, c := range containerStatuses(facts.pod) {
+		if !isOOMKilled(c.State) && !isOOMKilled(c.LastTerminationState) {
+			continue
+		}
+		f := finding{
+			verdict: fmt.Sprintf("Container %v ran out of memory and was killed, it has no memory limit so the "+
+				"node itself ran out of memory.", c.Name),
+			fix: "Set a memory request and limit above what the app needs, e.g. with the update task, so that the " +
+				"pod goes to a node with enough memory.",
+		}
+		if spec := podContainer(facts.pod, c.Name); spec != nil && !spec.Resources.Limits.Memory().IsZero() {
+			limit := spec.Resources.Limits.Memory()
+			raised := limit.DeepCopy()
+			raised.Add(*limit)
+			f.verdict = fmt.Sprintf("Container %v ran out of memory and was killed, its memory limit is %v.",
+				c.Name, limit)
+			f.fix = fmt.Sprintf("Raise the memory limit, e.g. update --container %v --limits memory=%v, or find out "+
+				"why the app needs more memory than it used to.", c.Name, raised.String())
+		}
+		findings = append(findings, f)
+	}
+	return findings
+}
+
+func isOOMKilled(state v1.ContainerState) bool {
+	return state.Terminated != nil && state.Terminated.Reason == "OOMKilled"
+}
+
+// checkCrashLoop shows the last lines logged by the containers that keep crashing, which usually tell why. Those
+// that ran out of memory are left to checkOOMKilled.
+func checkCrashLoop(facts *podFacts) []finding {
+	var findings []finding
+	for _, c := range containerStatuses(facts.pod) {
+		if c.State.Waiting == nil || c.State.Waiting.Reason != "CrashLoopBackOff" || isOOMKilled(c.LastTerminationState) {
+			continue
+		}
+		f := finding{
+			verdict: fmt.Sprintf("Container %v keeps crashing, it restarted %v.", c.Name,
+				plural(int(c.RestartCount), "time")),
+			fix: "The last lines of its log usually tell why it exits, the logs task with previous shows all of " +
+				"them. If it exits right after starting, check its command, args and env.",
+		}
+		if last := c.LastTerminationState.Terminated; last != nil {
+			f.verdict = fmt.Sprintf("Container %v keeps crashing, it restarted %v and last exited with code %d (%v).",
+				c.Name, plural(int(c.RestartCount), "time"), last.ExitCode, orNone(last.Reason))
+		}
+		if lines, err := facts.previousLog(c.Name); err != nil {
+			f.details = append(f.details, fmt.Sprintf("cannot read its log: %v", err))
+		} else if len(lines) == 0 {
+			f.details = append(f.details, "it logged nothing before exiting")
+		} else {
+			f.details = append(f.details, lines...)
+		}
+		findings = append(findings, f)
+	}
+	return findings
+}
+
+// checkProbes tells which probes fail according to the events of the kubelet, with the last failure of each.
+func checkProbes(facts *podFacts) []finding {
+	type probe struct{ kind, container string }
+	failures := map[probe]*v1.Event{}
+	var probes []probe
+	for i := range facts.events {
+		event := &facts.events[i]
+		kind := strings.SplitN(event.Message, " ", 2)[0]
+		if event.Reason != "Unhealthy" || probeFixes[kind] == "" {
+			continue
+		}
+		p := probe{kind: kind, container: fieldPathContainer(event.InvolvedObject.FieldPath)}
+		if failures[p] == nil {
+			probes = append(probes, p)
+		}
+		failures[p] = event
+	}
+	var findings []finding
+	for _, p := range probes {
+		event := failures[p]
+		message := strings.TrimPrefix(event.Message, p.kind+" probe failed: ")
+		f := finding{
+			verdict: fmt.Sprintf("The %v probe of container %v fails: %v", strings.ToLower(p.kind),
+				orNone(p.container), message),
+			fix: probeFixes[p.kind],
+		}
+		if event.Count > 1 {
+			f.verdict += fmt.Sprintf(" (%v)", plural(int(event.Count), "time"))
+		}
+		if target := probeTarget(podContainer(facts.pod, p.container), p.kind); target != "" {
+			f.details = append(f.details, "the probe is "+target)
+		}
+		findings = append(findings, f)
+	}
+	return findings
+}
+
+// checkEvicted explains why the kubelet evicted the pod.
+func checkEvicted(facts *podFacts) []finding {
+	if facts.pod.Status.Reason != "Evicted" {
+		return nil
+	}
+	return []finding{{
+		verdict: "The pod was evicted: " + orNone(facts.pod.Status.Message),
+		fix: "Its controller creates a new pod elsewhere. Set requests matching what the app uses so that its " +
+			"pods are not the first ones evicted when a node runs short.",
+	}}
+}
+
+// checkNode tells when the node of the pod is not ready or runs short of memory, disk or process IDs.
+func checkNode(facts *podFacts) []finding {
+	node := facts.node
+	if node == nil {
+		return nil
+	}
+	var findings []finding
+	for _, c := range node.Status.Conditions {
+		if c.Type == v1.NodeReady && c.Status != v1.ConditionTrue {
+			findings = append(findings, finding{
+				verdict: fmt.Sprintf("Node %v of the pod is not ready (%v): %v", node.Name, orNone(c.Reason), c.Message),
+				fix: "Check the kubelet and the network of the node. The pods of a node that stays not ready are " +
+					"evicted after a few minutes and their controllers create new ones elsewhere.",
+			})
+		} else if c.Type != v1.NodeReady && c.Status == v1.ConditionTrue && strings.HasSuffix(string(c.Type), "Pressure") {
+			findings = append(findings, finding{
+				verdict: fmt.Sprintf("Node %v of the pod is under %v: %v", node.Name, c.Type, c.Message),
+				fix:     "Free up the node or move workloads away, the kubelet evicts pods while the pressure lasts.",
+			})
+		}
+	}
+	return findings
+}
+
+// lastEvent returns the newest of the events with the reason, or nil.
+func lastEvent(events []v1.Event, reason string) *v1.Event {
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].Reason == reason {
+			return &events[i]
+		}
+	}
+	return nil
+}
+
+// podContainer returns the container or init container of the pod with the name, or nil.
+func podContainer(pod *v1.Pod, name string) *v1.Container {
+	for _, containers := range [][]v1.Container{pod.Spec.InitContainers, pod.Spec.Containers} {
+		for i := range containers {
+			if containers[i].Name == name {
+				return &containers[i]
+			}
+		}
+	}
+	return nil
+}
+
+// fieldPathContainer returns the container an event is about from its field path, e.g. "spec.containers{web}".
+func fieldPathContainer(fieldPath string) string {
+	start, end := strings.Index(fieldPath, "{"), strings.LastIndex(fieldPath, "}")
+	if start < 0 || end < start {
+		return ""
+	}
+	return fieldPath[start+1 : end]
+}
+
+// probeTarget describes what a probe of the container checks, e.g. "GET http://:8080/healthz", or "" when the
+// container has no such probe.
+func probeTarget(c *v1.Container, kind string) string {
+	if c == nil {
+		return ""
+	}
+	probes := map[string]*v1.Probe{"Liveness": c.LivenessProbe, "Readiness": c.ReadinessProbe, "Startup": c.StartupProbe}
+	probe := probes[kind]
+	switch {
+	case probe == nil:
+		return ""
+	case probe.HTTPGet != nil:
+		scheme := strings.ToLower(string(probe.HTTPGet.Scheme))
+		if scheme == "" {
+			scheme = "http"
+		}
+		return fmt.Sprintf("GET %v://%v:%v%v", scheme, probe.HTTPGet.Host, probe.HTTPGet.Port.String(), probe.HTTPGet.Path)
+	case probe.TCPSocket != nil:
+		return fmt.Sprintf("a TCP connection to port %v", probe.TCPSocket.Port.String())
+	case probe.Exec != nil:
+		return fmt.Sprintf("the command %q", strings.Join(probe.Exec.Command, " "))
+	}
+	return ""
+}
+
+// diagnosePod runs every rule against the pod. A pod that is not running and ready but that no rule knows about
+// still gets a finding, pointing to where to look next.
+func diagnosePod(facts *podFacts, rules []diagnosisRule) []finding {
+	var findings []finding
+	for _, rule := range rules {
+		for _, f := range rule.check(facts) {
+			f.rule = rule.name
+			findings = append(findings, f)
+		}
+	}
+	if len(findings) == 0 && !isPodHealthy(facts.pod) {
+		findings = append(findings, finding{
+			rule:    "unknown",
+			verdict: fmt.Sprintf("The pod is %v and no rule knows why.", podStatus(facts.pod)),
+			fix:     "The describe task shows the events and the container states of its deployment.",
+		})
+	}
+	return findings
+}
+
+// isPodHealthy tells whether the pod completed, or runs with all its containers ready.
+func isPodHealthy(pod *v1.Pod) bool {
+	if pod.Status.Phase == v1.PodSucceeded {
+		return true
+	}
+	ready := podReady(pod)
+	return pod.Status.Phase == v1.PodRunning && pod.DeletionTimestamp == nil &&
+		ready == fmt.Sprintf("%d/%d", len(pod.Spec.Containers), len(pod.Spec.Containers))
+}
+
+// diagnosePods lists the pods of a namespace, or of every namespace when it is empty, together with their events
+// and the nodes, and writes what the diagnosis rules find wrong with each pod. The events and nodes are left out,
+// with a warning, when the credentials may not list them.
+func diagnosePods(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	namespace string,
+	options metav1.ListOptions,
+	out io.Writer) error {
+	var pods []v1.Pod
+	var events []v1.Event
+	nodes := map[string]*v1.Node{}
+	err := gather(ctx,
+		func(ctx context.Context) error {
+			var err error
+			pods, err = getPods(ctx, clientset, namespace, options)
+			return err
+		},
+		func(ctx context.Context) error {
+			// Only the events of pods are selected, the others of a busy namespace could be many.
+			list, err := clientset.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{
+				FieldSelector: fields.OneTermEqualSelector("involvedObject.kind", "Pod").String(),
+			})
+			if apierrors.IsForbidden(err) {
+				log.Printf("Diagnosing without events: %v", err)
+				return nil
+			} else if err != nil {
+				return fmt.Errorf("cannot get events: %w", err)
+			}
+			events = list.Items
+			return nil
+		},
+		func(ctx context.Context) error {
+			list, err := clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+			if apierrors.IsForbidden(err) {
+				log.Printf("Diagnosing without the state of the nodes: %v", err)
+				return nil
+			} else if err != nil {
+				return fmt.Errorf("cannot get nodes: %w", err)
+			}
+			for i := range list.Items {
+				nodes[list.Items[i].Name] = &list.Items[i]
+			}
+			return nil
+		})
+	if err != nil {
+		return err
+	}
+	if len(pods) == 0 {
+		fmt.Fprintln(out, "No pods found.")
+		return nil
+	}
+
+	sort.SliceStable(events, func(i, j int) bool { return eventTime(&events[i]).Before(eventTime(&events[j])) })
+	podEvents := map[string][]v1.Event{}
+	for _, event := range events {
+		key := event.InvolvedObject.Namespace + "/" + event.InvolvedObject.Name
+		podEvents[key] = append(podEvents[key], event)
+	}
+	sort.Slice(pods, func(i, j int) bool { return podKey(&pods[i]) < podKey(&pods[j]) })
+	healthy := 0
+	for i := range pods {
+		pod := &pods[i]
+		facts := &podFacts{pod: pod, node: nodes[pod.Spec.NodeName]}
+		for _, event := range podEvents[podKey(pod)] {
+			if event.InvolvedObject.UID == "" || event.InvolvedObject.UID == pod.UID {
+				facts.events = append(facts.events, event)
+			}
+		}
+		facts.previousLog = func(container string) ([]string, error) {
+			tail := int64(diagnosisLogLines)
+			data, err := clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &v1.PodLogOptions{
+				Container: container,
+				Previous:  true,
+				TailLines: &tail,
+			}).DoRaw(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return splitLines(string(data)), nil
+		}
+
+		findings := diagnosePod(facts, diagnosisRules)
+		if len(findings) == 0 {
+			healthy++
+			continue
+		}
+		fmt.Fprintf(out, "%v (%v):\n", podKey(pod), podStatus(pod))
+		for _, f := range findings {
+			fmt.Fprintf(out, "  %v\n", f.verdict)
+			for _, detail := range f.details {
+				fmt.Fprintf(out, "    | %v\n", detail)
+			}
+			fmt.Fprintf(out, "    Fix: %v\n", f.fix)
+		}
+		fmt.Fprintln(out)
+	}
+	fmt.Fprintf(out, "%d of %v running fine.\n", healthy, plural(len(pods), "pod"))
+	return nil
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newWaitingPod returns a pod whose container web waits for the reason.
+func newWaitingPod(reason string, message string) *v1.Pod {
+	pod := newRunningPod("default", "web-0")
+	pod.Status.ContainerStatuses[0].Ready = false
+	pod.Status.ContainerStatuses[0].State = v1.ContainerState{
+		Waiting: &v1.ContainerStateWaiting{Reason: reason, Message: message},
+	}
+	return pod
+}
+
+func TestDiagnosisRules(t *testing.T) {
+	pullFailed := newWaitingPod("ImagePullBackOff", `Back-off pulling image "nginx:1.999"`)
+	pullFailed.Spec.Containers[0].Image = "nginx:1.999"
+
+	oomKilled := newWaitingPod("CrashLoopBackOff", "back-off 40s restarting failed container")
+	oomKilled.Spec.Containers[0].Resources.Limits = v1.ResourceList{v1.ResourceMemory: resource.MustParse("128Mi")}
+	oomKilled.Status.ContainerStatuses[0].RestartCount = 3
+	oomKilled.Status.ContainerStatuses[0].LastTerminationState.Terminated = &v1.ContainerStateTerminated{
+		Reason:   "OOMKilled",
+		ExitCode: 137,
+	}
+
+	crashing := newWaitingPod("CrashLoopBackOff", "back-off 40s restarting failed container")
+	crashing.Status.ContainerStatuses[0].RestartCount = 4
+	crashing.Status.ContainerStatuses[0].LastTerminationState.Terminated = &v1.ContainerStateTerminated{
+		Reason:   "Error",
+		ExitCode: 1,
+	}
+
+	unschedulable := newPod("default", "web-0")
+	unschedulable.Status.Phase = v1.PodPending
+	unschedulable.Status.Conditions = []v1.PodCondition{{
+		Type:   v1.PodScheduled,
+		Status: v1.ConditionFalse,
+		Reason: v1.PodReasonUnschedulable,
+		Message: "0/3 nodes are available: 1 node(s) had taint {dedicated: gpu}, that the pod didn't tolerate, " +
+			"2 Insufficient memory.",
+	}}
+
+	unready := newRunningPod("default", "web-0")
+	unready.Status.ContainerStatuses[0].Ready = false
+	unready.Spec.Containers[0].ReadinessProbe = &v1.Probe{Handler: v1.Handler{
+		HTTPGet: &v1.HTTPGetAction{Path: "/ready", Port: intstr.FromInt(8080)},
+	}}
+	probeEvent := newEvent(unready, "Pod", "Unhealthy", "Readiness probe failed: HTTP probe failed with statuscode: 503",
+		time.Now())
+	probeEvent.InvolvedObject.FieldPath = "spec.containers{web}"
+	probeEvent.Count = 7
+
+	lostNode := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+		Status: v1.NodeStatus{Conditions: []v1.NodeCondition{
+			{Type: v1.NodeReady, Status: v1.ConditionUnknown, Reason: "NodeStatusUnknown",
+				Message: "Kubelet stopped posting node status."},
+			{Type: v1.NodeDiskPressure, Status: v1.ConditionTrue, Message: "kubelet has disk pressure"},
+		}},
+	}
+
+	creating := newWaitingPod("ContainerCreating", "")
+
+	tests := []struct {
+		name   string
+		facts  podFacts
+		want   []string
+		wanted int
+	}{
+		{"image pull", podFacts{pod: pullFailed}, []string{
+			`Container web cannot pull its image "nginx:1.999" (ImagePullBackOff).`,
+			`| Back-off pulling image "nginx:1.999"`,
+			"Fix: Check that the image and its tag exist",
+		}, 1},
+		{"out of memory", podFacts{pod: oomKilled}, []string{
+			"Container web ran out of memory and was killed, its memory limit is 128Mi.",
+			"update --container web --limits memory=256Mi",
+		}, 1},
+		{"crash loop", podFacts{pod: crashing, previousLog: func(container string) ([]string, error) {
+			return []string{"connecting to db:5432", "panic: connection refused"}, nil
+		}}, []string{
+			"Container web keeps crashing, it restarted 4 times and last exited with code 1 (Error).",
+			"| panic: connection refused",
+		}, 1},
+		{"crash loop without log", podFacts{pod: crashing, previousLog: func(container string) ([]string, error) {
+			return nil, errors.New("log rotated")
+		}}, []string{"| cannot read its log: log rotated"}, 1},
+		{"unschedulable", podFacts{pod: unschedulable}, []string{
+			"The pod cannot be scheduled: 0/3 nodes are available",
+			"No node has enough free memory for the requests of the pod: lower its memory requests",
+			"The nodes have taints the pod does not tolerate",
+		}, 1},
+		{"readiness probe", podFacts{pod: unready, events: []v1.Event{*probeEvent}}, []string{
+			"The readiness probe of container web fails: HTTP probe failed with statuscode: 503 (7 times)",
+			"| the probe is GET http://:8080/ready",
+			"The pod gets no traffic from its services while it fails.",
+		}, 1},
+		{"node not ready", podFacts{pod: newRunningPod("default", "web-0"), node: lostNode}, []string{
+			"Node node-1 of the pod is not ready (NodeStatusUnknown): Kubelet stopped posting node status.",
+			"Node node-1 of the pod is under DiskPressure: kubelet has disk pressure",
+		}, 2},
+		{"unknown", podFacts{pod: creating}, []string{"The pod is ContainerCreating and no rule knows why."}, 1},
+		{"healthy", podFacts{pod: newRunningPod("default", "web-0")}, nil, 0},
+	}
+	for _, test := range tests {
+		findings := diagnosePod(&test.facts, diagnosisRules)
+		if len(findings) != test.wanted {
+			t.Errorf("Findings of %v, got: %+v, want: %d.", test.name, findings, test.wanted)
+		}
+		var out bytes.Buffer
+		for _, f := range findings {
+			out.WriteString(f.verdict + "\n")
+			for _, detail := range f.details {
+				out.WriteString("| " + detail + "\n")
+			}
+			out.WriteString("Fix: " + f.fix + "\n")
+		}
+		for _, want := range test.want {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("Findings of %v do not contain %q, got:\n%v", test.name, want, out.String())
+			}
+		}
+	}
+}
+
+func TestDiagnosisRulesAreExtensible(t *testing.T) {
+	noLimits := diagnosisRule{name: "no-limits", check: func(facts *podFacts) []finding {
+		var findings []finding
+		for _, c := range facts.pod.Spec.Containers {
+			if len(c.Resources.Limits) == 0 {
+				findings = append(findings, finding{verdict: "Container " + c.Name + " has no limits."})
+			}
+		}
+		return findings
+	}}
+	findings := diagnosePod(&podFacts{pod: newRunningPod("default", "web-0")}, append(diagnosisRules, noLimits))
+	if len(findings) != 1 || findings[0].rule != "no-limits" {
+		t.Errorf("Findings of a rule of our own, got: %+v, want the one of no-limits.", findings)
+	}
+}
+
+func TestDiagnosePods(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	crashing := newWaitingPod("CrashLoopBackOff", "back-off 40s restarting failed container")
+	crashing.Name = "web-1"
+	crashing.Status.ContainerStatuses[0].RestartCount = 2
+	crashing.Status.ContainerStatuses[0].LastTerminationState.Terminated = &v1.ContainerStateTerminated{ExitCode: 2}
+	clientset, server := newFakeClientset(t, newNamespace("default"), newRunningPod("default", "web-0"), crashing)
+	server.log("default", "web-1", "web", true, "starting", "reading config", "config.yaml: no such file",
+		"usage: web --config FILE", "exit status 2", "bye")
+	server.failNext("GET", "nodes", apierrors.NewForbidden(schema.GroupResource{Resource: "nodes"}, "",
+		errors.New("nodes are cluster-scoped")))
+
+	var out bytes.Buffer
+	if err := diagnosePods(context.TODO(), clientset, "default", metav1.ListOptions{}, &out); err != nil {
+		t.Fatalf("Cannot diagnose the pods: %v", err)
+	}
+	want := "default/web-1 (CrashLoopBackOff):\n" +
+		"  Container web keeps crashing, it restarted 2 times and last exited with code 2 (<none>).\n" +
+		"    | reading config\n" +
+		"    | config.yaml: no such file\n" +
+		"    | usage: web --config FILE\n" +
+		"    | exit status 2\n" +
+		"    | bye\n"
+	if !strings.HasPrefix(out.String(), want) || !strings.HasSuffix(out.String(), "\n\n1 of 2 pods running fine.\n") {
+		t.Errorf("Diagnosis, got:\n%v\nwant it to start with:\n%v", out.String(), want)
+	}
+	for _, r := range server.requestsOf("GET", "pods/log") {
+		if query := url.Values(r.query); query.Get("previous") != "true" || query.Get("tailLines") != "5" {
+			t.Errorf("Query of the log of a crashing container, got: %v, want the last 5 lines before it crashed.", r.query)
+		}
+	}
+	for _, r := range server.requestsOf("GET", "events") {
+		if selector := url.Values(r.query).Get("fieldSelector"); selector != "involvedObject.kind=Pod" {
+			t.Errorf("Field selector of the events, got: %q, want only those of pods.", selector)
+		}
+	}
+	if !strings.Contains(logs.String(), "Diagnosing without the state of the nodes") {
+		t.Errorf("Logs of a diagnosis without access to nodes do not warn about it, got:\n%v", logs.String())
+	}
+}
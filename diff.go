@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change of a unified diff.
+const diffContext = 3
+
+// diffLine is a line of a diff, kept (' '), removed ('-') or added ('+').
+type diffLine struct {
+	op   byte
+	text string
+}
+
+// unifiedDiff compares two texts line by line and renders the differences in the unified format of diff -u, or
+// returns an empty string when the texts are equal.
+func unifiedDiff(fromName string, toName string, from string, to string) string {
+	lines := diffLines(splitLines(from), splitLines(to))
+
+	var changes []int
+	for i, l := range lines {
+		if l.op != ' ' {
+			changes = append(changes, i)
+		}
+	}
+	if len(changes) == 0 {
+		return ""
+	}
+
+	// Positions in both texts before each line of the diff, for the hunk headers.
+	fromPos := make([]int, len(lines)+1)
+	toPos := make([]int, len(lines)+1)
+	for i, l := range lines {
+		fromPos[i+1], toPos[i+1] = fromPos[i], toPos[i]
+		if l.op != '+' {
+			fromPos[i+1]++
+		}
+		if l.op != '-' {
+			toPos[i+1]++
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", fromName, toName)
+	for i := 0; i < len(changes); {
+		start := changes[i] - diffContext
+		if start < 0 {
+			start = 0
+		}
+		end := changes[i] + diffContext + 1
+		for i++; i < len(changes) && changes[i]-diffContext <= end; i++ {
+			end = changes[i] + diffContext + 1
+		}
+		if end > len(lines) {
+			end = len(lines)
+		}
+		fmt.Fprintf(&b, "@@ -%s +%s @@\n",
+			hunkRange(fromPos[start], fromPos[end]-fromPos[start]),
+			hunkRange(toPos[start], toPos[end]-toPos[start]))
+		for _, l := range lines[start:end] {
+			fmt.Fprintf(&b, "%c%s\n", l.op, l.text)
+		}
+	}
+	return b.String()
+}
+
+// hunkRange formats the lines of a hunk, where an empty range refers to the line before it like diff -u does.
+func hunkRange(start int, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprint(start + 1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// diffLines finds a shortest edit turning from into to, from the longest common subsequence of their lines. The
+// quadratic table is fine for the size of a Kubernetes object.
+func diffLines(from []string, to []string) []diffLine {
+	common := make([][]int, len(from)+1)
+	for i := range common {
+		common[i] = make([]int, len(to)+1)
+	}
+	for i := len(from) - 1; i >= 0; i-- {
+		for j := len(to) - 1; j >= 0; j-- {
+			if from[i] == to[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else if common[i+1][j] >= common[i][j+1] {
+				common[i][j] = common[i+1][j]
+			} else {
+				common[i][j] = common[i][j+1]
+			}
+		}
+	}
+
+	var lines []diffLine
+	i, j := 0, 0
+	for i < len(from) && j < len(to) {
+		if from[i] == to[j] {
+			lines = append(lines, diffLine{' ', from[i]})
+			i++
+			j++
+		} else if common[i+1][j] >= common[i][j+1] {
+			lines = append(lines, diffLine{'-', from[i]})
+			i++
+		} else {
+			lines = append(lines, diffLine{'+', to[j]})
+			j++
+		}
+	}
+	for ; i < len(from); i++ {
+		lines = append(lines, diffLine{'-', from[i]})
+	}
+	for ; j < len(to); j++ {
+		lines = append(lines, diffLine{'+', to[j]})
+	}
+	return lines
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"log"
+)
+
+// Exit codes of the non-interactive subcommands for the classes of errors returned by the API server, or by a rollout
+// that cannot complete.
+const (
+	exitNotFound      = 3
+	exitAlreadyExists = 4
+	exitForbidden     = 5
+	exitConflict      = 6
+	exitTimeout       = 7
+	exitInvalid       = 8
+	exitRolloutFailed = 9
+	exitInterrupted   = 130
+)
+
+// errorClass groups the errors that call for the same reaction from the user.
+type errorClass struct {
+	matches  func(err error) bool
+	exitCode int
+	hint     string
+}
+
+var errorClasses = []errorClass{
+	{
+		matches:  apierrors.IsNotFound,
+		exitCode: exitNotFound,
+		hint:     "Check the namespace and the name, the view task lists what exists.",
+	},
+	{
+		matches:  apierrors.IsAlreadyExists,
+		exitCode: exitAlreadyExists,
+		hint:     "Choose another name, or delete the existing object first.",
+	},
+	{
+		matches: func(err error) bool {
+			return apierrors.IsForbidden(err) || apierrors.IsUnauthorized(err)
+		},
+		exitCode: exitForbidden,
+		hint:     "Your credentials are not allowed to do this, check the kubeconfig and your RBAC permissions.",
+	},
+	{
+		matches:  isApplyConflict,
+		exitCode: exitConflict,
+		hint:     "Other tools manage the fields listed above, apply with force to take them over.",
+	},
+	{
+		matches:  apierrors.IsConflict,
+		exitCode: exitConflict,
+		hint:     "The object was modified concurrently, try again.",
+	},
+	{
+		matches: func(err error) bool {
+			return apierrors.IsInvalid(err) || apierrors.IsBadRequest(err)
+		},
+		exitCode: exitInvalid,
+		hint:     "Fix the fields listed above and try again.",
+	},
+	{
+		matches:  isRolloutError,
+		exitCode: exitRolloutFailed,
+		hint:     "The new pods cannot start, fix the image or the pod spec and apply again.",
+	},
+	{
+		matches: func(err error) bool {
+			return apierrors.IsTimeout(err) || apierrors.IsServerTimeout(err) || errors.Is(err, context.DeadlineExceeded)
+		},
+		exitCode: exitTimeout,
+		hint:     "The API server did not answer in time, check the connectivity to the cluster or raise --timeout.",
+	},
+	{
+		matches: func(err error) bool {
+			return errors.Is(err, context.Canceled)
+		},
+		exitCode: exitInterrupted,
+		hint:     "The command was interrupted, it may have been applied partially.",
+	},
+}
+
+func classifyError(err error) *errorClass {
+	for i := range errorClasses {
+		if errorClasses[i].matches(err) {
+			return &errorClasses[i]
+		}
+	}
+	return nil
+}
+
+// exitCodeForError maps an error to the exit code of a non-interactive command.
+func exitCodeForError(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	if class := classifyError(err); class != nil {
+		return class.exitCode
+	}
+	return exitFailure
+}
+
+// reportError logs an error together with a hint on how to resolve it.
+func reportError(err error) {
+	log.Printf("Error: %v", err)
+	if class := classifyError(err); class != nil {
+		log.Print(class.hint)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"testing"
+)
+
+func TestErrorsAreRecoverable(t *testing.T) {
+	clientset, _ := newFakeClientset(t, newNamespace("default"))
+
+	if err := createSampleDeployment(clientset, "demo", "demo"); err != nil {
+		t.Fatalf("Cannot create deployment: %v", err.Error())
+	}
+	err := createSampleDeployment(clientset, "demo", "demo")
+	if !apierrors.IsAlreadyExists(err) {
+		t.Errorf("Error of creating a duplicate deployment, got: %v, want: AlreadyExists.", err)
+	}
+
+	err = deleteSampleDeployment(clientset, "missing")
+	if !apierrors.IsNotFound(err) {
+		t.Errorf("Error of deleting a missing deployment, got: %v, want: NotFound.", err)
+	}
+}
+
+func TestExitCodeForError(t *testing.T) {
+	deployments := schema.GroupResource{Group: "apps", Resource: "deployments"}
+	cases := []struct {
+		err  error
+		want int
+	}{
+		{nil, exitOK},
+		{fmt.Errorf("unexpected"), exitFailure},
+		{apierrors.NewNotFound(deployments, "demo"), exitNotFound},
+		{apierrors.NewAlreadyExists(deployments, "demo"), exitAlreadyExists},
+		{apierrors.NewForbidden(deployments, "demo", fmt.Errorf("denied")), exitForbidden},
+		{apierrors.NewUnauthorized("no credentials"), exitForbidden},
+		{apierrors.NewConflict(deployments, "demo", fmt.Errorf("modified")), exitConflict},
+		{apierrors.NewTimeoutError("slow", 1), exitTimeout},
+		{fmt.Errorf("cannot list: %w", context.DeadlineExceeded), exitTimeout},
+		{fmt.Errorf("command interrupted: %w", context.Canceled), exitInterrupted},
+		{fmt.Errorf("cannot update: %w", &rolloutError{deployment: "demo", reason: "ProgressDeadlineExceeded"}),
+			exitRolloutFailed},
+		{fmt.Errorf("cannot create deployment: %w", apierrors.NewAlreadyExists(deployments, "demo")), exitAlreadyExists},
+	}
+	for _, c := range cases {
+		if got := exitCodeForError(c.err); got != c.want {
+			t.Errorf("Exit code of %v, got: %d, want: %d.", c.err, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,960 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeResource describes a resource type served by the fake API server.
+type fakeResource struct {
+	groupVersion string
+	kind         string
+	namespaced   bool
+	shortNames   []string
+}
+
+var fakeResources = map[string]fakeResource{
+	"namespaces":             {"v1", "Namespace", false, []string{"ns"}},
+	"nodes":                  {"v1", "Node", false, []string{"no"}},
+	"pods":                   {"v1", "Pod", true, []string{"po"}},
+	"services":               {"v1", "Service", true, []string{"svc"}},
+	"configmaps":             {"v1", "ConfigMap", true, []string{"cm"}},
+	"secrets":                {"v1", "Secret", true, nil},
+	"serviceaccounts":        {"v1", "ServiceAccount", true, []string{"sa"}},
+	"persistentvolumeclaims": {"v1", "PersistentVolumeClaim", true, []string{"pvc"}},
+	"deployments":            {"apps/v1", "Deployment", true, []string{"deploy"}},
+	"replicasets":            {"apps/v1", "ReplicaSet", true, []string{"rs"}},
+	"statefulsets":           {"apps/v1", "StatefulSet", true, []string{"sts"}},
+	"daemonsets":             {"apps/v1", "DaemonSet", true, []string{"ds"}},
+	"jobs":                   {"batch/v1", "Job", true, nil},
+	"cronjobs":               {"batch/v1", "CronJob", true, []string{"cj"}},
+	"ingresses":              {"networking.k8s.io/v1", "Ingress", true, []string{"ing"}},
+	"events":                 {"v1", "Event", true, []string{"ev"}},
+	"endpointslices":         {"discovery.k8s.io/v1", "EndpointSlice", true, nil},
+}
+
+// fakeRequest is a request received by the fake API server, recorded so that tests can assert on the calls made.
+type fakeRequest struct {
+	method    string
+	resource  string
+	namespace string
+	name      string
+	query     map[string][]string
+	body      []byte
+}
+
+// fakeAPIServer is an in-memory stand-in for the Kubernetes API server. It stores objects without running any
+// controller, so tests seed the objects that a controller would have created themselves.
+type fakeAPIServer struct {
+	*httptest.Server
+	t               *testing.T
+	mu              sync.Mutex
+	objects         map[string]*unstructured.Unstructured
+	resourceVersion int
+	requests        []fakeRequest
+	hanging         bool
+	failures        map[string]*apierrors.StatusError
+	watchers        map[*fakeWatcher]bool
+	history         []fakeEvent
+	// logs are the lines logged by the containers by namespace/pod/container, with a /previous suffix for the
+	// containers that terminated before them. logsChanged is closed and replaced whenever lines are logged.
+	logs        map[string][]string
+	logsChanged chan struct{}
+}
+
+// fakeEvent is a change of an object, kept so that watches can resume from a resource version.
+type fakeEvent struct {
+	eventType watch.EventType
+	resource  string
+	object    *unstructured.Unstructured
+}
+
+// fakeWatcher is an open watch request, which receives the events of the objects matching its selectors.
+type fakeWatcher struct {
+	resource  string
+	namespace string
+	labels    labels.Selector
+	fields    fields.Selector
+	pending   []fakeEvent
+	wake      chan struct{}
+	// ended is set to end the watch the way the API server ends watches after a while.
+	ended bool
+}
+
+// newFakeClientset starts a fake API server seeded with the given objects and returns a clientset talking to it.
+func newFakeClientset(t *testing.T, objects ...runtime.Object) (kubernetes.Interface, *fakeAPIServer) {
+	server := &fakeAPIServer{
+		t:           t,
+		objects:     make(map[string]*unstructured.Unstructured),
+		failures:    make(map[string]*apierrors.StatusError),
+		watchers:    make(map[*fakeWatcher]bool),
+		logs:        make(map[string][]string),
+		logsChanged: make(chan struct{}),
+	}
+	server.Server = httptest.NewServer(http.HandlerFunc(server.serveHTTP))
+	t.Cleanup(func() {
+		// Open watches would otherwise keep Close waiting.
+		server.CloseClientConnections()
+		server.Close()
+	})
+
+	for _, obj := range objects {
+		server.seed(obj)
+	}
+
+	clientset, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL, QPS: clientQPS, Burst: clientBurst})
+	if err != nil {
+		t.Fatalf("Cannot create clientset for the fake API server: %v", err.Error())
+	}
+	return clientset, server
+}
+
+// seed stores an object the way a controller or another client would write it, replacing the previous version and
+// notifying the watches.
+func (s *fakeAPIServer) seed(obj runtime.Object) {
+	kinds, _, err := scheme.Scheme.ObjectKinds(obj)
+	if err != nil {
+		s.t.Fatalf("Cannot seed object of unknown kind: %v", err.Error())
+	}
+	resource := fakeResourceOfKind(kinds[0].Kind)
+	if resource == "" {
+		s.t.Fatalf("The fake API server does not serve kind %v.", kinds[0].Kind)
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		s.t.Fatalf("Cannot convert seeded object: %v", err.Error())
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u := &unstructured.Unstructured{Object: content}
+	s.initialize(u, resource, u.GetNamespace())
+	s.updateGeneration(u, nil)
+	s.store(fakeObjectKey(resource, u.GetNamespace(), u.GetName()), u, false)
+}
+
+// remove deletes an object the way a controller or the garbage collector would, notifying the watches.
+func (s *fakeAPIServer) remove(resource string, namespace string, name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.store(fakeObjectKey(resource, namespace, name), nil, false)
+}
+
+// waitForWatches waits until the given number of watches of a resource are open, so that a test changes objects
+// only once the code under test is watching them.
+func (s *fakeAPIServer) waitForWatches(resource string, n int) {
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(time.Millisecond) {
+		s.mu.Lock()
+		open := 0
+		for watcher := range s.watchers {
+			if watcher.resource == resource {
+				open++
+			}
+		}
+		s.mu.Unlock()
+		if open >= n {
+			return
+		}
+	}
+	s.t.Fatalf("No %d watches of %v were opened.", n, resource)
+}
+
+// log appends lines to the log of a container, or of the container that terminated before it when previous is set,
+// notifying the requests following the log.
+func (s *fakeAPIServer) log(namespace string, pod string, container string, previous bool, lines ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := namespace + "/" + pod + "/" + container
+	if previous {
+		key += "/previous"
+	}
+	s.logs[key] = append(s.logs[key], lines...)
+	close(s.logsChanged)
+	s.logsChanged = make(chan struct{})
+}
+
+// endWatches ends the open watches of a resource, which clients see as a dropped connection.
+func (s *fakeAPIServer) endWatches(resource string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for watcher := range s.watchers {
+		if watcher.resource == resource {
+			watcher.ended = true
+			select {
+			case watcher.wake <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// get returns a copy of the stored object, or nil if it does not exist.
+func (s *fakeAPIServer) get(resource string, namespace string, name string) *unstructured.Unstructured {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if u, ok := s.objects[fakeObjectKey(resource, namespace, name)]; ok {
+		return u.DeepCopy()
+	}
+	return nil
+}
+
+// requestsOf returns the recorded requests with the given method and resource.
+func (s *fakeAPIServer) requestsOf(method string, resource string) []fakeRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var result []fakeRequest
+	for _, r := range s.requests {
+		if r.method == method && r.resource == resource {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+// hang makes the server stop answering until the client gives up, like an overloaded API server.
+func (s *fakeAPIServer) hang() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hanging = true
+}
+
+// failNext makes the next request with the given method on the given resource fail with err. A failing WATCH is
+// answered with an ERROR event, the way the API server reports a resource version too old to resume from.
+func (s *fakeAPIServer) failNext(method string, resource string, err *apierrors.StatusError) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[method+" "+resource] = err
+}
+
+func (s *fakeAPIServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	hanging := s.hanging
+	s.mu.Unlock()
+	if hanging {
+		<-r.Context().Done()
+		return
+	}
+
+	if r.Method == http.MethodGet && s.discover(w, r.URL.Path) {
+		return
+	}
+	resource, subresource, namespace, name, ok := parseFakePath(r.URL.Path)
+	if ok && r.Method == http.MethodGet && resource == "pods" && subresource == "log" {
+		s.podLog(w, r, namespace, name)
+		return
+	}
+	info, served := fakeResources[resource]
+	if !ok || !served || (subresource != "" && (subresource != "scale" || resource != "deployments")) {
+		http.NotFound(w, r)
+		return
+	}
+	body := make([]byte, 0)
+	if r.Body != nil {
+		decoder := json.NewDecoder(r.Body)
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err == nil {
+			body = raw
+		}
+	}
+
+	if r.Method == http.MethodGet && name == "" && r.URL.Query().Get("watch") == "true" {
+		s.watch(w, r, resource, namespace)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	requested := resource
+	if subresource != "" {
+		requested = resource + "/" + subresource
+	}
+	s.requests = append(s.requests, fakeRequest{
+		method:    r.Method,
+		resource:  requested,
+		namespace: namespace,
+		name:      name,
+		query:     r.URL.Query(),
+		body:      body,
+	})
+
+	groupResource := schema.GroupResource{
+		Group:    schema.FromAPIVersionAndKind(info.groupVersion, "").Group,
+		Resource: resource,
+	}
+	if err, ok := s.failures[r.Method+" "+requested]; ok {
+		delete(s.failures, r.Method+" "+requested)
+		writeStatus(w, err)
+		return
+	}
+
+	dryRun := isFakeDryRun(r, body)
+	key := fakeObjectKey(resource, namespace, name)
+	switch {
+	case subresource == "scale":
+		s.scale(w, r, groupResource, key, body, dryRun)
+	case r.Method == http.MethodGet && name == "":
+		s.writeList(w, r, resource, namespace)
+	case r.Method == http.MethodGet:
+		if u, ok := s.objects[key]; ok {
+			writeJSON(w, http.StatusOK, u.Object)
+		} else {
+			writeStatus(w, apierrors.NewNotFound(groupResource, name))
+		}
+	case r.Method == http.MethodPost:
+		u := &unstructured.Unstructured{}
+		if err := u.UnmarshalJSON(body); err != nil {
+			writeStatus(w, apierrors.NewBadRequest(err.Error()))
+			return
+		}
+		if u.GetName() == "" && u.GetGenerateName() != "" {
+			u.SetName(fmt.Sprintf("%s%05d", u.GetGenerateName(), s.resourceVersion+1))
+		}
+		key = fakeObjectKey(resource, namespace, u.GetName())
+		if _, exists := s.objects[key]; exists {
+			writeStatus(w, apierrors.NewAlreadyExists(groupResource, u.GetName()))
+			return
+		}
+		s.initialize(u, resource, namespace)
+		s.updateGeneration(u, nil)
+		s.store(key, u, dryRun)
+		writeJSON(w, http.StatusCreated, u.Object)
+	case r.Method == http.MethodPut:
+		u := &unstructured.Unstructured{}
+		if err := u.UnmarshalJSON(body); err != nil {
+			writeStatus(w, apierrors.NewBadRequest(err.Error()))
+			return
+		}
+		existing, ok := s.objects[key]
+		if !ok {
+			writeStatus(w, apierrors.NewNotFound(groupResource, name))
+			return
+		}
+		if rv := u.GetResourceVersion(); rv != "" && rv != existing.GetResourceVersion() {
+			writeStatus(w, apierrors.NewConflict(groupResource, name, fmt.Errorf("the object has been modified")))
+			return
+		}
+		u.SetUID(existing.GetUID())
+		u.SetCreationTimestamp(existing.GetCreationTimestamp())
+		s.initialize(u, resource, namespace)
+		s.updateGeneration(u, existing)
+		s.store(key, u, dryRun)
+		writeJSON(w, http.StatusOK, u.Object)
+	case r.Method == http.MethodPatch:
+		s.patch(w, r, groupResource, resource, namespace, name, body)
+	case r.Method == http.MethodDelete:
+		u, ok := s.objects[key]
+		if !ok {
+			writeStatus(w, apierrors.NewNotFound(groupResource, name))
+			return
+		}
+		s.store(key, nil, dryRun)
+		writeJSON(w, http.StatusOK, u.Object)
+	default:
+		writeStatus(w, apierrors.NewMethodNotSupported(groupResource, r.Method))
+	}
+}
+
+// patch applies a server-side apply, merge or strategic merge patch. Strategic merge patches are approximated by
+// merging lists of objects by their name, which covers the containers of a pod template. The caller must hold the
+// lock.
+func (s *fakeAPIServer) patch(
+	w http.ResponseWriter,
+	r *http.Request,
+	groupResource schema.GroupResource,
+	resource string,
+	namespace string,
+	name string,
+	body []byte) {
+	patchType := types.PatchType(r.Header.Get("Content-Type"))
+	var patch map[string]interface{}
+	if err := json.Unmarshal(body, &patch); err != nil {
+		writeStatus(w, apierrors.NewBadRequest(err.Error()))
+		return
+	}
+
+	key := fakeObjectKey(resource, namespace, name)
+	existing, ok := s.objects[key]
+	if !ok && patchType != types.ApplyPatchType {
+		writeStatus(w, apierrors.NewNotFound(groupResource, name))
+		return
+	}
+	if rv, _, _ := unstructured.NestedString(patch, "metadata", "resourceVersion"); ok && rv != "" &&
+		rv != existing.GetResourceVersion() {
+		writeStatus(w, apierrors.NewConflict(groupResource, name, fmt.Errorf("the object has been modified")))
+		return
+	}
+	target := make(map[string]interface{})
+	if ok {
+		target = existing.DeepCopy().Object
+	}
+	switch patchType {
+	case types.ApplyPatchType, types.MergePatchType, types.StrategicMergePatchType:
+		target = mergeFakePatch(target, patch, patchType != types.MergePatchType)
+	default:
+		writeStatus(w, apierrors.NewBadRequest(fmt.Sprintf("patch type %v is not supported", patchType)))
+		return
+	}
+
+	if ok && marshalFake(existing.Object) == marshalFake(target) {
+		// Like the API server, a patch without effect keeps the resource version.
+		writeJSON(w, http.StatusOK, existing.Object)
+		return
+	}
+	u := &unstructured.Unstructured{}
+	if data, err := json.Marshal(target); err != nil || u.UnmarshalJSON(data) != nil {
+		writeStatus(w, apierrors.NewBadRequest("cannot decode the patched object"))
+		return
+	}
+	if ok {
+		u.SetUID(existing.GetUID())
+		u.SetCreationTimestamp(existing.GetCreationTimestamp())
+	}
+	s.initialize(u, resource, namespace)
+	s.updateGeneration(u, existing)
+	s.store(key, u, isFakeDryRun(r, body))
+	if ok {
+		writeJSON(w, http.StatusOK, u.Object)
+	} else {
+		writeJSON(w, http.StatusCreated, u.Object)
+	}
+}
+
+// scale serves the scale subresource of a deployment, reading and writing its spec.replicas. The caller must hold the
+// lock.
+func (s *fakeAPIServer) scale(
+	w http.ResponseWriter,
+	r *http.Request,
+	groupResource schema.GroupResource,
+	key string,
+	body []byte,
+	dryRun bool) {
+	existing, ok := s.objects[key]
+	if !ok {
+		writeStatus(w, apierrors.NewNotFound(groupResource, key[strings.LastIndex(key, "/")+1:]))
+		return
+	}
+	u := existing
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var scale autoscalingv1.Scale
+		if err := json.Unmarshal(body, &scale); err != nil {
+			writeStatus(w, apierrors.NewBadRequest(err.Error()))
+			return
+		}
+		if rv := scale.ResourceVersion; rv != "" && rv != existing.GetResourceVersion() {
+			writeStatus(w, apierrors.NewConflict(groupResource, existing.GetName(),
+				fmt.Errorf("the object has been modified")))
+			return
+		}
+		u = existing.DeepCopy()
+		_ = unstructured.SetNestedField(u.Object, int64(scale.Spec.Replicas), "spec", "replicas")
+		s.initialize(u, "deployments", existing.GetNamespace())
+		s.updateGeneration(u, existing)
+		s.store(key, u, dryRun)
+	default:
+		writeStatus(w, apierrors.NewMethodNotSupported(groupResource, r.Method))
+		return
+	}
+	specReplicas, _, _ := unstructured.NestedInt64(u.Object, "spec", "replicas")
+	statusReplicas, _, _ := unstructured.NestedInt64(u.Object, "status", "replicas")
+	writeJSON(w, http.StatusOK, autoscalingv1.Scale{
+		TypeMeta: metav1.TypeMeta{APIVersion: "autoscaling/v1", Kind: "Scale"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              u.GetName(),
+			Namespace:         u.GetNamespace(),
+			UID:               u.GetUID(),
+			ResourceVersion:   u.GetResourceVersion(),
+			CreationTimestamp: u.GetCreationTimestamp(),
+		},
+		Spec:   autoscalingv1.ScaleSpec{Replicas: int32(specReplicas)},
+		Status: autoscalingv1.ScaleStatus{Replicas: int32(statusReplicas)},
+	})
+}
+
+// mergeFakePatch merges a patch into an object following RFC 7386, where null deletes a field. When byName is set,
+// lists of objects with a name are merged element by element instead of being replaced.
+func mergeFakePatch(target map[string]interface{}, patch map[string]interface{}, byName bool) map[string]interface{} {
+	for k, v := range patch {
+		if strings.HasPrefix(k, "$") {
+			continue
+		}
+		if v == nil {
+			delete(target, k)
+			continue
+		}
+		if patchMap, ok := v.(map[string]interface{}); ok {
+			targetMap, ok := target[k].(map[string]interface{})
+			if !ok {
+				targetMap = make(map[string]interface{})
+			}
+			target[k] = mergeFakePatch(targetMap, patchMap, byName)
+			continue
+		}
+		patchList, isList := v.([]interface{})
+		targetList, wasList := target[k].([]interface{})
+		if byName && isList && wasList {
+			if merged, ok := mergeFakeNamedLists(targetList, patchList); ok {
+				target[k] = merged
+				continue
+			}
+		}
+		target[k] = v
+	}
+	return target
+}
+
+func mergeFakeNamedLists(target []interface{}, patch []interface{}) ([]interface{}, bool) {
+	merged := append([]interface{}{}, target...)
+	for _, p := range patch {
+		patchItem, ok := p.(map[string]interface{})
+		if !ok || patchItem["name"] == nil {
+			return nil, false
+		}
+		found := false
+		for i, t := range merged {
+			if targetItem, ok := t.(map[string]interface{}); ok && targetItem["name"] == patchItem["name"] {
+				merged[i] = mergeFakePatch(targetItem, patchItem, true)
+				found = true
+			}
+		}
+		if !found {
+			merged = append(merged, patchItem)
+		}
+	}
+	return merged, true
+}
+
+// updateGeneration bumps metadata.generation when the spec changes, like the API server does for workloads. The
+// caller must hold the lock.
+func (s *fakeAPIServer) updateGeneration(u *unstructured.Unstructured, existing *unstructured.Unstructured) {
+	if existing == nil {
+		if u.GetGeneration() == 0 {
+			u.SetGeneration(1)
+		}
+		return
+	}
+	u.SetGeneration(existing.GetGeneration())
+	if marshalFake(existing.Object["spec"]) != marshalFake(u.Object["spec"]) {
+		u.SetGeneration(existing.GetGeneration() + 1)
+	}
+}
+
+// store saves the object written by a request, or deletes it when u is nil, and notifies the watches, unless the
+// request is a dry run. The caller must hold the lock.
+func (s *fakeAPIServer) store(key string, u *unstructured.Unstructured, dryRun bool) {
+	if dryRun {
+		return
+	}
+	existing, exists := s.objects[key]
+	event := fakeEvent{eventType: watch.Added, resource: key[:strings.Index(key, "/")], object: u}
+	if u == nil {
+		if !exists {
+			return
+		}
+		delete(s.objects, key)
+		s.resourceVersion++
+		event.eventType = watch.Deleted
+		event.object = existing.DeepCopy()
+		event.object.SetResourceVersion(fmt.Sprint(s.resourceVersion))
+	} else {
+		s.objects[key] = u
+		if exists {
+			event.eventType = watch.Modified
+		}
+	}
+	s.history = append(s.history, event)
+	for watcher := range s.watchers {
+		if watcher.matches(event.resource, event.object) {
+			watcher.pending = append(watcher.pending, event)
+			select {
+			case watcher.wake <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// isFakeDryRun tells whether a request asks for a dry run, which is a query parameter of writes but a field of the
+// options in the body of deletes.
+func isFakeDryRun(r *http.Request, body []byte) bool {
+	dryRun := r.URL.Query()["dryRun"]
+	if r.Method == http.MethodDelete {
+		var options metav1.DeleteOptions
+		if err := json.Unmarshal(body, &options); err == nil {
+			dryRun = append(dryRun, options.DryRun...)
+		}
+	}
+	for _, d := range dryRun {
+		if d == metav1.DryRunAll {
+			return true
+		}
+	}
+	return false
+}
+
+// initialize fills in the fields that the API server sets on every write. The caller must hold the lock.
+func (s *fakeAPIServer) initialize(u *unstructured.Unstructured, resource string, namespace string) {
+	info := fakeResources[resource]
+	s.resourceVersion++
+	u.SetAPIVersion(info.groupVersion)
+	u.SetKind(info.kind)
+	if info.namespaced {
+		u.SetNamespace(namespace)
+	}
+	u.SetResourceVersion(fmt.Sprint(s.resourceVersion))
+	if u.GetUID() == "" {
+		u.SetUID(types.UID(fmt.Sprintf("uid-%d", s.resourceVersion)))
+	}
+	if _, ok := u.Object["metadata"].(map[string]interface{})["creationTimestamp"].(string); !ok {
+		u.SetCreationTimestamp(metav1.NewTime(time.Now()))
+	}
+}
+
+// podLog writes the log of a container, the last tailLines lines of it when asked to, and then keeps writing the
+// lines logged after it with follow until the pod is deleted.
+func (s *fakeAPIServer) podLog(w http.ResponseWriter, r *http.Request, namespace string, name string) {
+	query := r.URL.Query()
+	key := namespace + "/" + name + "/" + query.Get("container")
+	if query.Get("previous") == "true" {
+		key += "/previous"
+	}
+	s.mu.Lock()
+	s.requests = append(s.requests, fakeRequest{
+		method:    r.Method,
+		resource:  "pods/log",
+		namespace: namespace,
+		name:      name,
+		query:     query,
+	})
+	_, exists := s.objects[fakeObjectKey("pods", namespace, name)]
+	lines, logged := s.logs[key]
+	s.mu.Unlock()
+	if !exists {
+		writeStatus(w, apierrors.NewNotFound(schema.GroupResource{Resource: "pods"}, name))
+		return
+	} else if !logged {
+		writeStatus(w, apierrors.NewBadRequest(fmt.Sprintf("container %q in pod %q has no log", query.Get("container"), name)))
+		return
+	}
+
+	offset := 0
+	if tail, err := strconv.Atoi(query.Get("tailLines")); err == nil && tail < len(lines) {
+		offset = len(lines) - tail
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusOK)
+	for {
+		s.mu.Lock()
+		lines, changed := s.logs[key], s.logsChanged
+		_, exists := s.objects[fakeObjectKey("pods", namespace, name)]
+		s.mu.Unlock()
+		for _, line := range lines[offset:] {
+			fmt.Fprintln(w, line)
+		}
+		offset = len(lines)
+		w.(http.Flusher).Flush()
+		if query.Get("follow") != "true" || !exists {
+			return
+		}
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// discover answers the discovery requests of the API groups and their resource types, returning false for other paths.
+func (s *fakeAPIServer) discover(w http.ResponseWriter, path string) bool {
+	groups := map[string]bool{}
+	var groupList metav1.APIGroupList
+	resources := map[string][]metav1.APIResource{}
+	var names []string
+	for name := range fakeResources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		info := fakeResources[name]
+		gv := schema.FromAPIVersionAndKind(info.groupVersion, "").GroupVersion()
+		if gv.Group != "" && !groups[gv.Group] {
+			groups[gv.Group] = true
+			version := metav1.GroupVersionForDiscovery{GroupVersion: info.groupVersion, Version: gv.Version}
+			groupList.Groups = append(groupList.Groups, metav1.APIGroup{
+				Name:             gv.Group,
+				Versions:         []metav1.GroupVersionForDiscovery{version},
+				PreferredVersion: version,
+			})
+		}
+		verbs := metav1.Verbs{"create", "delete", "get", "list", "patch", "update", "watch"}
+		resources[info.groupVersion] = append(resources[info.groupVersion], metav1.APIResource{
+			Name:         name,
+			SingularName: strings.ToLower(info.kind),
+			Namespaced:   info.namespaced,
+			Kind:         info.kind,
+			Verbs:        verbs,
+			ShortNames:   info.shortNames,
+		})
+		if name == "deployments" {
+			resources[info.groupVersion] = append(resources[info.groupVersion], metav1.APIResource{
+				Name: "deployments/scale", Namespaced: true, Kind: "Scale", Verbs: metav1.Verbs{"get", "patch", "update"},
+			})
+		}
+	}
+
+	switch {
+	case path == "/api":
+		writeJSON(w, http.StatusOK, &metav1.APIVersions{
+			TypeMeta: metav1.TypeMeta{Kind: "APIVersions"},
+			Versions: []string{"v1"},
+		})
+	case path == "/apis":
+		groupList.Kind, groupList.APIVersion = "APIGroupList", "v1"
+		writeJSON(w, http.StatusOK, &groupList)
+	case path == "/api/v1" || (strings.HasPrefix(path, "/apis/") && strings.Count(path, "/") == 3):
+		groupVersion := strings.TrimPrefix(strings.TrimPrefix(path, "/api/"), "/apis/")
+		writeJSON(w, http.StatusOK, &metav1.APIResourceList{
+			TypeMeta:     metav1.TypeMeta{Kind: "APIResourceList", APIVersion: "v1"},
+			GroupVersion: groupVersion,
+			APIResources: resources[groupVersion],
+		})
+	default:
+		return false
+	}
+	return true
+}
+
+// writeList writes the objects of a resource matching the selectors of the request, sorted by namespace and name,
+// a page at a time when the request has a limit. The caller must hold the lock.
+func (s *fakeAPIServer) writeList(w http.ResponseWriter, r *http.Request, resource string, namespace string) {
+	info := fakeResources[resource]
+	watcher, err := newFakeWatcher(r, resource, namespace)
+	if err != nil {
+		writeStatus(w, apierrors.NewBadRequest(err.Error()))
+		return
+	}
+	// The continue token of a page is the offset of the next object, which is good enough without concurrent writes.
+	objects := s.sortedObjects(watcher)
+	offset, _ := strconv.Atoi(r.URL.Query().Get("continue"))
+	end := len(objects)
+	if limit, _ := strconv.Atoi(r.URL.Query().Get("limit")); limit > 0 && offset+limit < end {
+		end = offset + limit
+	}
+	items := make([]interface{}, 0)
+	for _, u := range objects[offset:end] {
+		items = append(items, u.Object)
+	}
+	metadata := map[string]interface{}{"resourceVersion": fmt.Sprint(s.resourceVersion)}
+	if end < len(objects) {
+		metadata["continue"] = fmt.Sprint(end)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"apiVersion": info.groupVersion,
+		"kind":       info.kind + "List",
+		"metadata":   metadata,
+		"items":      items,
+	})
+}
+
+// sortedObjects returns the objects matching the resource and the selectors of a watcher, sorted by namespace and
+// name. The caller must hold the lock.
+func (s *fakeAPIServer) sortedObjects(watcher *fakeWatcher) []*unstructured.Unstructured {
+	var keys []string
+	for key, u := range s.objects {
+		if watcher.matches(key[:strings.Index(key, "/")], u) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	var objects []*unstructured.Unstructured
+	for _, key := range keys {
+		objects = append(objects, s.objects[key])
+	}
+	return objects
+}
+
+// watch streams the events of the objects matching the selectors of the request. Without a resource version, the
+// existing objects are sent as ADDED events first; with one, the events after it are replayed.
+func (s *fakeAPIServer) watch(w http.ResponseWriter, r *http.Request, resource string, namespace string) {
+	watcher, err := newFakeWatcher(r, resource, namespace)
+	if err != nil {
+		writeStatus(w, apierrors.NewBadRequest(err.Error()))
+		return
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, fakeRequest{
+		method:    "WATCH",
+		resource:  resource,
+		namespace: namespace,
+		query:     r.URL.Query(),
+	})
+	if err, ok := s.failures["WATCH "+resource]; ok {
+		delete(s.failures, "WATCH "+resource)
+		s.mu.Unlock()
+		status := err.ErrStatus
+		status.Kind = "Status"
+		status.APIVersion = "v1"
+		writeJSON(w, http.StatusOK, map[string]interface{}{"type": watch.Error, "object": status})
+		return
+	}
+	if rv := r.URL.Query().Get("resourceVersion"); rv == "" || rv == "0" {
+		for _, u := range s.sortedObjects(watcher) {
+			watcher.pending = append(watcher.pending, fakeEvent{eventType: watch.Added, resource: resource, object: u})
+		}
+	} else {
+		from, _ := strconv.Atoi(rv)
+		for _, event := range s.history {
+			version, _ := strconv.Atoi(event.object.GetResourceVersion())
+			if version > from && watcher.matches(event.resource, event.object) {
+				watcher.pending = append(watcher.pending, event)
+			}
+		}
+	}
+	s.watchers[watcher] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.watchers, watcher)
+		s.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	encoder := json.NewEncoder(w)
+	for {
+		s.mu.Lock()
+		events, ended := watcher.pending, watcher.ended
+		watcher.pending = nil
+		s.mu.Unlock()
+		if ended {
+			return
+		}
+		for _, event := range events {
+			_ = encoder.Encode(map[string]interface{}{"type": event.eventType, "object": event.object.Object})
+		}
+		w.(http.Flusher).Flush()
+
+		select {
+		case <-watcher.wake:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func newFakeWatcher(r *http.Request, resource string, namespace string) (*fakeWatcher, error) {
+	labelSelector, err := labels.Parse(r.URL.Query().Get("labelSelector"))
+	if err != nil {
+		return nil, err
+	}
+	fieldSelector, err := fields.ParseSelector(r.URL.Query().Get("fieldSelector"))
+	if err != nil {
+		return nil, err
+	}
+	return &fakeWatcher{
+		resource:  resource,
+		namespace: namespace,
+		labels:    labelSelector,
+		fields:    fieldSelector,
+		wake:      make(chan struct{}, 1),
+	}, nil
+}
+
+// matches tells whether an object of the given resource is selected, supporting the metadata fields of every object
+// the fields of pods that kubectl selects by and the fields of the object an event is about.
+func (watcher *fakeWatcher) matches(resource string, u *unstructured.Unstructured) bool {
+	if resource != watcher.resource || (watcher.namespace != "" && u.GetNamespace() != watcher.namespace) {
+		return false
+	}
+	fieldSet := fields.Set{"metadata.name": u.GetName(), "metadata.namespace": u.GetNamespace()}
+	if resource == "pods" {
+		fieldSet["spec.nodeName"], _, _ = unstructured.NestedString(u.Object, "spec", "nodeName")
+		fieldSet["status.phase"], _, _ = unstructured.NestedString(u.Object, "status", "phase")
+	}
+	if resource == "events" {
+		for _, field := range []string{"kind", "namespace", "name", "uid"} {
+			fieldSet["involvedObject."+field], _, _ = unstructured.NestedString(u.Object, "involvedObject", field)
+		}
+	}
+	return watcher.labels.Matches(labels.Set(u.GetLabels())) && watcher.fields.Matches(fieldSet)
+}
+
+// parseFakePath splits an API path such as /apis/apps/v1/namespaces/default/deployments/demo/scale into its
+// resource, subresource, namespace and name.
+func parseFakePath(path string) (resource string, subresource string, namespace string, name string, ok bool) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) >= 3 && parts[0] == "api" {
+		parts = parts[2:]
+	} else if len(parts) >= 4 && parts[0] == "apis" {
+		parts = parts[3:]
+	} else {
+		return "", "", "", "", false
+	}
+	if len(parts) >= 3 && parts[0] == "namespaces" {
+		namespace = parts[1]
+		parts = parts[2:]
+	}
+	resource = parts[0]
+	if len(parts) >= 2 {
+		name = parts[1]
+	}
+	if len(parts) == 3 {
+		subresource = parts[2]
+	}
+	return resource, subresource, namespace, name, len(parts) <= 3
+}
+
+func fakeResourceOfKind(kind string) string {
+	for resource, info := range fakeResources {
+		if info.kind == kind {
+			return resource
+		}
+	}
+	return ""
+}
+
+func fakeObjectKey(resource string, namespace string, name string) string {
+	return resource + "/" + namespace + "/" + name
+}
+
+// marshalFake encodes an object for comparisons, where numbers decoded as float64 and int64 encode the same.
+func marshalFake(obj interface{}) string {
+	data, _ := json.Marshal(obj)
+	return string(data)
+}
+
+func writeJSON(w http.ResponseWriter, code int, obj interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(obj)
+}
+
+func writeStatus(w http.ResponseWriter, err *apierrors.StatusError) {
+	status := err.ErrStatus
+	status.Kind = "Status"
+	status.APIVersion = "v1"
+	writeJSON(w, int(status.Code), status)
+}
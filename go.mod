@@ -2,4 +2,10 @@ module git.garena.com/jiayu.li/k8s-trial
 
 go 1.16
 
-require k8s.io/client-go v0.21.0
+require (
+	github.com/spf13/pflag v1.0.5
+	k8s.io/api v0.21.0
+	k8s.io/apimachinery v0.21.0
+	k8s.io/client-go v0.21.0
+	sigs.k8s.io/yaml v1.2.0
+)
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+	"log"
+	"os"
+	"sort"
+	"text/tabwriter"
+)
+
+// getHistory returns the deployment together with the ReplicaSets that make up its rollout history, sorted by
+// revision. ReplicaSets without a revision, e.g. adopted ones not yet seen by the controller, are left out.
+func getHistory(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	namespace string,
+	deploymentName string) (*appsv1.Deployment, []appsv1.ReplicaSet, error) {
+	deployment, err := clientset.AppsV1().Deployments(namespace).Get(ctx, deploymentName, metav1.GetOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot get deployment %v in namespace %v: %w", deploymentName, namespace, err)
+	}
+	owned, err := getOwnedReplicaSets(ctx, clientset, deployment)
+	if err != nil {
+		return nil, nil, err
+	}
+	var replicaSets []appsv1.ReplicaSet
+	for _, rs := range owned {
+		if replicaSetRevision(&rs) > 0 {
+			replicaSets = append(replicaSets, rs)
+		}
+	}
+	sort.Slice(replicaSets, func(i, j int) bool {
+		return replicaSetRevision(&replicaSets[i]) < replicaSetRevision(&replicaSets[j])
+	})
+	return deployment, replicaSets, nil
+}
+
+// printHistory prints the revisions of the deployment, the current one marked by "*", like kubectl rollout history.
+func printHistory(ctx context.Context, clientset kubernetes.Interface, namespace string, deploymentName string) error {
+	_, replicaSets, err := getHistory(ctx, clientset, namespace, deploymentName)
+	if err != nil {
+		return err
+	}
+	if len(replicaSets) == 0 {
+		fmt.Printf("No revisions of deployment %v yet.\n", deploymentName)
+		return nil
+	}
+	return writeHistory(os.Stdout, replicaSets)
+}
+
+// writeHistory writes one line per revision, with the images of its pod template and the cause of the change.
+func writeHistory(out io.Writer, replicaSets []appsv1.ReplicaSet) error {
+	current := currentReplicaSet(replicaSets)
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "CURRENT\tREVISION\tIMAGES\tCHANGE-CAUSE\tCREATED")
+	for i := range replicaSets {
+		rs := &replicaSets[i]
+		marker := ""
+		if rs == current {
+			marker = "*"
+		}
+		cause := rs.Annotations[changeCauseAnnotation]
+		if cause == "" {
+			cause = "<none>"
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\n", marker, replicaSetRevision(rs), containerImages(rs.Spec.Template.Spec.Containers), cause,
+			rs.CreationTimestamp.Format("2006-01-02 15:04:05"))
+	}
+	return w.Flush()
+}
+
+// findRevision returns the ReplicaSet of a revision, where revision 0 stands for the one before the current revision.
+func findRevision(deploymentName string, replicaSets []appsv1.ReplicaSet, revision int64) (*appsv1.ReplicaSet, error) {
+	if revision == 0 && len(replicaSets) > 1 {
+		return &replicaSets[len(replicaSets)-2], nil
+	}
+	for i := range replicaSets {
+		if replicaSetRevision(&replicaSets[i]) == revision {
+			return &replicaSets[i], nil
+		}
+	}
+	detail := fmt.Sprintf("deployment %v has no revision %d", deploymentName, revision)
+	if revision == 0 {
+		detail = fmt.Sprintf("deployment %v has no previous revision", deploymentName)
+	}
+	return nil, invalidDeployment(deploymentName, field.ErrorList{field.Invalid(revisionPath, revision, detail)})
+}
+
+// rollbackTemplate is the pod template of the ReplicaSet of a revision as it was on the deployment, that is without
+// the pod-template-hash label the controller adds.
+func rollbackTemplate(rs *appsv1.ReplicaSet) *v1.PodTemplateSpec {
+	template := rs.Spec.Template.DeepCopy()
+	delete(template.Labels, appsv1.DefaultDeploymentUniqueLabelKey)
+	return template
+}
+
+// rollbackDeployment copies the pod template of a revision back onto the deployment, reading it again and retrying
+// when it was modified in the meantime. It returns the deployment before and after the rollback, which are the same
+// when the revision is already the current one.
+func rollbackDeployment(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	namespace string,
+	deploymentName string,
+	revision int64,
+	dryRun bool) (*appsv1.Deployment, *appsv1.Deployment, int64, error) {
+	deploymentsClient := clientset.AppsV1().Deployments(namespace)
+	options := metav1.UpdateOptions{}
+	if dryRun {
+		options.DryRun = []string{metav1.DryRunAll}
+	}
+	var live, result *appsv1.Deployment
+	var target int64
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		deployment, replicaSets, err := getHistory(ctx, clientset, namespace, deploymentName)
+		if err != nil {
+			return err
+		}
+		rs, err := findRevision(deploymentName, replicaSets, revision)
+		if err != nil {
+			return err
+		}
+		live, target = deployment, replicaSetRevision(rs)
+		template := rollbackTemplate(rs)
+		if apiequality.Semantic.DeepEqual(&deployment.Spec.Template, template) {
+			result = live
+			return nil
+		}
+		deployment = deployment.DeepCopy()
+		deployment.Spec.Template = *template
+		if deployment.Annotations == nil {
+			deployment.Annotations = map[string]string{}
+		}
+		deployment.Annotations[changeCauseAnnotation] = fmt.Sprintf("rollback to revision %d", target)
+		result, err = deploymentsClient.Update(ctx, deployment, options)
+		return err
+	})
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("cannot roll back deployment %v in namespace %v: %w", deploymentName, namespace, err)
+	}
+	return live, result, target, nil
+}
+
+// rollbackK8sDeployment rolls the deployment back to a revision of its history, or to the previous one when revision
+// is 0. The controller then rolls out the old pod template as a new revision.
+func rollbackK8sDeployment(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	namespace string,
+	deploymentName string,
+	revision int64) error {
+	live, result, target, err := rollbackDeployment(ctx, clientset, namespace, deploymentName, revision, false)
+	if err != nil {
+		return err
+	}
+	if result == live {
+		log.Printf("Deployment %v already runs the pod template of revision %d, nothing to roll back.",
+			deploymentName, target)
+		return nil
+	}
+	log.Printf("Rolled back deployment %v to revision %d (generation %d).", deploymentName, target, result.Generation)
+	return nil
+}
+
+// previewRollback runs the rollback as a server-side dry run and shows how the live deployment would change.
+func previewRollback(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	namespace string,
+	deploymentName string,
+	revision int64) (string, error) {
+	live, result, _, err := rollbackDeployment(ctx, clientset, namespace, deploymentName, revision, true)
+	if err != nil {
+		return "", err
+	}
+	return diffObjects(live, result, deploymentKind, deploymentName)
+}
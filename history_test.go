@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	appsv1 "k8s.io/api/apps/v1"
+	"strings"
+	"testing"
+)
+
+// seedRevision adds a ReplicaSet of the deployment with the given revision, whose pod template runs the image.
+func seedRevision(server *fakeAPIServer, deployment *appsv1.Deployment, revision string, image string) *appsv1.ReplicaSet {
+	rs := newOwnedReplicaSet(deployment, "demo-"+revision)
+	rs.Annotations = map[string]string{revisionAnnotation: revision, changeCauseAnnotation: "update image to " + image}
+	rs.Spec.Template = *deployment.Spec.Template.DeepCopy()
+	rs.Spec.Template.Labels[appsv1.DefaultDeploymentUniqueLabelKey] = "hash-" + revision
+	rs.Spec.Template.Spec.Containers[0].Image = image
+	server.seed(rs)
+	return rs
+}
+
+func TestHistory(t *testing.T) {
+	clientset, server := newFakeClientset(t, newNamespace("default"))
+	if err := createSampleDeployment(clientset, "demo", "demo"); err != nil {
+		t.Fatalf("Cannot create deployment: %v", err.Error())
+	}
+	deployment := getSampleDeployment(t, server, "demo")
+	seedRevision(server, deployment, "2", "bootcamp:v2")
+	seedRevision(server, deployment, "10", "bootcamp:v10")
+	unrelated := newOwnedReplicaSet(deployment, "demo-adopted")
+	server.seed(unrelated)
+
+	_, replicaSets, err := getHistory(context.TODO(), clientset, "default", "demo")
+	if err != nil {
+		t.Fatalf("Cannot get history: %v", err.Error())
+	}
+	var out bytes.Buffer
+	if err := writeHistory(&out, replicaSets); err != nil {
+		t.Fatalf("Cannot write history: %v", err.Error())
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Lines of history, got:\n%v\nwant a header and 2 revisions.", out.String())
+	}
+	if !strings.HasPrefix(lines[1], " ") || !strings.Contains(lines[1], "bootcamp:v2") {
+		t.Errorf("First revision, got: %q, want revision 2 not marked as current.", lines[1])
+	}
+	if !strings.HasPrefix(lines[2], "*") || !strings.Contains(lines[2], "update image to bootcamp:v10") {
+		t.Errorf("Last revision, got: %q, want revision 10 marked as current.", lines[2])
+	}
+}
+
+func TestRollback(t *testing.T) {
+	clientset, server := newFakeClientset(t, newNamespace("default"))
+	if err := createSampleDeployment(clientset, "demo", "demo"); err != nil {
+		t.Fatalf("Cannot create deployment: %v", err.Error())
+	}
+	deployment := getSampleDeployment(t, server, "demo")
+	seedRevision(server, deployment, "1", "bootcamp:v1")
+	seedRevision(server, deployment, "2", "bootcamp:v2")
+
+	changes, err := previewRollback(context.TODO(), clientset, "default", "demo", 0)
+	if err != nil {
+		t.Fatalf("Cannot preview rollback: %v", err.Error())
+	}
+	if !strings.Contains(changes, "+      - image: bootcamp:v1\n") {
+		t.Errorf("Preview of rollback does not show the image of revision 1, got:\n%v", changes)
+	}
+	if len(server.requestsOf("PUT", "deployments")) != 1 || getSampleDeployment(t, server, "demo").Generation != 1 {
+		t.Errorf("Preview of rollback did not run as a dry run.")
+	}
+
+	if err := rollbackK8sDeployment(context.TODO(), clientset, "default", "demo", 0); err != nil {
+		t.Fatalf("Cannot roll back to the previous revision: %v", err.Error())
+	}
+	rolledBack := getSampleDeployment(t, server, "demo")
+	if got := rolledBack.Spec.Template.Spec.Containers[0].Image; got != "bootcamp:v1" {
+		t.Errorf("Image after rollback, got: %v, want: %v.", got, "bootcamp:v1")
+	}
+	if _, ok := rolledBack.Spec.Template.Labels[appsv1.DefaultDeploymentUniqueLabelKey]; ok {
+		t.Errorf("Pod template after rollback has the label %v of the replica set.", appsv1.DefaultDeploymentUniqueLabelKey)
+	}
+	if got := rolledBack.Annotations[changeCauseAnnotation]; got != "rollback to revision 1" {
+		t.Errorf("Change cause after rollback, got: %q, want: %q.", got, "rollback to revision 1")
+	}
+
+	if err := rollbackK8sDeployment(context.TODO(), clientset, "default", "demo", 1); err != nil {
+		t.Fatalf("Cannot roll back to the current pod template: %v", err.Error())
+	}
+	if got := getSampleDeployment(t, server, "demo").Generation; got != rolledBack.Generation {
+		t.Errorf("Generation after rolling back to the same template, got: %d, want: %d.", got, rolledBack.Generation)
+	}
+
+	err = rollbackK8sDeployment(context.TODO(), clientset, "default", "demo", 7)
+	if exitCodeForError(err) != exitInvalid || !strings.Contains(err.Error(), "no revision 7") {
+		t.Errorf("Error of a rollback to a missing revision, got: %v, want an invalid input.", err)
+	}
+}
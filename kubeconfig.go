@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"github.com/spf13/pflag"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	"time"
+)
+
+// Client-side rate limits of the requests to the API server.
+const (
+	clientQPS   = 50
+	clientBurst = 100
+)
+
+// connectionOptions selects the cluster to connect to and how long to wait for it. Empty fields fall back to the
+// defaults of kubectl: the files listed in KUBECONFIG (merged) or $HOME/.kube/config, their current context, and
+// finally the in-cluster service account when the program runs inside a pod.
+type connectionOptions struct {
+	kubeconfig string
+	context    string
+	cluster    string
+	timeout    time.Duration
+}
+
+func (o *connectionOptions) addFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&o.kubeconfig, "kubeconfig", "", "path to the kubeconfig file (defaults to $KUBECONFIG or $HOME/.kube/config)")
+	flags.StringVar(&o.context, "context", "", "kubeconfig context to use (defaults to the current context)")
+	flags.StringVar(&o.cluster, "cluster", "", "kubeconfig cluster to use (defaults to the cluster of the context)")
+	flags.DurationVar(&o.timeout, "timeout", defaultTimeout, "time limit of the API calls of each command (0 for none)")
+}
+
+func newClientConfig(options connectionOptions) clientcmd.ClientConfig {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = options.kubeconfig
+
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: options.context}
+	overrides.Context.Cluster = options.cluster
+
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides)
+}
+
+func connectToK8s(options connectionOptions) (kubernetes.Interface, error) {
+	return newClientset(newClientConfig(options))
+}
+
+func newClientset(clientConfig clientcmd.ClientConfig) (kubernetes.Interface, error) {
+	config, err := clientConfig.ClientConfig()
+	if err != nil {
+		if clientcmd.IsEmptyConfig(err) {
+			return nil, fmt.Errorf("no kubeconfig found and not running inside a cluster: %w", err)
+		}
+		return nil, fmt.Errorf("failed to create K8s config: %w", err)
+	}
+
+	if config.QPS == 0 {
+		// The defaults of client-go, 5 requests per second in bursts of 10, would throttle the workers listing
+		// namespaces concurrently.
+		config.QPS, config.Burst = clientQPS, clientBurst
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create K8s clientset: %w", err)
+	}
+	return clientset, nil
+}
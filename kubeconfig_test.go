@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"k8s.io/client-go/tools/clientcmd"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeKubeconfig writes a kubeconfig with one context per cluster, each context named after its cluster.
+func writeKubeconfig(t *testing.T, currentContext string, servers map[string]string) string {
+	var clusters, contexts strings.Builder
+	for name, server := range servers {
+		fmt.Fprintf(&clusters, "- name: %s\n  cluster:\n    server: %s\n", name, server)
+		fmt.Fprintf(&contexts, "- name: %s\n  context:\n    cluster: %s\n    user: user\n", name, name)
+	}
+	content := fmt.Sprintf("apiVersion: v1\nkind: Config\ncurrent-context: %q\nclusters:\n%scontexts:\n%s"+
+		"users:\n- name: user\n  user: {}\n", currentContext, clusters.String(), contexts.String())
+
+	path := filepath.Join(t.TempDir(), "config")
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Cannot write kubeconfig: %v", err.Error())
+	}
+	return path
+}
+
+func checkHost(t *testing.T, options connectionOptions, want string) {
+	config, err := newClientConfig(options).ClientConfig()
+	if err != nil {
+		t.Fatalf("Cannot load config with %+v: %v", options, err.Error())
+	}
+	if config.Host != want {
+		t.Errorf("Host with %+v, got: %v, want: %v.", options, config.Host, want)
+	}
+}
+
+// setenv sets the environment variable for the rest of the test, restoring what it was once the test ends.
+func setenv(t *testing.T, key string, value string) {
+	old, had := os.LookupEnv(key)
+	if err := os.Setenv(key, value); err != nil {
+		t.Fatalf("Cannot set %v: %v", key, err)
+	}
+	t.Cleanup(func() {
+		if had {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+}
+
+func TestKubeconfigResolution(t *testing.T) {
+	first := writeKubeconfig(t, "staging", map[string]string{
+		"staging": "https://staging.example.com",
+		"prod":    "https://prod.example.com",
+	})
+	second := writeKubeconfig(t, "", map[string]string{
+		"local": "https://127.0.0.1:6443",
+	})
+
+	checkHost(t, connectionOptions{kubeconfig: first}, "https://staging.example.com")
+	checkHost(t, connectionOptions{kubeconfig: first, context: "prod"}, "https://prod.example.com")
+	checkHost(t, connectionOptions{kubeconfig: first, cluster: "prod"}, "https://prod.example.com")
+
+	setenv(t, clientcmd.RecommendedConfigPathEnvVar, first+string(os.PathListSeparator)+second)
+	checkHost(t, connectionOptions{}, "https://staging.example.com")
+	checkHost(t, connectionOptions{context: "local"}, "https://127.0.0.1:6443")
+	checkHost(t, connectionOptions{kubeconfig: second, context: "local"}, "https://127.0.0.1:6443")
+}
+
+func TestKubeconfigUnknownContext(t *testing.T) {
+	path := writeKubeconfig(t, "staging", map[string]string{"staging": "https://staging.example.com"})
+	if _, err := connectToK8s(connectionOptions{kubeconfig: path, context: "missing"}); err == nil {
+		t.Errorf("Connecting with an unknown context succeeded, want an error.")
+	}
+}
+
+func TestSubcommandsWithKubeconfig(t *testing.T) {
+	_, server := newFakeClientset(t, newNamespace("default"))
+	path := writeKubeconfig(t, "fake", map[string]string{"fake": server.URL})
+
+	create := []string{"create", "--kubeconfig", path, "--name", "demo", "--image", "nginx"}
+	if got := runSubcommand(create); got != exitOK {
+		t.Errorf("Exit code of create, got: %d, want: %d.", got, exitOK)
+	}
+	if server.get("deployments", "default", "demo") == nil {
+		t.Errorf("Deployment demo was not created.")
+	}
+	if got := runSubcommand(create); got != exitAlreadyExists {
+		t.Errorf("Exit code of duplicate create, got: %d, want: %d.", got, exitAlreadyExists)
+	}
+
+	view := []string{"view", "--kubeconfig", path}
+	if got := runSubcommand(view); got != exitOK {
+		t.Errorf("Exit code of view, got: %d, want: %d.", got, exitOK)
+	}
+
+	remove := []string{"delete", "--kubeconfig", path, "--name", "demo"}
+	if got := runSubcommand(remove); got != exitOK {
+		t.Errorf("Exit code of delete, got: %d, want: %d.", got, exitOK)
+	}
+	if got := runSubcommand(remove); got != exitNotFound {
+		t.Errorf("Exit code of deleting a missing deployment, got: %d, want: %d.", got, exitNotFound)
+	}
+}
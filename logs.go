@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"hash/fnv"
+	"io"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// logColors are the ANSI colors of the pod/container prefixes, chosen by a hash of the prefix so that a container
+// keeps its color.
+var logColors = []int{31, 32, 33, 34, 35, 36, 91, 92, 93, 94, 95, 96}
+
+// logOptions tell which lines of the logs to show.
+type logOptions struct {
+	// since and tail limit the lines of the containers running when the logs are asked for, 0 and -1 for all.
+	since time.Duration
+	tail  int64
+	// previous shows the logs of the containers that terminated before the running ones, instead of following.
+	previous bool
+	// filter keeps the lines matching it, all lines when it is nil.
+	filter *regexp.Regexp
+	color  bool
+}
+
+// newLogOptions checks the options of the logs task, where filter is a regular expression or empty for all lines.
+func newLogOptions(since time.Duration, tail int64, filter string, previous bool) (logOptions, error) {
+	options := logOptions{since: since, tail: tail, previous: previous, color: isTerminal(os.Stdout)}
+	if since < 0 {
+		return options, apierrors.NewBadRequest(fmt.Sprintf("since must not be negative, got %v", since))
+	}
+	if tail < -1 {
+		return options, apierrors.NewBadRequest(fmt.Sprintf("tail must be -1 for all lines or more, got %d", tail))
+	}
+	if filter != "" {
+		expression, err := regexp.Compile(filter)
+		if err != nil {
+			return options, apierrors.NewBadRequest(fmt.Sprintf("invalid filter %q: %v", filter, err))
+		}
+		options.filter = expression
+	}
+	return options, nil
+}
+
+// parseSince parses the duration of the since prompt, e.g. "10m", where the empty input stands for the whole log.
+func parseSince(input string) (time.Duration, error) {
+	if input == "" {
+		return 0, nil
+	}
+	since, err := time.ParseDuration(input)
+	if err != nil {
+		return 0, apierrors.NewBadRequest(fmt.Sprintf("invalid duration %q, e.g. 30s or 10m", input))
+	}
+	return since, nil
+}
+
+// parseTail parses the number of lines of the tail prompt, where the empty input stands for all lines.
+func parseTail(input string) (int64, error) {
+	if input == "" {
+		return -1, nil
+	}
+	tail, err := strconv.ParseInt(input, 10, 64)
+	if err != nil {
+		return 0, apierrors.NewBadRequest(fmt.Sprintf("invalid number of lines %q", input))
+	}
+	return tail, nil
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// logStream is the log of one instance of a container, which a restart replaces by a new one.
+type logStream struct {
+	pod       string
+	container string
+	// id identifies the instance of the container.
+	id string
+}
+
+func (s logStream) prefix(color bool) string {
+	prefix := s.pod + "/" + s.container
+	if !color {
+		return "[" + prefix + "] "
+	}
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(prefix))
+	return fmt.Sprintf("\x1b[%dm[%s]\x1b[0m ", logColors[hash.Sum32()%uint32(len(logColors))], prefix)
+}
+
+// logStreamEnd tells that a stream ended, with the error that ended it if any.
+type logStreamEnd struct {
+	stream logStream
+	err    error
+}
+
+// streamDeploymentLogs writes the logs of every container of the pods the deployment selects, each line prefixed by
+// its pod and container. It follows the logs until the context is done, starting to follow the containers of new
+// pods, and of restarted containers, as they start. With options.previous, it writes the logs of the previous
+// instances of the containers instead and returns.
+func streamDeploymentLogs(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	namespace string,
+	deploymentName string,
+	options logOptions,
+	out io.Writer) error {
+	deployment, err := clientset.AppsV1().Deployments(namespace).Get(ctx, deploymentName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("cannot get deployment %v in namespace %v: %w", deploymentName, namespace, err)
+	}
+	podOptions, err := selectorListOptions(deployment.Spec.Selector)
+	if err != nil {
+		return fmt.Errorf("invalid selector of deployment %v: %w", deploymentName, err)
+	}
+	podsClient := clientset.CoreV1().Pods(namespace)
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	lines := make(chan string)
+	ended := make(chan logStreamEnd)
+	started := map[logStream]bool{}
+	active := 0
+	follow := func(pod *v1.Pod, initial bool) {
+		for _, stream := range podLogStreams(pod, options.previous) {
+			if started[stream] {
+				continue
+			}
+			started[stream] = true
+			active++
+			podLogOptions := &v1.PodLogOptions{Container: stream.container, Follow: !options.previous,
+				Previous: options.previous}
+			if initial && options.since > 0 {
+				seconds := int64(options.since.Seconds())
+				podLogOptions.SinceSeconds = &seconds
+			}
+			if initial && options.tail >= 0 {
+				podLogOptions.TailLines = &options.tail
+			}
+			request := podsClient.GetLogs(stream.pod, podLogOptions)
+			wg.Add(1)
+			go func(stream logStream) {
+				defer wg.Done()
+				err := streamLog(ctx, request.Stream, stream.prefix(options.color), options.filter, lines)
+				select {
+				case ended <- logStreamEnd{stream: stream, err: err}:
+				case <-ctx.Done():
+				}
+			}(stream)
+		}
+	}
+
+	list, err := podsClient.List(ctx, podOptions)
+	if err != nil {
+		return fmt.Errorf("cannot get pods of deployment %v: %w", deploymentName, err)
+	}
+	for i := range list.Items {
+		follow(&list.Items[i], true)
+	}
+	if active == 0 && options.previous {
+		log.Printf("No container of deployment %v has restarted, there are no previous logs.", deploymentName)
+		return nil
+	} else if active == 0 {
+		log.Printf("No container of deployment %v is running yet, waiting for one to start.", deploymentName)
+	}
+
+	watchPods := func(resourceVersion string) (watch.Interface, error) {
+		watchOptions := podOptions
+		watchOptions.ResourceVersion = resourceVersion
+		return podsClient.Watch(ctx, watchOptions)
+	}
+	// The logs of previous containers do not grow, so there are no pods to wait for.
+	var podWatch watch.Interface
+	var events <-chan watch.Event
+	if !options.previous {
+		if podWatch, err = watchPods(list.ResourceVersion); err != nil {
+			return fmt.Errorf("cannot watch pods of deployment %v: %w", deploymentName, err)
+		}
+		defer func() { podWatch.Stop() }()
+		events = podWatch.ResultChan()
+	}
+
+	// The pod watch is restarted after a growing delay, from the start again once it delivers an event.
+	podBackoff := watchRestartBackoff
+	for {
+		select {
+		case line := <-lines:
+			fmt.Fprintln(out, line)
+		case end := <-ended:
+			active--
+			if end.err != nil {
+				log.Printf("Stopped following container %v of pod %v: %v", end.stream.container, end.stream.pod, end.err)
+			}
+			if active == 0 && options.previous {
+				return nil
+			}
+		case event, ok := <-events:
+			if !ok || event.Type == watch.Error {
+				// The API server ends watches after a while, list the pods again to catch up with what was missed.
+				podWatch.Stop()
+				if err := waitForBackoff(ctx, &podBackoff); err != nil {
+					return nil
+				}
+				if list, err = podsClient.List(ctx, podOptions); err != nil {
+					return fmt.Errorf("cannot get pods of deployment %v: %w", deploymentName, err)
+				}
+				for i := range list.Items {
+					follow(&list.Items[i], false)
+				}
+				if podWatch, err = watchPods(list.ResourceVersion); err != nil {
+					return fmt.Errorf("cannot watch pods of deployment %v: %w", deploymentName, err)
+				}
+				events = podWatch.ResultChan()
+				continue
+			}
+			podBackoff = watchRestartBackoff
+			if pod, ok := event.Object.(*v1.Pod); ok && event.Type != watch.Deleted {
+				follow(pod, false)
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// podLogStreams returns the logs of the containers of the pod that can be streamed: those of the containers that
+// started, or of the containers that terminated before them with previous.
+func podLogStreams(pod *v1.Pod, previous bool) []logStream {
+	var streams []logStream
+	for _, c := range pod.Status.ContainerStatuses {
+		if previous && c.LastTerminationState.Terminated != nil {
+			streams = append(streams, logStream{pod: pod.Name, container: c.Name,
+				id: c.LastTerminationState.Terminated.ContainerID})
+		} else if !previous && (c.State.Running != nil || c.State.Terminated != nil) {
+			streams = append(streams, logStream{pod: pod.Name, container: c.Name, id: c.ContainerID})
+		}
+	}
+	return streams
+}
+
+// streamLog sends the lines of a log that match the filter to lines, each one behind the prefix, until the log ends
+// or the context is done.
+func streamLog(
+	ctx context.Context,
+	open func(ctx context.Context) (io.ReadCloser, error),
+	prefix string,
+	filter *regexp.Regexp,
+	lines chan<- string) error {
+	stream, err := open(ctx)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+	reader := bufio.NewReader(stream)
+	for {
+		line, err := reader.ReadString('\n')
+		line = strings.TrimRight(line, "\r\n")
+		if line != "" && (filter == nil || filter.MatchString(line)) {
+			select {
+			case lines <- prefix + line:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+		if err == io.EOF || ctx.Err() != nil {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"log"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newLoggingDeployment(name string) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": name}},
+			Template: v1.PodTemplateSpec{Spec: v1.PodSpec{Containers: []v1.Container{{Name: "web"}}}},
+		},
+	}
+}
+
+// newLoggingPod returns a running pod of the app, whose container web has the ID.
+func newLoggingPod(app string, name string, containerID string) *v1.Pod {
+	pod := newRunningPod("default", name)
+	pod.Labels = map[string]string{"app": app}
+	pod.Status.ContainerStatuses[0].ContainerID = containerID
+	return pod
+}
+
+func TestStreamDeploymentLogs(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	other := newLoggingPod("api", "api-0", "containerd://api-0")
+	clientset, server := newFakeClientset(t, newNamespace("default"), newLoggingDeployment("web"),
+		newLoggingPod("web", "web-0", "containerd://web-0"), other)
+	server.log("default", "web-0", "web", false, "GET /healthz 200", "GET /orders 500", "GET /orders 200")
+	server.log("default", "api-0", "web", false, "GET /orders 500")
+
+	options, err := newLogOptions(10*time.Minute, 2, "orders", false)
+	if err != nil {
+		t.Fatalf("Cannot check the log options: %v", err)
+	}
+	out := &lineWriter{lines: make(chan string, 100)}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- streamDeploymentLogs(ctx, clientset, "default", "web", options, out)
+	}()
+
+	expectLine(t, out.lines, "[web-0/web] GET /orders 500")
+	expectLine(t, out.lines, "[web-0/web] GET /orders 200")
+	server.log("default", "web-0", "web", false, "GET /healthz 200", "GET /orders/1 200")
+	expectLine(t, out.lines, "[web-0/web] GET /orders/1 200")
+
+	// A pod that starts during a rollout is followed from the start of its log.
+	server.waitForWatches("pods", 1)
+	server.log("default", "web-1", "web", false, "GET /orders/2 200", "GET /orders/3 200", "GET /orders/4 200")
+	server.seed(newLoggingPod("web", "web-1", "containerd://web-1"))
+	expectLine(t, out.lines, "[web-1/web] GET /orders/2 200")
+	expectLine(t, out.lines, "[web-1/web] GET /orders/4 200")
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Error of logs followed until the user stopped, got: %v, want: nil.", err)
+	}
+	close(out.lines)
+	for line := range out.lines {
+		if strings.Contains(line, "healthz") || strings.Contains(line, "api-0") {
+			t.Errorf("Logs contain a line that is filtered out or not of the deployment: %q", line)
+		}
+	}
+
+	requests := server.requestsOf("GET", "pods/log")
+	if len(requests) != 2 {
+		t.Fatalf("Requests of logs, got: %d, want: %d.", len(requests), 2)
+	}
+	for _, r := range requests {
+		query := url.Values(r.query)
+		if query.Get("follow") != "true" || query.Get("container") != "web" {
+			t.Errorf("Query of the log of %v, got: %v, want to follow container web.", r.name, r.query)
+		}
+		// The since and tail options are about the logs already there, not of the pods started later.
+		initial := r.name == "web-0"
+		if got := query.Get("tailLines") == "2" && query.Get("sinceSeconds") == "600"; got != initial {
+			t.Errorf("Query of the log of %v, got: %v, want since and tail: %t.", r.name, r.query, initial)
+		}
+	}
+}
+
+func TestStreamDeploymentLogsBacksOffDroppedWatches(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	clientset, server := newFakeClientset(t, newNamespace("default"), newLoggingDeployment("web"),
+		newLoggingPod("web", "web-0", "containerd://web-0"))
+	server.log("default", "web-0", "web", false, "GET /orders 200")
+	options, err := newLogOptions(0, -1, "", false)
+	if err != nil {
+		t.Fatalf("Cannot check the log options: %v", err)
+	}
+	out := &lineWriter{lines: make(chan string, 100)}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go streamDeploymentLogs(ctx, clientset, "default", "web", options, out)
+	expectLine(t, out.lines, "[web-0/web] GET /orders 200")
+	server.waitForWatches("pods", 1)
+
+	// A watch the API server ends is not listed again right away, but the pods started meanwhile are followed.
+	server.endWatches("pods")
+	time.Sleep(100 * time.Millisecond)
+	if lists := len(server.requestsOf("GET", "pods")); lists != 1 {
+		t.Errorf("Lists of pods right after the watch was dropped, got: %d, want: 1.", lists)
+	}
+	server.log("default", "web-1", "web", false, "GET /orders/1 200")
+	server.seed(newLoggingPod("web", "web-1", "containerd://web-1"))
+	expectLine(t, out.lines, "[web-1/web] GET /orders/1 200")
+}
+
+func TestStreamPreviousLogs(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	restarted := newLoggingPod("web", "web-0", "containerd://web-0-b")
+	restarted.Status.ContainerStatuses[0].LastTerminationState.Terminated = &v1.ContainerStateTerminated{
+		ExitCode:    1,
+		ContainerID: "containerd://web-0-a",
+	}
+	clientset, server := newFakeClientset(t, newNamespace("default"), newLoggingDeployment("web"), restarted,
+		newLoggingPod("web", "web-1", "containerd://web-1"))
+	server.log("default", "web-0", "web", true, "panic: out of orders")
+
+	options, err := newLogOptions(0, -1, "", true)
+	if err != nil {
+		t.Fatalf("Cannot check the log options: %v", err)
+	}
+	options.color = true
+	var out bytes.Buffer
+	if err := streamDeploymentLogs(context.TODO(), clientset, "default", "web", options, &out); err != nil {
+		t.Fatalf("Cannot get the previous logs: %v", err)
+	}
+	if !regexp.MustCompile(`^\x1b\[\d+m\[web-0/web\]\x1b\[0m panic: out of orders\n$`).MatchString(out.String()) {
+		t.Errorf("Previous logs, got: %q, want the line of web-0 behind a colored prefix.", out.String())
+	}
+	for _, r := range server.requestsOf("GET", "pods/log") {
+		if query := url.Values(r.query); query.Get("previous") != "true" || query.Get("follow") == "true" {
+			t.Errorf("Query of a previous log, got: %v, want previous without follow.", r.query)
+		}
+	}
+	if len(server.requestsOf("WATCH", "pods")) != 0 {
+		t.Errorf("Previous logs watched the pods, want them to return once the logs are written.")
+	}
+
+	server.remove("pods", "default", "web-0")
+	out.Reset()
+	if err := streamDeploymentLogs(context.TODO(), clientset, "default", "web", options, &out); err != nil {
+		t.Fatalf("Cannot get the previous logs: %v", err)
+	}
+	if out.Len() != 0 || !strings.Contains(logs.String(), "has restarted") {
+		t.Errorf("Previous logs without restarts, got: %q, logs: %q, want none and a message.", out.String(), logs.String())
+	}
+}
+
+func TestNewLogOptions(t *testing.T) {
+	tests := []struct {
+		since  string
+		tail   string
+		filter string
+		valid  bool
+	}{
+		{"", "", "", true},
+		{"10m", "100", "error|warn", true},
+		{"ten minutes", "", "", false},
+		{"", "-5", "", false},
+		{"", "lots", "", false},
+		{"-1m", "", "", false},
+		{"", "", "(unclosed", false},
+	}
+	for _, test := range tests {
+		since, err := parseSince(test.since)
+		var tail int64
+		if err == nil {
+			tail, err = parseTail(test.tail)
+		}
+		if err == nil {
+			_, err = newLogOptions(since, tail, test.filter, false)
+		}
+		if valid := err == nil; valid != test.valid {
+			t.Errorf("Log options since %q, tail %q, filter %q, got error: %v, want valid: %t.",
+				test.since, test.tail, test.filter, err, test.valid)
+		}
+	}
+}
@@ -7,56 +7,323 @@ import (
 	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/clientcmd"
 	"log"
 	"os"
-	"path/filepath"
+	"strings"
+	"time"
 )
 
 // reference: https://dev.to/narasimha1997/create-kubernetes-jobs-in-golang-using-k8s-client-go-api-59ej
 
 func main() {
+	args := os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		os.Exit(runSubcommand(args))
+	}
+
+	options, ok, code := parseInteractiveFlags(args)
+	if !ok {
+		os.Exit(code)
+	}
+	s, err := newSession(options)
+	if err != nil {
+		reportError(err)
+		os.Exit(exitCodeForError(err))
+	}
+
 	stdReader := bufio.NewReader(os.Stdin)
-	clientset := connectToK8s()
 	for {
-		handleK8sCommand(stdReader, clientset)
+		handleK8sCommand(stdReader, s)
 	}
 }
 
-func handleK8sCommand(reader *bufio.Reader, clientset *kubernetes.Clientset) {
-	fmt.Print("Task (view, create, or delete): ")
+func handleK8sCommand(reader *bufio.Reader, s *session) {
+	fmt.Printf("%s Task (view, api-resources, create, apply, update, describe, diagnose, tree, nodes, logs, history, "+
+		"rollback, scale, pause, resume, restart, create-from-file, delete, contexts, or use-context): ", s.prompt())
 	task := readInput(reader)
+	clientset := s.clientset
+	var err error
 	if task == "view" {
-		printNamespaces(clientset)
-		fmt.Print("Namespace (empty for all): ")
-		namespace := readInput(reader)
-		getPods(clientset, namespace)
+		fmt.Print("Kind, e.g. deployments, svc or nodes (empty for pods): ")
+		kind := readInput(reader)
+		resource := podsResource
+		if kind != "" {
+			err = s.run(func(ctx context.Context) error {
+				var err error
+				resource, err = resolveResource(clientset.Discovery(), kind)
+				return err
+			})
+		}
+		if err == nil {
+			namespace := ""
+			if resource.namespaced {
+				s.printNamespaces()
+				fmt.Print("Namespace (empty for all): ")
+				namespace = readInput(reader)
+			}
+			fmt.Print("Label selector, e.g. app=demo,tier!=db (empty for all): ")
+			labelSelector := readInput(reader)
+			fmt.Print("Field selector, e.g. status.phase=Running (empty for all): ")
+			fieldSelector := readInput(reader)
+			fmt.Print("Output format (empty for a table, or wide, json, yaml, name, jsonpath=..., custom-columns=...): ")
+			format := readInput(reader)
+			watchChanges := false
+			if resource.isPods() {
+				fmt.Print("Keep watching for changes until Ctrl-C (y/N): ")
+				watchChanges = readYesNo(reader)
+			}
+			var options metav1.ListOptions
+			if options, err = resource.listOptions(labelSelector, fieldSelector); err == nil && watchChanges {
+				// A watch is meant to stay open, so only Ctrl-C ends it.
+				err = runCancellable(0, func(ctx context.Context) error {
+					return watchPods(ctx, clientset, namespace, options, os.Stdout, format)
+				})
+			} else if err == nil {
+				err = s.run(func(ctx context.Context) error {
+					return viewResources(ctx, clientset, resource, namespace, options, os.Stdout, format)
+				})
+			}
+		}
+	} else if task == "api-resources" {
+		err = s.run(func(ctx context.Context) error {
+			types, err := getResourceTypes(clientset.Discovery())
+			if err != nil {
+				return err
+			}
+			return printResourceTypes(os.Stdout, types)
+		})
 	} else if task == "create" {
-		printNamespaces(clientset)
-		fmt.Print("Namespace: ")
+		var input deploymentInput
+		var confirmed bool
+		if input, err = readDeploymentInput(reader, s); err == nil {
+			confirmed, err = previewAndConfirm(reader, s, "Create deployment "+input.deploymentName,
+				func(ctx context.Context) (string, error) {
+					return previewCreate(ctx, clientset, input)
+				})
+		}
+		if confirmed {
+			fmt.Print("Wait for the rollout to complete (y/N): ")
+			wait := readYesNo(reader)
+			err = s.run(func(ctx context.Context) error {
+				if err := launchK8sDeployment(ctx, clientset, input); err != nil || !wait {
+					return err
+				}
+				return waitForRollout(ctx, clientset, input.targetNamespace(), input.deploymentName)
+			})
+		}
+	} else if task == "apply" {
+		var input deploymentInput
+		if input, err = readDeploymentInput(reader, s); err == nil {
+			fmt.Print("Take over fields owned by other managers (y/N): ")
+			force := readYesNo(reader)
+			var confirmed bool
+			confirmed, err = previewAndConfirm(reader, s, "Apply deployment "+input.deploymentName,
+				func(ctx context.Context) (string, error) {
+					return previewApply(ctx, clientset, input, force)
+				})
+			if confirmed {
+				fmt.Print("Wait for the rollout to complete (y/N): ")
+				wait := readYesNo(reader)
+				err = s.run(func(ctx context.Context) error {
+					if err := applyK8sDeployment(ctx, clientset, input, force); err != nil || !wait {
+						return err
+					}
+					return waitForRollout(ctx, clientset, input.targetNamespace(), input.deploymentName)
+				})
+			}
+		}
+	} else if task == "update" {
+		var input updateInput
+		var confirmed bool
+		if input, err = readUpdateInput(reader, s); err == nil {
+			confirmed, err = previewAndConfirm(reader, s, "Update deployment "+input.deploymentName,
+				func(ctx context.Context) (string, error) {
+					return previewUpdate(ctx, clientset, input)
+				})
+		}
+		if confirmed {
+			err = s.run(func(ctx context.Context) error {
+				if err := updateK8sDeployment(ctx, clientset, input); err != nil {
+					return err
+				}
+				return waitForRollout(ctx, clientset, input.targetNamespace(), input.deploymentName)
+			})
+		}
+	} else if task == "describe" {
+		namespace, deploymentName := readDeploymentName(reader, s)
+		err = s.run(func(ctx context.Context) error {
+			return describeDeployment(ctx, clientset, namespace, deploymentName, os.Stdout)
+		})
+	} else if task == "diagnose" {
+		s.printNamespaces()
+		fmt.Print("Namespace (empty for all): ")
 		namespace := readInput(reader)
-		fmt.Print("App name: ")
-		appName := readInput(reader)
-		fmt.Print("Deployment name: ")
-		deploymentName := readInput(reader)
-		fmt.Print("Container name: ")
-		containerName := readInput(reader)
-		fmt.Print("Container image: ")
-		image := readInput(reader)
-		launchK8sDeployment(clientset, namespace, appName, deploymentName, containerName, image)
+		fmt.Print("Label selector, e.g. app=demo,tier!=db (empty for all): ")
+		var options metav1.ListOptions
+		if options, err = podListOptions(readInput(reader), ""); err == nil {
+			err = s.run(func(ctx context.Context) error {
+				return diagnosePods(ctx, clientset, namespace, options, os.Stdout)
+			})
+		}
+	} else if task == "tree" {
+		fmt.Print("Kind, e.g. deploy, sts, job, cronjob or svc: ")
+		kind := readInput(reader)
+		s.printNamespaces()
+		fmt.Printf("Namespace (empty for %v): ", s.namespace)
+		namespace := readInputOrDefault(reader, s.namespace)
+		fmt.Print("Name: ")
+		name := readInput(reader)
+		err = s.run(func(ctx context.Context) error {
+			resource, err := resolveResource(clientset.Discovery(), kind)
+			if err != nil {
+				return err
+			}
+			return printTree(ctx, clientset, resource, namespace, name, os.Stdout)
+		})
+	} else if task == "nodes" {
+		fmt.Print("Show on which nodes the pods of each deployment run (y/N): ")
+		placement := readYesNo(reader)
+		namespace := ""
+		if placement {
+			s.printNamespaces()
+			fmt.Print("Namespace of the deployments (empty for all): ")
+			namespace = readInput(reader)
+		}
+		err = s.run(func(ctx context.Context) error {
+			return printNodes(ctx, clientset, placement, namespace, os.Stdout)
+		})
+	} else if task == "logs" {
+		namespace, deploymentName := readDeploymentName(reader, s)
+		fmt.Print("Only lines matching the regular expression (empty for all): ")
+		filter := readInput(reader)
+		fmt.Print("Since, e.g. 10m (empty for the whole log): ")
+		var since time.Duration
+		var tail int64
+		if since, err = parseSince(readInput(reader)); err == nil {
+			fmt.Print("Lines to show from the end of each log (empty for all): ")
+			tail, err = parseTail(readInput(reader))
+		}
+		if err == nil {
+			fmt.Print("Logs of the previous containers instead (y/N): ")
+			previous := readYesNo(reader)
+			var options logOptions
+			if options, err = newLogOptions(since, tail, filter, previous); err == nil && previous {
+				err = s.run(func(ctx context.Context) error {
+					return streamDeploymentLogs(ctx, clientset, namespace, deploymentName, options, os.Stdout)
+				})
+			} else if err == nil {
+				// Logs are followed until Ctrl-C.
+				err = runCancellable(0, func(ctx context.Context) error {
+					return streamDeploymentLogs(ctx, clientset, namespace, deploymentName, options, os.Stdout)
+				})
+			}
+		}
+	} else if task == "history" {
+		namespace, deploymentName := readDeploymentName(reader, s)
+		err = s.run(func(ctx context.Context) error {
+			return printHistory(ctx, clientset, namespace, deploymentName)
+		})
+	} else if task == "rollback" {
+		namespace, deploymentName := readDeploymentName(reader, s)
+		err = s.run(func(ctx context.Context) error {
+			return printHistory(ctx, clientset, namespace, deploymentName)
+		})
+		var revision int64
+		var confirmed bool
+		if err == nil {
+			fmt.Print("Revision to roll back to (empty for the previous one): ")
+			var errs field.ErrorList
+			if revision, errs = parseRevision(readInput(reader)); len(errs) > 0 {
+				err = invalidDeployment(deploymentName, errs)
+			}
+		}
+		if err == nil {
+			confirmed, err = previewAndConfirm(reader, s, "Roll back deployment "+deploymentName,
+				func(ctx context.Context) (string, error) {
+					return previewRollback(ctx, clientset, namespace, deploymentName, revision)
+				})
+		}
+		if confirmed {
+			fmt.Print("Wait for the rollout to complete (y/N): ")
+			wait := readYesNo(reader)
+			err = s.run(func(ctx context.Context) error {
+				if err := rollbackK8sDeployment(ctx, clientset, namespace, deploymentName, revision); err != nil || !wait {
+					return err
+				}
+				return waitForRollout(ctx, clientset, namespace, deploymentName)
+			})
+		}
+	} else if task == "scale" {
+		namespace, deploymentName := readDeploymentName(reader, s)
+		fmt.Print("Replicas: ")
+		replicas, errs := parseScaleReplicas(readInput(reader))
+		if len(errs) > 0 {
+			err = invalidDeployment(deploymentName, errs)
+		} else {
+			wait := readWaitUntilSettled(reader)
+			err = s.run(func(ctx context.Context) error {
+				return scaleK8sDeployment(ctx, clientset, namespace, deploymentName, replicas, wait)
+			})
+		}
+	} else if task == "pause" || task == "resume" {
+		namespace, deploymentName := readDeploymentName(reader, s)
+		wait := readWaitUntilSettled(reader)
+		err = s.run(func(ctx context.Context) error {
+			return pauseK8sDeployment(ctx, clientset, namespace, deploymentName, task == "pause", wait)
+		})
+	} else if task == "restart" {
+		namespace, deploymentName := readDeploymentName(reader, s)
+		wait := readWaitUntilSettled(reader)
+		err = s.run(func(ctx context.Context) error {
+			return restartK8sDeployment(ctx, clientset, namespace, deploymentName, wait)
+		})
+	} else if task == "create-from-file" {
+		fmt.Print("Manifest file: ")
+		// The prompt reads its tasks from stdin, so the manifest cannot be read from there until its end.
+		if filename := readInput(reader); filename == "-" {
+			err = field.Invalid(field.NewPath("filename"), filename, "stdin is read by the prompt, give the path of a file")
+		} else {
+			fmt.Printf("Namespace of objects without one (empty for %v): ", s.namespace)
+			namespace := readInputOrDefault(reader, s.namespace)
+			err = s.run(func(ctx context.Context) error {
+				return createFromManifest(ctx, clientset, filename, namespace)
+			})
+		}
 	} else if task == "delete" {
-		printNamespaces(clientset)
-		fmt.Print("Namespace: ")
-		namespace := readInput(reader)
-		fmt.Print("Deployment name: ")
-		deploymentName := readInput(reader)
-		deleteK8sDeployment(clientset, namespace, deploymentName)
+		namespace, deploymentName := readDeploymentName(reader, s)
+		var confirmed bool
+		confirmed, err = previewAndConfirm(reader, s, "Delete deployment "+deploymentName,
+			func(ctx context.Context) (string, error) {
+				return previewDelete(ctx, clientset, namespace, deploymentName)
+			})
+		if confirmed {
+			fmt.Print("Wait until its pods are gone (y/N): ")
+			wait := readYesNo(reader)
+			err = s.run(func(ctx context.Context) error {
+				if wait {
+					return deleteK8sDeploymentAndWait(ctx, clientset, namespace, deploymentName)
+				}
+				return deleteK8sDeployment(ctx, clientset, namespace, deploymentName)
+			})
+		}
+	} else if task == "contexts" {
+		err = s.printContexts()
+	} else if task == "use-context" {
+		fmt.Print("Context: ")
+		contextName := readInput(reader)
+		if err = s.useContext(contextName); err == nil {
+			log.Printf("Switched to context %v.", s.context)
+		}
 	} else if task == "exit" {
 		os.Exit(0)
 	} else {
 		log.Printf("Invalid task type.")
 	}
+	if err != nil {
+		reportError(err)
+	}
 }
 
 func readInput(reader *bufio.Reader) string {
@@ -67,60 +334,176 @@ func readInput(reader *bufio.Reader) string {
 	return result[:(len(result) - 1)]
 }
 
-func connectToK8s() *kubernetes.Clientset {
-	home, exists := os.LookupEnv("HOME")
-	if !exists {
-		home = "/root"
+// readDeploymentInput asks for the fields of the deployment to create or apply.
+func readDeploymentInput(reader *bufio.Reader, s *session) (deploymentInput, error) {
+	var input deploymentInput
+	s.printNamespaces()
+	fmt.Printf("Namespace (empty for %v): ", s.namespace)
+	input.namespace = readInputOrDefault(reader, s.namespace)
+	fmt.Print("App name: ")
+	input.appName = readInput(reader)
+	fmt.Print("Deployment name: ")
+	input.deploymentName = readInput(reader)
+	fmt.Print("Container name: ")
+	input.containerName = readInput(reader)
+	fmt.Print("Container image: ")
+	input.image = readInput(reader)
+	fmt.Printf("Replicas (empty for %d): ", defaultReplicas)
+	replicas, errs := parseReplicas(readInput(reader))
+	input.replicas = replicas
+	fmt.Printf("Ports as name:port/protocol, comma separated (empty for %v): ", defaultPorts)
+	ports, portErrs := parsePorts([]string{readInputOrDefault(reader, defaultPorts)})
+	input.ports = ports
+	if errs = append(errs, portErrs...); len(errs) > 0 {
+		return input, invalidDeployment(input.deploymentName, errs)
 	}
+	return input, nil
+}
 
-	configPath := filepath.Join(home, ".kube", "config")
+// readDeploymentName asks for the namespace and the name of an existing deployment.
+func readDeploymentName(reader *bufio.Reader, s *session) (string, string) {
+	s.printNamespaces()
+	fmt.Printf("Namespace (empty for %v): ", s.namespace)
+	namespace := readInputOrDefault(reader, s.namespace)
+	fmt.Print("Deployment name: ")
+	return namespace, readInput(reader)
+}
 
-	config, err := clientcmd.BuildConfigFromFlags("", configPath)
-	if err != nil {
-		log.Panicln("failed to create K8s config")
+func readWaitUntilSettled(reader *bufio.Reader) bool {
+	fmt.Print("Wait until the deployment settles (y/N): ")
+	return readYesNo(reader)
+}
+
+// readUpdateInput asks for the deployment to update and the changes to make to its container.
+func readUpdateInput(reader *bufio.Reader, s *session) (updateInput, error) {
+	var input updateInput
+	input.namespace, input.deploymentName = readDeploymentName(reader, s)
+	fmt.Print("Container name (empty for the only container): ")
+	input.containerName = readInput(reader)
+	fmt.Print("New container image (empty to keep it): ")
+	input.image = readInput(reader)
+	fmt.Print("Env variables as NAME=value, comma separated (empty for none): ")
+	env, errs := parseEnv([]string{readInput(reader)})
+	input.env = env
+	fmt.Print("Resource requests as name=quantity, e.g. cpu=250m,memory=128Mi (empty for none): ")
+	requests, requestErrs := parseResources([]string{readInput(reader)}, requestsPath)
+	input.requests = requests
+	fmt.Print("Resource limits as name=quantity, comma separated (empty for none): ")
+	limits, limitErrs := parseResources([]string{readInput(reader)}, limitsPath)
+	input.limits = limits
+	if errs = append(append(errs, requestErrs...), limitErrs...); len(errs) > 0 {
+		return input, invalidDeployment(input.deploymentName, errs)
 	}
+	return input, nil
+}
+
+// readYesNo reads the answer to a yes/no question, where anything but "y" or "yes" means no.
+func readYesNo(reader *bufio.Reader) bool {
+	answer := strings.ToLower(strings.TrimSpace(readInput(reader)))
+	return answer == "y" || answer == "yes"
+}
 
-	clientset, err := kubernetes.NewForConfig(config)
+// previewAndConfirm offers to preview what a mutating task would change and, once the preview is shown, asks
+// whether to go ahead. It returns false when the task should not run.
+func previewAndConfirm(
+	reader *bufio.Reader,
+	s *session,
+	action string,
+	preview func(ctx context.Context) (string, error)) (bool, error) {
+	fmt.Print("Preview the changes with a dry run first (y/N): ")
+	if !readYesNo(reader) {
+		return true, nil
+	}
+	var changes string
+	err := s.run(func(ctx context.Context) (err error) {
+		changes, err = preview(ctx)
+		return err
+	})
 	if err != nil {
-		log.Panicln("Failed to create K8s clientset")
+		return false, err
+	}
+	fmt.Print(changes)
+	fmt.Printf("%v (y/N): ", action)
+	if !readYesNo(reader) {
+		log.Printf("Cancelled, nothing was changed.")
+		return false, nil
 	}
+	return true, nil
+}
 
-	return clientset
+func readInputOrDefault(reader *bufio.Reader, defaultValue string) string {
+	result := readInput(reader)
+	if result == "" {
+		return defaultValue
+	}
+	return result
 }
 
-func printNamespaces(clientset *kubernetes.Clientset) {
+func printNamespaces(ctx context.Context, clientset kubernetes.Interface) {
+	namespaces, err := getNamespaces(ctx, clientset)
+	if err != nil {
+		log.Printf("Cannot list existing namespaces: %v", err)
+		return
+	}
 	fmt.Print("Existing namespaces: ")
-	namespaces := getNamespaces(clientset)
 	for _, ns := range namespaces {
 		fmt.Print(ns.Name, " ")
 	}
 	fmt.Println()
 }
 
-func getNamespaces(clientset *kubernetes.Clientset) []v1.Namespace {
-	namespaces, err := clientset.CoreV1().Namespaces().List(context.TODO(), metav1.ListOptions{})
+func getNamespaces(ctx context.Context, clientset kubernetes.Interface) ([]v1.Namespace, error) {
+	namespaces, err := clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
 	if err != nil {
-		log.Fatalf("Cannot get list of namespaces: %v", err.Error())
+		return nil, fmt.Errorf("cannot get list of namespaces: %w", err)
 	}
-	return namespaces.Items
+	return namespaces.Items, nil
+}
+
+// deploymentInput holds the fields of a deployment asked for by the create task.
+type deploymentInput struct {
+	namespace      string
+	appName        string
+	deploymentName string
+	containerName  string
+	image          string
+	replicas       int32
+	ports          []v1.ContainerPort
+}
+
+// targetNamespace is the namespace of the deployment, "default" unless the input says otherwise.
+func (in deploymentInput) targetNamespace() string {
+	if in.namespace == "" {
+		return "default"
+	}
+	return in.namespace
 }
 
-// https://github.com/kubernetes/client-go/blob/master/examples/create-update-delete-deployment/main.go
 func launchK8sDeployment(
-	clientset *kubernetes.Clientset,
-	namespace string,
-	appName string,
-	deploymentName string,
-	containerName string,
-	image string) {
-	if namespace == "" {
-		namespace = "default"
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	input deploymentInput) error {
+	namespace := input.targetNamespace()
+	if errs := input.validate(); len(errs) > 0 {
+		return invalidDeployment(input.deploymentName, errs)
 	}
-	var numOfReplicas int32 = 4
 
 	deploymentsClient := clientset.AppsV1().Deployments(namespace)
+	result, err := deploymentsClient.Create(ctx, newK8sDeployment(input), metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("cannot create deployment %v in namespace %v: %w", input.deploymentName, namespace, err)
+	}
+	log.Printf("Created deployment %v.", result.GetObjectMeta().GetName())
+	return nil
+}
+
+// newK8sDeployment builds the deployment described by the input of the create task, after the example of
+// https://github.com/kubernetes/client-go/blob/master/examples/create-update-delete-deployment/main.go
+func newK8sDeployment(input deploymentInput) *appsv1.Deployment {
+	deploymentName, appName := input.deploymentName, input.appName
+	numOfReplicas := input.replicas
 
-	deployment := &appsv1.Deployment{
+	return &appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
 			Name: deploymentName,
 		},
@@ -140,63 +523,58 @@ func launchK8sDeployment(
 				Spec: v1.PodSpec{
 					Containers: []v1.Container{
 						{
-							Name: containerName,
-							Image: image,
-							Ports: []v1.ContainerPort{
-								{
-									Name: "http",
-									Protocol: v1.ProtocolTCP,
-									ContainerPort: 80,
-								},
-							},
+							Name: input.containerName,
+							Image: input.image,
+							Ports: input.ports,
 						},
 					},
 				},
 			},
 		},
 	}
-
-	result, err := deploymentsClient.Create(context.TODO(), deployment, metav1.CreateOptions{})
-	if err != nil {
-		log.Fatalf("Cannot create deployment: %v", err.Error())
-	}
-	log.Printf("Created deployment %v.", result.GetObjectMeta().GetName())
 }
 
 func deleteK8sDeployment(
-	clientset *kubernetes.Clientset,
+	ctx context.Context,
+	clientset kubernetes.Interface,
 	namespace string,
-	deploymentName string) {
+	deploymentName string) error {
 	deletePolicy := metav1.DeletePropagationForeground
 	deploymentClient := clientset.AppsV1().Deployments(namespace)
 
-	if err := deploymentClient.Delete(context.TODO(), deploymentName, metav1.DeleteOptions{
+	if err := deploymentClient.Delete(ctx, deploymentName, metav1.DeleteOptions{
 		PropagationPolicy: &deletePolicy,
 	}); err != nil {
-		log.Fatalf("Cannot delete deployment: %v", err.Error())
+		return fmt.Errorf("cannot delete deployment %v in namespace %v: %w", deploymentName, namespace, err)
 	}
-	log.Printf("Deleted deployment %v", deploymentName)
+	log.Printf("Deleting deployment %v, its replica sets and pods are deleted first.", deploymentName)
+	return nil
 }
 
-func getPods(clientset *kubernetes.Clientset, namespace string) {
-	if namespace == "" {
-		namespaces := getNamespaces(clientset)
-		for _, ns := range namespaces {
-			name := ns.Name
-			getPodsOfNamespace(clientset, name)
-		}
-	} else {
-		getPodsOfNamespace(clientset, namespace)
-	}
+// getPods lists the pods of a namespace, or of every namespace when it is empty, narrowed down by the selectors of
+// the options.
+func getPods(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	namespace string,
+	options metav1.ListOptions) ([]v1.Pod, error) {
+	var pods []v1.Pod
+	err := listPods(ctx, clientset, namespace, options, func(page []v1.Pod) error {
+		pods = append(pods, page...)
+		return nil
+	})
+	return pods, err
 }
 
-func getPodsOfNamespace(clientset *kubernetes.Clientset, name string) {
-	pods, err := clientset.CoreV1().Pods(name).List(context.TODO(), metav1.ListOptions{})
-	if err != nil {
-		log.Fatalf("Cannot get pods of namespace %v: %v", name, err.Error())
-	}
-
-	for _, p := range pods.Items {
-		log.Printf("Pod of namespace %v: %v", name, p.Name)
-	}
+func getPodsOfNamespace(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	name string,
+	options metav1.ListOptions) ([]v1.Pod, error) {
+	var pods []v1.Pod
+	_, err := listPodPages(ctx, clientset, name, options, func(page []v1.Pod) error {
+		pods = append(pods, page...)
+		return nil
+	})
+	return pods, err
 }
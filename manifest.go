@@ -0,0 +1,329 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"log"
+	"os"
+	"strings"
+)
+
+// manifestObject is one object of a manifest, or the error of decoding it.
+type manifestObject struct {
+	object runtime.Object
+	kind   schema.GroupVersionKind
+	err    error
+}
+
+// manifestResult is the outcome of creating or applying one object of a manifest.
+type manifestResult struct {
+	description string
+	err         error
+}
+
+// readManifestFile reads the objects of a manifest file, or of stdin when the file name is "-".
+func readManifestFile(filename string) ([]manifestObject, error) {
+	if filename == "-" {
+		return decodeManifests(os.Stdin)
+	}
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open manifest: %w", err)
+	}
+	defer file.Close()
+	return decodeManifests(file)
+}
+
+// decodeManifests decodes the documents of a YAML or JSON manifest into typed objects. A document that cannot be
+// decoded is returned with its error so that the other documents can still be created; a syntax error ends the
+// manifest.
+func decodeManifests(reader io.Reader) ([]manifestObject, error) {
+	decoder := yaml.NewYAMLOrJSONDecoder(reader, 4096)
+	deserializer := scheme.Codecs.UniversalDeserializer()
+	var objects []manifestObject
+	for {
+		var raw runtime.RawExtension
+		if err := decoder.Decode(&raw); err == io.EOF {
+			return objects, nil
+		} else if err != nil {
+			return objects, fmt.Errorf("cannot parse document %d of the manifest: %w", len(objects)+1, err)
+		}
+		if len(raw.Raw) == 0 {
+			continue
+		}
+
+		obj, kind, err := deserializer.Decode(raw.Raw, nil, nil)
+		if err != nil {
+			err = fmt.Errorf("cannot decode document %d: %w", len(objects)+1, err)
+			objects = append(objects, manifestObject{err: err})
+			continue
+		}
+		if list, ok := obj.(*v1.List); ok {
+			items, err := decodeListItems(list)
+			if err != nil {
+				return objects, err
+			}
+			objects = append(objects, items...)
+			continue
+		}
+		objects = append(objects, manifestObject{object: obj, kind: *kind})
+	}
+}
+
+func decodeListItems(list *v1.List) ([]manifestObject, error) {
+	var objects []manifestObject
+	for _, item := range list.Items {
+		items, err := decodeManifests(strings.NewReader(string(item.Raw)))
+		if err != nil {
+			return objects, err
+		}
+		objects = append(objects, items...)
+	}
+	return objects, nil
+}
+
+// manifestAction creates or applies one object of a manifest, in the namespace of the object or else in the given
+// namespace, and returns the description of the object.
+type manifestAction func(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	o manifestObject,
+	namespace string) (string, error)
+
+// createManifestObjects creates each object with its typed client, in the namespace of the object or else in the
+// given namespace, and returns one result per object.
+func createManifestObjects(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	objects []manifestObject,
+	namespace string) []manifestResult {
+	return handleManifestObjects(ctx, clientset, objects, namespace, createManifestObject)
+}
+
+// applyManifestObjects creates or updates each object with server-side apply through its typed client, in the
+// namespace of the object or else in the given namespace, and returns one result per object. Fields owned by another
+// manager are only taken over when force is set.
+func applyManifestObjects(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	objects []manifestObject,
+	namespace string,
+	force bool) []manifestResult {
+	return handleManifestObjects(ctx, clientset, objects, namespace,
+		func(ctx context.Context, clientset kubernetes.Interface, o manifestObject, namespace string) (string, error) {
+			return applyManifestObject(ctx, clientset, o, namespace, force)
+		})
+}
+
+func handleManifestObjects(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	objects []manifestObject,
+	namespace string,
+	action manifestAction) []manifestResult {
+	var results []manifestResult
+	for _, o := range objects {
+		if o.err != nil {
+			results = append(results, manifestResult{description: "unknown object", err: o.err})
+			continue
+		}
+		description, err := action(ctx, clientset, o, namespace)
+		results = append(results, manifestResult{description: description, err: err})
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return results
+}
+
+// manifestTarget returns the description, name and namespace of an object of a manifest, which is its own namespace,
+// or else the given one, or else default.
+func manifestTarget(o manifestObject, namespace string) (string, string, string, error) {
+	accessor, err := meta.Accessor(o.object)
+	if err != nil {
+		return "unknown object", "", "", err
+	}
+	if accessor.GetNamespace() != "" {
+		namespace = accessor.GetNamespace()
+	}
+	if namespace == "" {
+		namespace = "default"
+	}
+	return describeObject(o.kind, accessor.GetName()), accessor.GetName(), namespace, nil
+}
+
+func createManifestObject(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	o manifestObject,
+	namespace string) (string, error) {
+	description, _, namespace, err := manifestTarget(o, namespace)
+	if err != nil {
+		return description, err
+	}
+
+	options := metav1.CreateOptions{}
+	switch obj := o.object.(type) {
+	case *v1.Namespace:
+		_, err = clientset.CoreV1().Namespaces().Create(ctx, obj, options)
+	case *v1.ConfigMap:
+		_, err = clientset.CoreV1().ConfigMaps(namespace).Create(ctx, obj, options)
+	case *v1.Secret:
+		_, err = clientset.CoreV1().Secrets(namespace).Create(ctx, obj, options)
+	case *v1.Service:
+		_, err = clientset.CoreV1().Services(namespace).Create(ctx, obj, options)
+	case *v1.ServiceAccount:
+		_, err = clientset.CoreV1().ServiceAccounts(namespace).Create(ctx, obj, options)
+	case *v1.PersistentVolumeClaim:
+		_, err = clientset.CoreV1().PersistentVolumeClaims(namespace).Create(ctx, obj, options)
+	case *v1.Pod:
+		_, err = clientset.CoreV1().Pods(namespace).Create(ctx, obj, options)
+	case *appsv1.Deployment:
+		_, err = clientset.AppsV1().Deployments(namespace).Create(ctx, obj, options)
+	case *appsv1.StatefulSet:
+		_, err = clientset.AppsV1().StatefulSets(namespace).Create(ctx, obj, options)
+	case *appsv1.DaemonSet:
+		_, err = clientset.AppsV1().DaemonSets(namespace).Create(ctx, obj, options)
+	case *batchv1.Job:
+		_, err = clientset.BatchV1().Jobs(namespace).Create(ctx, obj, options)
+	case *batchv1.CronJob:
+		_, err = clientset.BatchV1().CronJobs(namespace).Create(ctx, obj, options)
+	case *networkingv1.Ingress:
+		_, err = clientset.NetworkingV1().Ingresses(namespace).Create(ctx, obj, options)
+	default:
+		return description, fmt.Errorf("kind %v is not supported", o.kind)
+	}
+	if err != nil {
+		return description, fmt.Errorf("cannot create %v: %w", description, err)
+	}
+	return description, nil
+}
+
+// applyManifestObject sends the object as a server-side apply patch to its typed client. The decoder clears the kind
+// of the objects it decodes, so it is set again for the API server to know what the patch applies to.
+func applyManifestObject(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	o manifestObject,
+	namespace string,
+	force bool) (string, error) {
+	description, name, namespace, err := manifestTarget(o, namespace)
+	if err != nil {
+		return description, err
+	}
+	obj := o.object.DeepCopyObject()
+	obj.GetObjectKind().SetGroupVersionKind(o.kind)
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return description, fmt.Errorf("cannot encode %v: %w", description, err)
+	}
+
+	patch, options := types.ApplyPatchType, metav1.PatchOptions{FieldManager: fieldManager, Force: &force}
+	switch obj.(type) {
+	case *v1.Namespace:
+		_, err = clientset.CoreV1().Namespaces().Patch(ctx, name, patch, data, options)
+	case *v1.ConfigMap:
+		_, err = clientset.CoreV1().ConfigMaps(namespace).Patch(ctx, name, patch, data, options)
+	case *v1.Secret:
+		_, err = clientset.CoreV1().Secrets(namespace).Patch(ctx, name, patch, data, options)
+	case *v1.Service:
+		_, err = clientset.CoreV1().Services(namespace).Patch(ctx, name, patch, data, options)
+	case *v1.ServiceAccount:
+		_, err = clientset.CoreV1().ServiceAccounts(namespace).Patch(ctx, name, patch, data, options)
+	case *v1.PersistentVolumeClaim:
+		_, err = clientset.CoreV1().PersistentVolumeClaims(namespace).Patch(ctx, name, patch, data, options)
+	case *v1.Pod:
+		_, err = clientset.CoreV1().Pods(namespace).Patch(ctx, name, patch, data, options)
+	case *appsv1.Deployment:
+		_, err = clientset.AppsV1().Deployments(namespace).Patch(ctx, name, patch, data, options)
+	case *appsv1.StatefulSet:
+		_, err = clientset.AppsV1().StatefulSets(namespace).Patch(ctx, name, patch, data, options)
+	case *appsv1.DaemonSet:
+		_, err = clientset.AppsV1().DaemonSets(namespace).Patch(ctx, name, patch, data, options)
+	case *batchv1.Job:
+		_, err = clientset.BatchV1().Jobs(namespace).Patch(ctx, name, patch, data, options)
+	case *batchv1.CronJob:
+		_, err = clientset.BatchV1().CronJobs(namespace).Patch(ctx, name, patch, data, options)
+	case *networkingv1.Ingress:
+		_, err = clientset.NetworkingV1().Ingresses(namespace).Patch(ctx, name, patch, data, options)
+	default:
+		return description, fmt.Errorf("kind %v is not supported", o.kind)
+	}
+	if conflicts := applyConflicts(err); len(conflicts) > 0 {
+		return description, fmt.Errorf("cannot apply %v, fields owned by other managers:\n%v\n%w",
+			description, strings.Join(conflicts, "\n"), err)
+	} else if err != nil {
+		return description, fmt.Errorf("cannot apply %v: %w", description, err)
+	}
+	return description, nil
+}
+
+// describeObject names an object the way kubectl does, e.g. "deployment.apps/demo".
+func describeObject(kind schema.GroupVersionKind, name string) string {
+	description := strings.ToLower(kind.Kind)
+	if kind.Group != "" {
+		description += "." + kind.Group
+	}
+	return description + "/" + name
+}
+
+// printManifestResults logs the result of each object, e.g. "service/web created" for the verb created, and returns an
+// error wrapping the first failure, if any.
+func printManifestResults(results []manifestResult, verb string) error {
+	var firstErr error
+	failed := 0
+	for _, r := range results {
+		if r.err != nil {
+			log.Printf("%v failed: %v", r.description, r.err)
+			if firstErr == nil {
+				firstErr = r.err
+			}
+			failed++
+		} else {
+			log.Printf("%v %v", r.description, verb)
+		}
+	}
+	if firstErr != nil {
+		return fmt.Errorf("%d of %d objects failed, the first one with: %w", failed, len(results), firstErr)
+	}
+	return nil
+}
+
+// createFromManifest creates the objects of a manifest file and reports the result of each of them.
+func createFromManifest(ctx context.Context, clientset kubernetes.Interface, filename string, namespace string) error {
+	objects, err := readManifestFile(filename)
+	results := createManifestObjects(ctx, clientset, objects, namespace)
+	if resultErr := printManifestResults(results, "created"); resultErr != nil {
+		return resultErr
+	}
+	return err
+}
+
+// applyFromManifest applies the objects of a manifest file and reports the result of each of them.
+func applyFromManifest(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	filename string,
+	namespace string,
+	force bool) error {
+	objects, err := readManifestFile(filename)
+	results := applyManifestObjects(ctx, clientset, objects, namespace, force)
+	if resultErr := printManifestResults(results, "applied"); resultErr != nil {
+		return resultErr
+	}
+	return err
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"strings"
+	"testing"
+)
+
+const sampleManifest = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 2
+  selector:
+    matchLabels:
+      app: web
+  template:
+    metadata:
+      labels:
+        app: web
+    spec:
+      containers:
+      - name: web
+        image: nginx
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  namespace: frontend
+spec:
+  selector:
+    app: web
+  ports:
+  - port: 80
+---
+# An empty document is skipped.
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+data:
+  mode: production
+---
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: unknown
+---
+{"apiVersion": "v1", "kind": "Secret", "metadata": {"name": "token"}, "stringData": {"token": "secret"}}
+`
+
+func TestDecodeManifests(t *testing.T) {
+	objects, err := decodeManifests(strings.NewReader(sampleManifest))
+	if err != nil {
+		t.Fatalf("Cannot decode manifest: %v", err.Error())
+	}
+	var kinds []string
+	for _, o := range objects {
+		if o.err != nil {
+			kinds = append(kinds, "error")
+		} else {
+			kinds = append(kinds, o.kind.Kind)
+		}
+	}
+	want := "Deployment Service ConfigMap error Secret"
+	if got := strings.Join(kinds, " "); got != want {
+		t.Errorf("Decoded kinds, got: %v, want: %v.", got, want)
+	}
+}
+
+func TestCreateManifestObjects(t *testing.T) {
+	clientset, server := newFakeClientset(t,
+		newNamespace("default"),
+		&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "staging", Name: "settings"}})
+
+	objects, err := decodeManifests(strings.NewReader(sampleManifest))
+	if err != nil {
+		t.Fatalf("Cannot decode manifest: %v", err.Error())
+	}
+	results := createManifestObjects(context.TODO(), clientset, objects, "staging")
+	if len(results) != 5 {
+		t.Fatalf("Number of results, got: %d, want: %d.", len(results), 5)
+	}
+
+	wantCreated := map[string]bool{
+		"deployment.apps/web": true,
+		"service/web":         true,
+		"configmap/settings":  false,
+		"unknown object":      false,
+		"secret/token":        true,
+	}
+	for _, r := range results {
+		if created, ok := wantCreated[r.description]; !ok {
+			t.Errorf("Unexpected result for %v.", r.description)
+		} else if created != (r.err == nil) {
+			t.Errorf("Result of %v, got: %v, want created: %v.", r.description, r.err, created)
+		}
+		if r.description == "configmap/settings" && !apierrors.IsAlreadyExists(r.err) {
+			t.Errorf("Error of an existing config map, got: %v, want: AlreadyExists.", r.err)
+		}
+	}
+
+	if server.get("deployments", "staging", "web") == nil {
+		t.Errorf("Deployment without a namespace was not created in the given namespace.")
+	}
+	if server.get("services", "frontend", "web") == nil {
+		t.Errorf("Service was not created in its own namespace.")
+	}
+	if server.get("secrets", "staging", "token") == nil {
+		t.Errorf("Secret of a JSON document was not created.")
+	}
+	if err := printManifestResults(results, "created"); exitCodeForError(err) != exitAlreadyExists {
+		t.Errorf("Summary error, got: %v, want one wrapping AlreadyExists.", err)
+	}
+}
+
+func TestApplyManifestObjects(t *testing.T) {
+	clientset, server := newFakeClientset(t,
+		newNamespace("default"),
+		&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "staging", Name: "settings"}})
+
+	objects, err := decodeManifests(strings.NewReader(sampleManifest))
+	if err != nil {
+		t.Fatalf("Cannot decode manifest: %v", err.Error())
+	}
+	for i := 0; i < 2; i++ {
+		results := applyManifestObjects(context.TODO(), clientset, objects, "staging", false)
+		if len(results) != 5 {
+			t.Fatalf("Number of results, got: %d, want: %d.", len(results), 5)
+		}
+		for _, r := range results {
+			if (r.err == nil) != (r.description != "unknown object") {
+				t.Errorf("Result of %v in run %d, got: %v, want applied unless the kind is unknown.", r.description,
+					i+1, r.err)
+			}
+		}
+	}
+
+	if d := server.get("deployments", "staging", "web"); d == nil || d.GetGeneration() != 1 {
+		t.Errorf("Deployment applied twice, got: %v, want it created once in the given namespace.", d)
+	}
+	if server.get("services", "frontend", "web") == nil {
+		t.Errorf("Service was not applied in its own namespace.")
+	}
+	if data, _, _ := unstructured.NestedString(server.get("configmaps", "staging", "settings").Object,
+		"data", "mode"); data != "production" {
+		t.Errorf("Data of an existing config map after apply, got: %q, want: %q.", data, "production")
+	}
+	patches := server.requestsOf("PATCH", "secrets")
+	if len(patches) != 2 {
+		t.Errorf("Apply patches of the secret, got: %d, want: 2.", len(patches))
+	}
+	for _, r := range patches {
+		if got := r.query["fieldManager"]; len(got) != 1 || got[0] != fieldManager {
+			t.Errorf("Field manager of apply, got: %v, want: %v.", got, fieldManager)
+		}
+		if !strings.Contains(string(r.body), `"kind":"Secret"`) {
+			t.Errorf("Apply patch of a secret does not name its kind, got: %s", r.body)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// activePodsSelector selects the pods that hold on to the resources of their node, those that have not terminated.
+const activePodsSelector = "status.phase!=Succeeded,status.phase!=Failed"
+
+// nodeResources are the resources shown for each node, in this order.
+var nodeResources = []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory, v1.ResourcePods}
+
+// nodeUsage is a node together with the sums of the requests and limits of the pods scheduled on it.
+type nodeUsage struct {
+	node     *v1.Node
+	pods     int
+	requests v1.ResourceList
+	limits   v1.ResourceList
+}
+
+// printNodes writes every node with what the pods scheduled on it request. With placement, it then writes on which
+// nodes the pods of the deployments of a namespace run, or of every namespace when it is empty.
+func printNodes(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	placement bool,
+	namespace string,
+	out io.Writer) error {
+	var nodes []v1.Node
+	var pods []v1.Pod
+	var replicaSets []appsv1.ReplicaSet
+	tasks := []func(ctx context.Context) error{
+		func(ctx context.Context) error {
+			list, err := clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+			if err != nil {
+				return fmt.Errorf("cannot get nodes: %w", err)
+			}
+			nodes = list.Items
+			return nil
+		},
+		func(ctx context.Context) error {
+			var err error
+			pods, err = getPods(ctx, clientset, "", metav1.ListOptions{FieldSelector: activePodsSelector})
+			return err
+		},
+	}
+	if placement {
+		tasks = append(tasks, func(ctx context.Context) error {
+			list, err := clientset.AppsV1().ReplicaSets(namespace).List(ctx, metav1.ListOptions{})
+			if err != nil {
+				return fmt.Errorf("cannot get replica sets: %w", err)
+			}
+			replicaSets = list.Items
+			return nil
+		})
+	}
+	if err := gather(ctx, tasks...); err != nil {
+		return err
+	}
+	if len(nodes) == 0 {
+		fmt.Fprintln(out, "No nodes found.")
+		return nil
+	}
+
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+	if err := writeNodeUsages(out, nodeUsages(nodes, pods)); err != nil || !placement {
+		return err
+	}
+	fmt.Fprintln(out)
+	return writePlacement(out, nodes, deploymentPlacement(pods, replicaSets))
+}
+
+// nodeUsages sums the requests and limits of the pods of each node.
+func nodeUsages(nodes []v1.Node, pods []v1.Pod) []nodeUsage {
+	usages := make([]nodeUsage, len(nodes))
+	byName := map[string]*nodeUsage{}
+	for i := range nodes {
+		usages[i] = nodeUsage{node: &nodes[i], requests: v1.ResourceList{}, limits: v1.ResourceList{}}
+		byName[nodes[i].Name] = &usages[i]
+	}
+	for i := range pods {
+		usage := byName[pods[i].Spec.NodeName]
+		if usage == nil {
+			continue
+		}
+		usage.pods++
+		requests, limits := podRequestsAndLimits(&pods[i])
+		addResources(usage.requests, requests)
+		addResources(usage.limits, limits)
+	}
+	return usages
+}
+
+// podRequestsAndLimits returns what a pod requests and is limited to the way the scheduler counts it: the sum over
+// its containers or the most any init container asks for, whichever is higher, plus the overhead of the pod.
+func podRequestsAndLimits(pod *v1.Pod) (v1.ResourceList, v1.ResourceList) {
+	requests, limits := v1.ResourceList{}, v1.ResourceList{}
+	for _, c := range pod.Spec.Containers {
+		addResources(requests, c.Resources.Requests)
+		addResources(limits, c.Resources.Limits)
+	}
+	for _, c := range pod.Spec.InitContainers {
+		maxResources(requests, c.Resources.Requests)
+		maxResources(limits, c.Resources.Limits)
+	}
+	addResources(requests, pod.Spec.Overhead)
+	if len(limits) > 0 {
+		addResources(limits, pod.Spec.Overhead)
+	}
+	return requests, limits
+}
+
+func addResources(sum v1.ResourceList, resources v1.ResourceList) {
+	for name, quantity := range resources {
+		total := sum[name]
+		total.Add(quantity)
+		sum[name] = total
+	}
+}
+
+func maxResources(peak v1.ResourceList, resources v1.ResourceList) {
+	for name, quantity := range resources {
+		if current, ok := peak[name]; !ok || quantity.Cmp(current) > 0 {
+			peak[name] = quantity.DeepCopy()
+		}
+	}
+}
+
+// writeNodeUsages writes a block per node with its roles, kubelet version, conditions, taints and resources, and
+// the share of its allocatable resources that the pods on it request and are limited to.
+func writeNodeUsages(out io.Writer, usages []nodeUsage) error {
+	for i, usage := range usages {
+		node := usage.node
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+		fmt.Fprintf(out, "%s (%s, roles %s, kubelet %s)\n", node.Name, nodeStatus(node),
+			orNone(strings.Join(nodeRoles(node), ",")), orNone(node.Status.NodeInfo.KubeletVersion))
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "  Conditions:\t%s\n", nodeConditions(node))
+		fmt.Fprintf(w, "  Taints:\t%s\n", nodeTaints(node))
+		fmt.Fprintf(w, "  Capacity:\t%s\n", formatNodeResources(node.Status.Capacity))
+		fmt.Fprintf(w, "  Allocatable:\t%s\n", formatNodeResources(node.Status.Allocatable))
+		allocatable := node.Status.Allocatable
+		fmt.Fprintf(w, "  Requests:\t%s\n", formatShares(usage.requests, allocatable))
+		fmt.Fprintf(w, "  Limits:\t%s\n", formatShares(usage.limits, allocatable))
+		pods := resource.NewQuantity(int64(usage.pods), resource.DecimalSI)
+		fmt.Fprintf(w, "  Pods:\t%d%s\n", usage.pods, share(*pods, allocatable[v1.ResourcePods]))
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nodeConditions lists the conditions of the node, e.g. "Ready=True, MemoryPressure=False".
+func nodeConditions(node *v1.Node) string {
+	var conditions []string
+	for _, c := range node.Status.Conditions {
+		conditions = append(conditions, fmt.Sprintf("%s=%s", c.Type, c.Status))
+	}
+	return orNone(strings.Join(conditions, ", "))
+}
+
+// nodeTaints lists the taints of the node, e.g. "dedicated=gpu:NoSchedule".
+func nodeTaints(node *v1.Node) string {
+	var taints []string
+	for _, taint := range node.Spec.Taints {
+		taints = append(taints, taint.ToString())
+	}
+	return orNone(strings.Join(taints, ", "))
+}
+
+// formatNodeResources writes the CPU, memory and pods of a list of resources, e.g. "cpu 4, memory 16Gi, pods 110".
+func formatNodeResources(resources v1.ResourceList) string {
+	var entries []string
+	for _, name := range nodeResources {
+		if quantity, ok := resources[name]; ok {
+			entries = append(entries, fmt.Sprintf("%s %s", name, quantity.String()))
+		}
+	}
+	return orNone(strings.Join(entries, ", "))
+}
+
+// formatShares writes the CPU and memory of a sum of requests or limits, each with its share of the allocatable
+// resources, e.g. "cpu 750m (37%), memory 1Gi (12%)".
+func formatShares(sum v1.ResourceList, allocatable v1.ResourceList) string {
+	var entries []string
+	for _, name := range []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory} {
+		quantity := sum[name]
+		entries = append(entries, fmt.Sprintf("%s %s%s", name, quantity.String(), share(quantity, allocatable[name])))
+	}
+	return strings.Join(entries, ", ")
+}
+
+// share writes a quantity as a percentage of a total, e.g. " (37%)", or nothing when the total is not known.
+func share(quantity resource.Quantity, total resource.Quantity) string {
+	if total.IsZero() {
+		return ""
+	}
+	return fmt.Sprintf(" (%d%%)", quantity.MilliValue()*100/total.MilliValue())
+}
+
+// deploymentPlacement counts the pods of each deployment per node, keyed by namespace/name and then by node. The
+// deployment of a pod is the one controlling the ReplicaSet controlling the pod; other pods are left out.
+func deploymentPlacement(pods []v1.Pod, replicaSets []appsv1.ReplicaSet) map[string]map[string]int {
+	deployments := map[types.UID]string{}
+	for _, rs := range replicaSets {
+		if ref := metav1.GetControllerOf(&rs); ref != nil && ref.Kind == "Deployment" {
+			deployments[rs.UID] = rs.Namespace + "/" + ref.Name
+		}
+	}
+	placement := map[string]map[string]int{}
+	for i := range pods {
+		ref := metav1.GetControllerOf(&pods[i])
+		if ref == nil || pods[i].Spec.NodeName == "" {
+			continue
+		}
+		deployment, ok := deployments[ref.UID]
+		if !ok {
+			continue
+		}
+		if placement[deployment] == nil {
+			placement[deployment] = map[string]int{}
+		}
+		placement[deployment][pods[i].Spec.NodeName]++
+	}
+	return placement
+}
+
+// writePlacement writes a table with a row per deployment and a column per node, counting the pods of the
+// deployment on the node. Deployments with several pods that all run on one node, when there are more nodes to
+// spread them over, are marked as such.
+func writePlacement(out io.Writer, nodes []v1.Node, placement map[string]map[string]int) error {
+	if len(placement) == 0 {
+		fmt.Fprintln(out, "No pods of deployments are scheduled.")
+		return nil
+	}
+	var deployments []string
+	for deployment := range placement {
+		deployments = append(deployments, deployment)
+	}
+	sort.Strings(deployments)
+
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	headers := []string{"DEPLOYMENT"}
+	for _, node := range nodes {
+		headers = append(headers, node.Name)
+	}
+	fmt.Fprintln(w, strings.Join(append(headers, "SPREAD"), "\t"))
+	for _, deployment := range deployments {
+		row := []string{deployment}
+		pods, used := 0, 0
+		for _, node := range nodes {
+			count := placement[deployment][node.Name]
+			row = append(row, fmt.Sprint(count))
+			pods += count
+			if count > 0 {
+				used++
+			}
+		}
+		spread := fmt.Sprintf("%s on %s", plural(pods, "pod"), plural(used, "node"))
+		if pods > 1 && used == 1 && len(nodes) > 1 {
+			spread += ", all on one node"
+		}
+		fmt.Fprintln(w, strings.Join(append(row, spread), "\t"))
+	}
+	return w.Flush()
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"regexp"
+	"testing"
+)
+
+func newNode(name string, cpu string, memory string, pods string) *v1.Node {
+	resources := v1.ResourceList{
+		v1.ResourceCPU:    resource.MustParse(cpu),
+		v1.ResourceMemory: resource.MustParse(memory),
+		v1.ResourcePods:   resource.MustParse(pods),
+	}
+	return &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: v1.NodeStatus{
+			Capacity:    resources,
+			Allocatable: resources,
+			Conditions:  []v1.NodeCondition{{Type: v1.NodeReady, Status: v1.ConditionTrue}},
+			NodeInfo:    v1.NodeSystemInfo{KubeletVersion: "v1.21.2"},
+		},
+	}
+}
+
+// newScheduledPod returns a running pod on the node whose container requests cpu and memory, and is limited to
+// twice the memory.
+func newScheduledPod(pod *v1.Pod, node string, cpu string, memory string) *v1.Pod {
+	pod.Spec.NodeName = node
+	pod.Spec.Containers = []v1.Container{{Name: "web", Resources: v1.ResourceRequirements{
+		Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse(cpu), v1.ResourceMemory: resource.MustParse(memory)},
+	}}}
+	limit := resource.MustParse(memory)
+	limit.Add(limit)
+	pod.Spec.Containers[0].Resources.Limits = v1.ResourceList{v1.ResourceMemory: limit}
+	pod.Status.Phase = v1.PodRunning
+	return pod
+}
+
+func TestPrintNodes(t *testing.T) {
+	controlPlane := newNode("node-1", "4", "8Gi", "110")
+	controlPlane.Labels = map[string]string{"node-role.kubernetes.io/control-plane": ""}
+	controlPlane.Spec.Taints = []v1.Taint{{Key: "node-role.kubernetes.io/master", Effect: v1.TaintEffectNoSchedule}}
+	controlPlane.Status.Allocatable = v1.ResourceList{
+		v1.ResourceCPU:    resource.MustParse("3800m"),
+		v1.ResourceMemory: resource.MustParse("7Gi"),
+		v1.ResourcePods:   resource.MustParse("110"),
+	}
+	clientset, server := newFakeClientset(t, newNamespace("default"), controlPlane,
+		newNode("node-2", "2", "4Gi", "10"))
+	if err := createSampleDeployment(clientset, "demo", "kubernetes-bootcamp"); err != nil {
+		t.Fatalf("Cannot create the sample deployment: %v", err.Error())
+	}
+	rs := newOwnedReplicaSet(getSampleDeployment(t, server, "kubernetes-bootcamp"), "kubernetes-bootcamp-1")
+	initialized := newScheduledPod(newPod("kube-system", "etcd"), "node-1", "250m", "256Mi")
+	initialized.Spec.InitContainers = []v1.Container{{Name: "init", Resources: v1.ResourceRequirements{
+		Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("1")},
+	}}}
+	completed := newScheduledPod(newPod("default", "migration"), "node-2", "1", "1Gi")
+	completed.Status.Phase = v1.PodSucceeded
+	for _, obj := range []runtime.Object{
+		rs, initialized, completed,
+		newScheduledPod(newOwnedPod(rs, "kubernetes-bootcamp-1-a"), "node-2", "500m", "512Mi"),
+		newScheduledPod(newOwnedPod(rs, "kubernetes-bootcamp-1-b"), "node-2", "500m", "512Mi"),
+		newScheduledPod(newOwnedPod(rs, "kubernetes-bootcamp-1-c"), "node-2", "500m", "512Mi"),
+	} {
+		server.seed(obj)
+	}
+
+	var out bytes.Buffer
+	if err := printNodes(context.TODO(), clientset, true, "default", &out); err != nil {
+		t.Fatalf("Cannot print the nodes: %v", err)
+	}
+	for _, want := range []string{
+		`(?m)^node-1 \(Ready, roles control-plane, kubelet v1.21.2\)\n` +
+			`  Conditions: +Ready=True\n` +
+			`  Taints: +node-role.kubernetes.io/master:NoSchedule\n` +
+			`  Capacity: +cpu 4, memory 8Gi, pods 110\n` +
+			`  Allocatable: +cpu 3800m, memory 7Gi, pods 110\n` +
+			`  Requests: +cpu 1 \(26%\), memory 256Mi \(3%\)\n` +
+			`  Limits: +cpu 0 \(0%\), memory 512Mi \(7%\)\n` +
+			`  Pods: +1 \(0%\)\n`,
+		`(?m)^node-2 \(Ready, roles <none>, kubelet v1.21.2\)\n` +
+			`  Conditions: +Ready=True\n` +
+			`  Taints: +<none>\n`,
+		`(?m)^  Requests: +cpu 1500m \(75%\), memory 1536Mi \(37%\)\n` +
+			`  Limits: +cpu 0 \(0%\), memory 3Gi \(75%\)\n` +
+			`  Pods: +3 \(30%\)\n`,
+		`(?m)^DEPLOYMENT +node-1 +node-2 +SPREAD\n` +
+			`default/kubernetes-bootcamp +0 +3 +3 pods on 1 node, all on one node$`,
+	} {
+		if !regexp.MustCompile(want).MatchString(out.String()) {
+			t.Errorf("Nodes do not match %q, got:\n%v", want, out.String())
+		}
+	}
+	if regexp.MustCompile(`kube-system/etcd`).MatchString(out.String()) {
+		t.Errorf("Placement shows a pod that is not of a deployment, got:\n%v", out.String())
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// getOwnedReplicaSets returns the ReplicaSets controlled by the deployment, the current one as well as the old ones
+// kept for rollbacks.
+func getOwnedReplicaSets(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	deployment *appsv1.Deployment) ([]appsv1.ReplicaSet, error) {
+	options, err := selectorListOptions(deployment.Spec.Selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector of deployment %v: %w", deployment.Name, err)
+	}
+	list, err := clientset.AppsV1().ReplicaSets(deployment.Namespace).List(ctx, options)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get replica sets of deployment %v: %w", deployment.Name, err)
+	}
+	var replicaSets []appsv1.ReplicaSet
+	for _, rs := range list.Items {
+		if metav1.IsControlledBy(&rs, deployment) {
+			replicaSets = append(replicaSets, rs)
+		}
+	}
+	return replicaSets, nil
+}
+
+// getOwnedPods returns the pods of the deployment, that is the pods controlled by one of its ReplicaSets.
+func getOwnedPods(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	deployment *appsv1.Deployment,
+	replicaSets []appsv1.ReplicaSet) ([]v1.Pod, error) {
+	options, err := selectorListOptions(deployment.Spec.Selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector of deployment %v: %w", deployment.Name, err)
+	}
+	list, err := clientset.CoreV1().Pods(deployment.Namespace).List(ctx, options)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get pods of deployment %v: %w", deployment.Name, err)
+	}
+	return controlledPods(list.Items, replicaSets), nil
+}
+
+// controlledPods returns the pods controlled by one of the ReplicaSets.
+func controlledPods(pods []v1.Pod, replicaSets []appsv1.ReplicaSet) []v1.Pod {
+	var controlled []v1.Pod
+	for _, pod := range pods {
+		for i := range replicaSets {
+			if metav1.IsControlledBy(&pod, &replicaSets[i]) {
+				controlled = append(controlled, pod)
+				break
+			}
+		}
+	}
+	return controlled
+}
+
+// selectorListOptions narrows a list down to the objects matching a label selector of a workload.
+func selectorListOptions(selector *metav1.LabelSelector) (metav1.ListOptions, error) {
+	s, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return metav1.ListOptions{}, err
+	}
+	return metav1.ListOptions{LabelSelector: s.String()}, nil
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/kubernetes"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultPageSize is the number of pods asked for per page when the options have no limit, the default chunk size
+	// of kubectl.
+	defaultPageSize = 500
+	// listWorkers bounds the namespaces listed at the same time when the pods are listed namespace by namespace.
+	listWorkers = 8
+)
+
+// podFieldSelectors are the fields of pods that the API server can select by.
+var podFieldSelectors = sets.NewString("metadata.name", "metadata.namespace", "spec.nodeName", "spec.restartPolicy",
+	"spec.schedulerName", "spec.serviceAccountName", "status.phase", "status.podIP", "status.nominatedNodeName")
+
+// podListOptions checks a label selector such as "app=demo,tier!=db" and a field selector such as
+// "status.phase=Running", either of which may be empty, and returns the options listing the pods they select.
+func podListOptions(labelSelector string, fieldSelector string) (metav1.ListOptions, error) {
+	options, err := resourceListOptions(labelSelector, fieldSelector)
+	if err != nil || fieldSelector == "" {
+		return options, err
+	}
+	selector, err := fields.ParseSelector(options.FieldSelector)
+	if err != nil {
+		return options, apierrors.NewBadRequest(fmt.Sprintf("invalid field selector %q: %v", fieldSelector, err))
+	}
+	for _, requirement := range selector.Requirements() {
+		if !podFieldSelectors.Has(requirement.Field) {
+			return options, apierrors.NewBadRequest(fmt.Sprintf("field %q is not supported by pod field selectors, "+
+				"use one of %v", requirement.Field, strings.Join(podFieldSelectors.List(), ", ")))
+		}
+	}
+	return options, nil
+}
+
+// podColumns are the columns of the pod table, the same ones kubectl get pods shows.
+var podColumns = []column{
+	podColumn("NAMESPACE", false, func(pod *v1.Pod) string { return pod.Namespace }),
+	podColumn("NAME", false, func(pod *v1.Pod) string { return pod.Name }),
+	podColumn("READY", false, podReady),
+	podColumn("STATUS", false, podStatus),
+	podColumn("RESTARTS", false, func(pod *v1.Pod) string { return fmt.Sprint(podRestarts(pod)) }),
+	podColumn("AGE", false, func(pod *v1.Pod) string { return age(pod.CreationTimestamp.Time) }),
+	podColumn("IP", true, func(pod *v1.Pod) string { return orNone(pod.Status.PodIP) }),
+	podColumn("NODE", true, func(pod *v1.Pod) string { return orNone(pod.Spec.NodeName) }),
+}
+
+func podColumn(header string, wide bool, value func(pod *v1.Pod) string) column {
+	return column{header: header, wide: wide, value: func(obj runtime.Object) string {
+		return value(obj.(*v1.Pod))
+	}}
+}
+
+func podObjects(pods []v1.Pod) []runtime.Object {
+	objects := make([]runtime.Object, len(pods))
+	for i := range pods {
+		objects[i] = &pods[i]
+	}
+	return objects
+}
+
+// viewPods lists the pods of a namespace, or of every namespace when it is empty, and writes them in an output
+// format page by page as they arrive.
+func viewPods(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	namespace string,
+	options metav1.ListOptions,
+	out io.Writer,
+	format string) error {
+	printer, err := newPrinter(out, format, podColumns)
+	if err != nil {
+		return err
+	}
+	err = listPods(ctx, clientset, namespace, options, func(pods []v1.Pod) error {
+		return printer.printObjects(podObjects(pods))
+	})
+	if err != nil {
+		return err
+	}
+	return printer.flush()
+}
+
+// listPods lists the pods of a namespace, or of the whole cluster with a single paged list when it is empty, and
+// hands each page to handle as soon as it arrives. When the credentials may not list pods across the cluster, the
+// namespaces are listed one by one instead by a bounded number of workers, skipping those that are forbidden too.
+func listPods(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	namespace string,
+	options metav1.ListOptions,
+	handle func(pods []v1.Pod) error) error {
+	listed := false
+	_, err := listPodPages(ctx, clientset, namespace, options, func(pods []v1.Pod) error {
+		listed = true
+		return handle(pods)
+	})
+	if namespace != "" || listed || !apierrors.IsForbidden(err) {
+		return err
+	}
+	log.Printf("Cannot list pods across the cluster, listing them namespace by namespace instead.")
+	return listPodsByNamespace(ctx, clientset, options, handle)
+}
+
+// listPodPages lists the pods of a namespace, or of every namespace when it is empty, following the continue token
+// from page to page. The limit of the options is the page size, defaultPageSize when it is not set. It returns the
+// resource version of the first page, which all the pages are a snapshot of.
+func listPodPages(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	namespace string,
+	options metav1.ListOptions,
+	handle func(pods []v1.Pod) error) (string, error) {
+	if options.Limit == 0 {
+		options.Limit = defaultPageSize
+	}
+	options.Continue = ""
+	resourceVersion := ""
+	for {
+		list, err := clientset.CoreV1().Pods(namespace).List(ctx, options)
+		if err != nil && namespace == "" {
+			return "", fmt.Errorf("cannot get pods of all namespaces: %w", err)
+		} else if err != nil {
+			return "", fmt.Errorf("cannot get pods of namespace %v: %w", namespace, err)
+		}
+		if resourceVersion == "" {
+			resourceVersion = list.ResourceVersion
+		}
+		if err := handle(list.Items); err != nil {
+			return "", err
+		}
+		if list.Continue == "" {
+			return resourceVersion, nil
+		}
+		options.Continue = list.Continue
+	}
+}
+
+// listPodsByNamespace lists the pods namespace by namespace, listWorkers namespaces at a time. The pages are handed to
+// handle one at a time, in the order they arrive.
+func listPodsByNamespace(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	options metav1.ListOptions,
+	handle func(pods []v1.Pod) error) error {
+	namespaces, err := getNamespaces(ctx, clientset)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	pages := make(chan []v1.Pod)
+	tasks := make([]func(ctx context.Context) error, len(namespaces))
+	for i := range namespaces {
+		name := namespaces[i].Name
+		tasks[i] = func(ctx context.Context) error {
+			_, err := listPodPages(ctx, clientset, name, options, func(pods []v1.Pod) error {
+				select {
+				case pages <- pods:
+					return nil
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			})
+			if apierrors.IsForbidden(err) {
+				log.Printf("Skipping namespace %v: %v", name, err)
+				return nil
+			}
+			return err
+		}
+	}
+	var listErr error
+	go func() {
+		listErr = gatherLimited(ctx, listWorkers, tasks...)
+		close(pages)
+	}()
+
+	var handleErr error
+	for pods := range pages {
+		if handleErr == nil {
+			if handleErr = handle(pods); handleErr != nil {
+				cancel()
+			}
+		}
+	}
+	if handleErr != nil {
+		return handleErr
+	}
+	return listErr
+}
+
+// podReady counts the ready containers of the pod, e.g. "1/2".
+func podReady(pod *v1.Pod) string {
+	ready := 0
+	for _, c := range pod.Status.ContainerStatuses {
+		if c.Ready {
+			ready++
+		}
+	}
+	return fmt.Sprintf("%d/%d", ready, len(pod.Spec.Containers))
+}
+
+func podRestarts(pod *v1.Pod) int32 {
+	var restarts int32
+	for _, c := range pod.Status.ContainerStatuses {
+		restarts += c.RestartCount
+	}
+	return restarts
+}
+
+// podStatus describes the state of the pod the way kubectl does, preferring the reason of a waiting or terminated
+// container, e.g. "CrashLoopBackOff", over the phase, and showing the progress of init containers as "Init:1/2".
+func podStatus(pod *v1.Pod) string {
+	reason := string(pod.Status.Phase)
+	if pod.Status.Reason != "" {
+		reason = pod.Status.Reason
+	}
+
+	initializing := false
+	for i, c := range pod.Status.InitContainerStatuses {
+		if c.State.Terminated != nil && c.State.Terminated.ExitCode == 0 {
+			continue
+		}
+		initializing = true
+		switch {
+		case c.State.Terminated != nil && c.State.Terminated.Reason != "":
+			reason = "Init:" + c.State.Terminated.Reason
+		case c.State.Terminated != nil:
+			reason = fmt.Sprintf("Init:ExitCode:%d", c.State.Terminated.ExitCode)
+		case c.State.Waiting != nil && c.State.Waiting.Reason != "" && c.State.Waiting.Reason != "PodInitializing":
+			reason = "Init:" + c.State.Waiting.Reason
+		default:
+			reason = fmt.Sprintf("Init:%d/%d", i, len(pod.Spec.InitContainers))
+		}
+		break
+	}
+	if !initializing {
+		for i := len(pod.Status.ContainerStatuses) - 1; i >= 0; i-- {
+			c := pod.Status.ContainerStatuses[i]
+			if c.State.Waiting != nil && c.State.Waiting.Reason != "" {
+				reason = c.State.Waiting.Reason
+			} else if c.State.Terminated != nil && c.State.Terminated.Reason != "" {
+				reason = c.State.Terminated.Reason
+			} else if c.State.Terminated != nil {
+				reason = fmt.Sprintf("ExitCode:%d", c.State.Terminated.ExitCode)
+			}
+		}
+	}
+
+	if pod.DeletionTimestamp != nil && pod.Status.Reason == "NodeLost" {
+		return "Unknown"
+	} else if pod.DeletionTimestamp != nil {
+		return "Terminating"
+	}
+	if reason == "" {
+		return "Pending"
+	}
+	return reason
+}
+
+// age tells how long ago something happened, e.g. "5m" or "3d", or "<unknown>" if it is not known.
+func age(t time.Time) string {
+	if t.IsZero() {
+		return "<unknown>"
+	}
+	return duration.HumanDuration(time.Since(t))
+}
+
+func orNone(value string) string {
+	if value == "" {
+		return "<none>"
+	}
+	return value
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestPodStatus(t *testing.T) {
+	waiting := func(reason string) v1.ContainerState {
+		return v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: reason}}
+	}
+	terminated := func(reason string, exitCode int32) v1.ContainerState {
+		return v1.ContainerState{Terminated: &v1.ContainerStateTerminated{Reason: reason, ExitCode: exitCode}}
+	}
+	deleted := metav1.Now()
+	tests := []struct {
+		name string
+		pod  v1.Pod
+		want string
+	}{
+		{"phase", v1.Pod{Status: v1.PodStatus{Phase: v1.PodRunning}}, "Running"},
+		{"no phase yet", v1.Pod{}, "Pending"},
+		{"evicted", v1.Pod{Status: v1.PodStatus{Phase: v1.PodFailed, Reason: "Evicted"}}, "Evicted"},
+		{"waiting container", v1.Pod{Status: v1.PodStatus{
+			Phase:             v1.PodRunning,
+			ContainerStatuses: []v1.ContainerStatus{{State: waiting("CrashLoopBackOff")}},
+		}}, "CrashLoopBackOff"},
+		{"terminated container", v1.Pod{Status: v1.PodStatus{
+			Phase:             v1.PodFailed,
+			ContainerStatuses: []v1.ContainerStatus{{State: terminated("", 137)}},
+		}}, "ExitCode:137"},
+		{"init container running", v1.Pod{
+			Spec: v1.PodSpec{InitContainers: []v1.Container{{Name: "a"}, {Name: "b"}}},
+			Status: v1.PodStatus{Phase: v1.PodPending, InitContainerStatuses: []v1.ContainerStatus{
+				{State: terminated("Completed", 0)},
+				{State: waiting("PodInitializing")},
+			}},
+		}, "Init:1/2"},
+		{"init container failing", v1.Pod{
+			Spec: v1.PodSpec{InitContainers: []v1.Container{{Name: "a"}}},
+			Status: v1.PodStatus{Phase: v1.PodPending, InitContainerStatuses: []v1.ContainerStatus{
+				{State: terminated("Error", 1)},
+			}},
+		}, "Init:Error"},
+		{"terminating", v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{DeletionTimestamp: &deleted},
+			Status:     v1.PodStatus{Phase: v1.PodRunning},
+		}, "Terminating"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := podStatus(&test.pod); got != test.want {
+				t.Errorf("Status, got: %v, want: %v.", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPodListOptions(t *testing.T) {
+	tests := []struct {
+		labelSelector string
+		fieldSelector string
+		wantLabels    string
+		wantFields    string
+		wantErr       bool
+	}{
+		{"", "", "", "", false},
+		{"app=demo,tier!=db", "", "app=demo,tier!=db", "", false},
+		{"environment in (prod, staging)", "status.phase=Running", "environment in (prod,staging)", "status.phase=Running", false},
+		{"", "spec.nodeName=node-1,status.phase!=Failed", "", "spec.nodeName=node-1,status.phase!=Failed", false},
+		{"app=", "", "app=", "", false},
+		{"app==demo=", "", "", "", true},
+		{"not a selector!", "", "", "", true},
+		{"", "status.phase", "", "", true},
+		{"", "spec.containers=web", "", "", true},
+	}
+	for _, test := range tests {
+		options, err := podListOptions(test.labelSelector, test.fieldSelector)
+		if test.wantErr {
+			if exitCodeForError(err) != exitInvalid {
+				t.Errorf("Error of selectors %q and %q, got: %v, want an invalid input.", test.labelSelector,
+					test.fieldSelector, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Cannot parse selectors %q and %q: %v", test.labelSelector, test.fieldSelector, err.Error())
+		} else if options.LabelSelector != test.wantLabels || options.FieldSelector != test.wantFields {
+			t.Errorf("Selectors, got: %q and %q, want: %q and %q.", options.LabelSelector, options.FieldSelector,
+				test.wantLabels, test.wantFields)
+		}
+	}
+}
+
+func TestViewWithSelectors(t *testing.T) {
+	running := newPod("default", "web-0")
+	running.Labels = map[string]string{"app": "web"}
+	running.Status.Phase = v1.PodRunning
+	pending := newPod("default", "web-1")
+	pending.Labels = map[string]string{"app": "web"}
+	pending.Status.Phase = v1.PodPending
+	other := newPod("default", "db-0")
+	other.Labels = map[string]string{"app": "db"}
+	other.Status.Phase = v1.PodRunning
+	clientset, server := newFakeClientset(t, newNamespace("default"), running, pending, other)
+
+	options, err := podListOptions("app=web", "status.phase=Running")
+	if err != nil {
+		t.Fatalf("Cannot parse selectors: %v", err.Error())
+	}
+	pods, err := getPods(context.TODO(), clientset, "default", options)
+	if err != nil {
+		t.Fatalf("Cannot get pods: %v", err.Error())
+	}
+	if len(pods) != 1 || pods[0].Name != "web-0" {
+		t.Errorf("Selected pods, got: %v, want: web-0.", pods)
+	}
+	requests := server.requestsOf("GET", "pods")
+	query := url.Values(requests[len(requests)-1].query)
+	if query.Get("labelSelector") != "app=web" || query.Get("fieldSelector") != "status.phase=Running" {
+		t.Errorf("Selectors sent to the API server, got: %v, want them in the list options.", query)
+	}
+
+	path := writeKubeconfig(t, "fake", map[string]string{"fake": server.URL})
+	if got := runSubcommand([]string{"view", "--kubeconfig", path, "-l", "app in (web"}); got != exitInvalid {
+		t.Errorf("Exit code of view with an invalid label selector, got: %d, want: %d.", got, exitInvalid)
+	}
+}
+
+func TestListPodsInPages(t *testing.T) {
+	clientset, server := newFakeClientset(t, newNamespace("default"), newNamespace("kube-system"),
+		newPod("default", "web-0"), newPod("default", "web-1"), newPod("default", "web-2"),
+		newPod("kube-system", "coredns-0"), newPod("kube-system", "coredns-1"))
+
+	var pages []int
+	err := listPods(context.TODO(), clientset, "", metav1.ListOptions{Limit: 2}, func(pods []v1.Pod) error {
+		pages = append(pages, len(pods))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Cannot list pods: %v", err.Error())
+	}
+	if !reflect.DeepEqual(pages, []int{2, 2, 1}) {
+		t.Errorf("Sizes of the pages, got: %v, want: %v.", pages, []int{2, 2, 1})
+	}
+	for _, r := range server.requestsOf("GET", "pods") {
+		if r.namespace != "" || url.Values(r.query).Get("limit") != "2" {
+			t.Errorf("List of a page, got namespace %q and query %v, want a cluster-wide list with a limit.",
+				r.namespace, r.query)
+		}
+	}
+	if got := len(server.requestsOf("GET", "namespaces")); got != 0 {
+		t.Errorf("Lists of namespaces, got: %d, want: %d.", got, 0)
+	}
+}
+
+func TestListPodsFallsBackToNamespaces(t *testing.T) {
+	objects := []runtime.Object{}
+	for i := 0; i < 2*listWorkers; i++ {
+		namespace := fmt.Sprintf("team-%02d", i)
+		objects = append(objects, newNamespace(namespace), newPod(namespace, "web-0"), newPod(namespace, "web-1"))
+	}
+	clientset, server := newFakeClientset(t, objects...)
+	server.failNext("GET", "pods", apierrors.NewForbidden(schema.GroupResource{Resource: "pods"}, "",
+		errors.New("cannot list pods at the cluster scope")))
+
+	pods, err := getPods(context.TODO(), clientset, "", metav1.ListOptions{Limit: 1})
+	if err != nil {
+		t.Fatalf("Cannot list pods namespace by namespace: %v", err.Error())
+	}
+	if len(pods) != 4*listWorkers {
+		t.Errorf("Number of pods, got: %d, want: %d.", len(pods), 4*listWorkers)
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+	"strings"
+)
+
+var deploymentKind = appsv1.SchemeGroupVersion.WithKind("Deployment")
+
+// previewCreate runs the create task as a server-side dry run and shows the deployment that would be created, as a
+// diff against nothing.
+func previewCreate(ctx context.Context, clientset kubernetes.Interface, input deploymentInput) (string, error) {
+	namespace := input.targetNamespace()
+	if errs := input.validate(); len(errs) > 0 {
+		return "", invalidDeployment(input.deploymentName, errs)
+	}
+
+	result, err := clientset.AppsV1().Deployments(namespace).Create(ctx, newK8sDeployment(input), metav1.CreateOptions{
+		DryRun: []string{metav1.DryRunAll},
+	})
+	if err != nil {
+		return "", fmt.Errorf("cannot preview deployment %v in namespace %v: %w", input.deploymentName, namespace, err)
+	}
+	return diffObjects(nil, result, deploymentKind, input.deploymentName)
+}
+
+// previewApply runs the apply task as a server-side dry run and shows how the live deployment would change.
+func previewApply(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	input deploymentInput,
+	force bool) (string, error) {
+	namespace := input.targetNamespace()
+	if errs := input.validate(); len(errs) > 0 {
+		return "", invalidDeployment(input.deploymentName, errs)
+	}
+
+	deploymentsClient := clientset.AppsV1().Deployments(namespace)
+	live, err := deploymentsClient.Get(ctx, input.deploymentName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		live = nil
+	} else if err != nil {
+		return "", fmt.Errorf("cannot get deployment %v in namespace %v: %w", input.deploymentName, namespace, err)
+	}
+	result, err := deploymentsClient.Apply(ctx, newDeploymentApplyConfiguration(namespace, input), metav1.ApplyOptions{
+		FieldManager: fieldManager,
+		Force:        force,
+		DryRun:       []string{metav1.DryRunAll},
+	})
+	if err != nil {
+		return "", applyError(input.deploymentName, namespace, err)
+	}
+	if live == nil {
+		return diffObjects(nil, result, deploymentKind, input.deploymentName)
+	}
+	return diffObjects(live, result, deploymentKind, input.deploymentName)
+}
+
+// previewDelete runs the delete task as a server-side dry run and shows the deployment that would be deleted,
+// together with the ReplicaSets and Pods that the foreground cascade would delete before it.
+func previewDelete(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	namespace string,
+	deploymentName string) (string, error) {
+	deploymentClient := clientset.AppsV1().Deployments(namespace)
+	live, err := deploymentClient.Get(ctx, deploymentName, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("cannot get deployment %v in namespace %v: %w", deploymentName, namespace, err)
+	}
+	replicaSets, err := getOwnedReplicaSets(ctx, clientset, live)
+	if err != nil {
+		return "", err
+	}
+	pods, err := getOwnedPods(ctx, clientset, live, replicaSets)
+	if err != nil {
+		return "", err
+	}
+	deletePolicy := metav1.DeletePropagationForeground
+	if err := deploymentClient.Delete(ctx, deploymentName, metav1.DeleteOptions{
+		PropagationPolicy: &deletePolicy,
+		DryRun:            []string{metav1.DryRunAll},
+	}); err != nil {
+		return "", fmt.Errorf("cannot preview deleting deployment %v in namespace %v: %w", deploymentName, namespace, err)
+	}
+
+	diff, err := diffObjects(live, nil, deploymentKind, deploymentName)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	b.WriteString(diff)
+	if len(replicaSets) == 0 && len(pods) == 0 {
+		b.WriteString("No dependent objects would be deleted.\n")
+		return b.String(), nil
+	}
+	b.WriteString("The foreground cascade would first delete:\n")
+	for _, rs := range replicaSets {
+		fmt.Fprintf(&b, "  %v\n", describeObject(appsv1.SchemeGroupVersion.WithKind("ReplicaSet"), rs.Name))
+	}
+	for _, pod := range pods {
+		fmt.Fprintf(&b, "  %v\n", describeObject(schema.GroupVersionKind{Version: "v1", Kind: "Pod"}, pod.Name))
+	}
+	return b.String(), nil
+}
+
+// diffObjects renders the change from the live object to the object that would result as a unified diff of their
+// YAML, where a nil object stands for one that does not exist.
+func diffObjects(
+	live runtime.Object,
+	result runtime.Object,
+	kind schema.GroupVersionKind,
+	name string) (string, error) {
+	from, err := previewYAML(live, kind)
+	if err != nil {
+		return "", err
+	}
+	to, err := previewYAML(result, kind)
+	if err != nil {
+		return "", err
+	}
+	description := describeObject(kind, name)
+	diff := unifiedDiff(description+" (live)", description+" (dry run)", from, to)
+	if diff == "" {
+		return fmt.Sprintf("No changes to %v.\n", description), nil
+	}
+	return diff, nil
+}
+
+// previewYAML renders an object for a diff, leaving out the managed fields that would only add noise.
+func previewYAML(obj runtime.Object, kind schema.GroupVersionKind) (string, error) {
+	if obj == nil {
+		return "", nil
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return "", fmt.Errorf("cannot render %v: %w", kind.Kind, err)
+	}
+	u := &unstructured.Unstructured{Object: content}
+	u.SetGroupVersionKind(kind)
+	u.SetManagedFields(nil)
+	data, err := yaml.Marshal(u.Object)
+	if err != nil {
+		return "", fmt.Errorf("cannot render %v: %w", kind.Kind, err)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		from string
+		to   string
+		want string
+	}{
+		{"equal", "a\nb\n", "a\nb\n", ""},
+		{"created", "", "a\nb\n", "--- old\n+++ new\n@@ -0,0 +1,2 @@\n+a\n+b\n"},
+		{"deleted", "a\n", "", "--- old\n+++ new\n@@ -1 +0,0 @@\n-a\n"},
+		{
+			"changed with context",
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			"1\n2\n3\n4\nfive\n6\n7\n8\n9\n",
+			"--- old\n+++ new\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+		{
+			"separate hunks",
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			"one\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\ntwelve\n",
+			"--- old\n+++ new\n@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n@@ -9,4 +9,4 @@\n 9\n 10\n 11\n-12\n+twelve\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := unifiedDiff("old", "new", test.from, test.to); got != test.want {
+				t.Errorf("Diff, got:\n%v\nwant:\n%v", got, test.want)
+			}
+		})
+	}
+}
+
+func newOwnedReplicaSet(deployment *appsv1.Deployment, name string) *appsv1.ReplicaSet {
+	return &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{
+		Namespace:       deployment.Namespace,
+		Name:            name,
+		UID:             types.UID("uid-" + name),
+		Labels:          deployment.Spec.Selector.MatchLabels,
+		OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(deployment, deploymentKind)},
+	}}
+}
+
+func newOwnedPod(rs *appsv1.ReplicaSet, name string) *v1.Pod {
+	pod := newPod(rs.Namespace, name)
+	pod.Labels = rs.Labels
+	pod.OwnerReferences = []metav1.OwnerReference{
+		*metav1.NewControllerRef(rs, appsv1.SchemeGroupVersion.WithKind("ReplicaSet")),
+	}
+	return pod
+}
+
+func getSampleDeployment(t *testing.T, server *fakeAPIServer, name string) *appsv1.Deployment {
+	u := server.get("deployments", "default", name)
+	if u == nil {
+		t.Fatalf("Deployment %v does not exist.", name)
+	}
+	var deployment appsv1.Deployment
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, &deployment); err != nil {
+		t.Fatalf("Cannot convert deployment %v: %v", name, err.Error())
+	}
+	return &deployment
+}
+
+func TestPreviewApply(t *testing.T) {
+	clientset, server := newFakeClientset(t, newNamespace("default"))
+
+	changes, err := previewApply(context.TODO(), clientset, newSampleInput(2), false)
+	if err != nil {
+		t.Fatalf("Cannot preview apply of a new deployment: %v", err.Error())
+	}
+	if !strings.Contains(changes, "+++ deployment.apps/demo (dry run)") || !strings.Contains(changes, "+  replicas: 2") {
+		t.Errorf("Preview of a new deployment does not show it, got:\n%v", changes)
+	}
+	if server.get("deployments", "default", "demo") != nil {
+		t.Fatalf("Deployment was created by a dry run.")
+	}
+
+	if err := applyK8sDeployment(context.TODO(), clientset, newSampleInput(2), false); err != nil {
+		t.Fatalf("Cannot apply deployment: %v", err.Error())
+	}
+	changes, err = previewApply(context.TODO(), clientset, newSampleInput(3), false)
+	if err != nil {
+		t.Fatalf("Cannot preview apply of an existing deployment: %v", err.Error())
+	}
+	if !strings.Contains(changes, "-  replicas: 2\n") || !strings.Contains(changes, "+  replicas: 3\n") {
+		t.Errorf("Preview does not show the change of replicas, got:\n%v", changes)
+	}
+	replicas, _, _ := unstructured.NestedInt64(server.get("deployments", "default", "demo").Object, "spec", "replicas")
+	if replicas != 2 {
+		t.Errorf("Replicas after a dry run, got: %d, want: %d.", replicas, 2)
+	}
+	requests := server.requestsOf("PATCH", "deployments")
+	if got := requests[len(requests)-1].query["dryRun"]; len(got) != 1 || got[0] != metav1.DryRunAll {
+		t.Errorf("Dry run of the preview, got: %v, want: %v.", got, metav1.DryRunAll)
+	}
+
+	changes, err = previewApply(context.TODO(), clientset, newSampleInput(2), false)
+	if err != nil {
+		t.Fatalf("Cannot preview apply without changes: %v", err.Error())
+	}
+	if changes != "No changes to deployment.apps/demo.\n" {
+		t.Errorf("Preview without changes, got:\n%v", changes)
+	}
+}
+
+func TestPreviewDelete(t *testing.T) {
+	clientset, server := newFakeClientset(t, newNamespace("default"), newPod("default", "unrelated"))
+	if err := createSampleDeployment(clientset, "demo", "demo"); err != nil {
+		t.Fatalf("Cannot create deployment: %v", err.Error())
+	}
+	deployment := getSampleDeployment(t, server, "demo")
+	rs := newOwnedReplicaSet(deployment, "demo-5d4f")
+	server.seed(rs)
+	server.seed(newOwnedPod(rs, "demo-5d4f-abcde"))
+
+	changes, err := previewDelete(context.TODO(), clientset, "default", "demo")
+	if err != nil {
+		t.Fatalf("Cannot preview delete: %v", err.Error())
+	}
+	for _, want := range []string{"-kind: Deployment\n", "  replicaset.apps/demo-5d4f\n", "  pod/demo-5d4f-abcde\n"} {
+		if !strings.Contains(changes, want) {
+			t.Errorf("Preview of delete does not contain %q, got:\n%v", want, changes)
+		}
+	}
+	if strings.Contains(changes, "unrelated") {
+		t.Errorf("Preview of delete lists a pod not owned by the deployment, got:\n%v", changes)
+	}
+	if server.get("deployments", "default", "demo") == nil {
+		t.Errorf("Deployment was deleted by a dry run.")
+	}
+}
+
+func TestDeleteCancelledAfterPreview(t *testing.T) {
+	_, server := newFakeClientset(t, newNamespace("default"))
+	path := writeKubeconfig(t, "local", map[string]string{"local": server.URL})
+	s, err := newSession(connectionOptions{kubeconfig: path})
+	if err != nil {
+		t.Fatalf("Cannot start session: %v", err.Error())
+	}
+	if err := createSampleDeployment(s.clientset, "demo", "demo"); err != nil {
+		t.Fatalf("Cannot create deployment: %v", err.Error())
+	}
+
+	reader := bufio.NewReader(strings.NewReader("delete\n\ndemo\ny\nn\ndelete\n\ndemo\ny\ny\ny\n"))
+	handleK8sCommand(reader, s)
+	if server.get("deployments", "default", "demo") == nil {
+		t.Fatalf("Deployment was deleted without confirmation.")
+	}
+	handleK8sCommand(reader, s)
+	if server.get("deployments", "default", "demo") != nil {
+		t.Errorf("Deployment was not deleted after confirmation.")
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/util/jsonpath"
+	"log"
+	"sigs.k8s.io/yaml"
+	"strings"
+	"unicode/utf8"
+)
+
+// outputFormats are the formats of -o, the ones taking an argument written as format=argument.
+var outputFormats = []string{"wide", "json", "yaml", "name", "jsonpath=<template>", "custom-columns=<header>:<path>,..."}
+
+// column is a column of a table, computing the cell of each object.
+type column struct {
+	header string
+	// wide columns are only shown with -o wide.
+	wide  bool
+	value func(obj runtime.Object) string
+}
+
+// resourcePrinter writes objects in one output format. Objects may come in several batches, e.g. one per page of a
+// list, and flush ends the output.
+type resourcePrinter interface {
+	printObjects(objects []runtime.Object) error
+	flush() error
+}
+
+// newPrinter returns the printer of an output format, where the empty format is a table of the given columns.
+func newPrinter(out io.Writer, format string, columns []column) (resourcePrinter, error) {
+	name, argument := format, ""
+	if i := strings.Index(format, "="); i >= 0 {
+		name, argument = format[:i], format[i+1:]
+	}
+	switch name {
+	case "":
+		return &tablePrinter{out: out, columns: narrowColumns(columns)}, nil
+	case "wide":
+		return &tablePrinter{out: out, columns: columns}, nil
+	case "json", "yaml":
+		return &listPrinter{out: out, format: name}, nil
+	case "name":
+		return &namePrinter{out: out}, nil
+	case "jsonpath":
+		parser := jsonpath.New("output")
+		if err := parser.Parse(relaxedJSONPath(argument)); err != nil {
+			return nil, fmt.Errorf("invalid output format %q: %w", format, err)
+		}
+		return &listPrinter{out: out, format: name, template: parser}, nil
+	case "custom-columns":
+		columns, err := parseCustomColumns(argument)
+		if err != nil {
+			return nil, fmt.Errorf("invalid output format %q: %w", format, err)
+		}
+		return &tablePrinter{out: out, columns: columns}, nil
+	}
+	return nil, fmt.Errorf("unsupported output format %q, use one of %v", format, strings.Join(outputFormats, ", "))
+}
+
+func narrowColumns(columns []column) []column {
+	var narrow []column
+	for _, c := range columns {
+		if !c.wide {
+			narrow = append(narrow, c)
+		}
+	}
+	return narrow
+}
+
+// parseCustomColumns parses columns written as HEADER:.json.path, comma separated, e.g.
+// "NAME:.metadata.name,NODE:.spec.nodeName".
+func parseCustomColumns(spec string) ([]column, error) {
+	var columns []column
+	for _, entry := range strings.Split(spec, ",") {
+		i := strings.Index(entry, ":")
+		if i <= 0 {
+			return nil, fmt.Errorf("column %q must be written as HEADER:.json.path", entry)
+		}
+		header := entry[:i]
+		parser := jsonpath.New(header).AllowMissingKeys(true)
+		if err := parser.Parse(relaxedJSONPath(entry[i+1:])); err != nil {
+			return nil, fmt.Errorf("column %v: %w", header, err)
+		}
+		columns = append(columns, column{header: header, value: func(obj runtime.Object) string {
+			content, err := objectContent(obj)
+			if err != nil {
+				return "<error>"
+			}
+			var cell bytes.Buffer
+			if err := parser.Execute(&cell, content); err != nil {
+				return "<error>"
+			}
+			if cell.Len() == 0 {
+				return "<none>"
+			}
+			return cell.String()
+		}})
+	}
+	return columns, nil
+}
+
+// relaxedJSONPath accepts a JSONPath without braces, e.g. ".metadata.name", like kubectl does.
+func relaxedJSONPath(path string) string {
+	if strings.Contains(path, "{") {
+		return path
+	}
+	return "{" + path + "}"
+}
+
+// objectKind returns the kind of an object, which typed objects returned by the clientset only know from the scheme.
+func objectKind(obj runtime.Object) schema.GroupVersionKind {
+	if kind := obj.GetObjectKind().GroupVersionKind(); kind.Kind != "" {
+		return kind
+	}
+	if kinds, _, err := scheme.Scheme.ObjectKinds(obj); err == nil && len(kinds) > 0 {
+		return kinds[0]
+	}
+	return schema.GroupVersionKind{}
+}
+
+// objectContent converts an object to its JSON fields, with its kind filled in and without managed fields.
+func objectContent(obj runtime.Object) (map[string]interface{}, error) {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	u := &unstructured.Unstructured{Object: content}
+	u.SetGroupVersionKind(objectKind(obj))
+	u.SetManagedFields(nil)
+	return u.Object, nil
+}
+
+// tablePrinter writes a row per object under a header, which is written once before the first batch. The widths of
+// the columns are those of the header and the first batch, so that the rows of later batches line up with them; a
+// later cell wider than its column pushes the rest of its row to the right instead of realigning the whole table.
+type tablePrinter struct {
+	out     io.Writer
+	columns []column
+	rows    int
+	widths  []int
+}
+
+// columnPadding is the number of spaces between the widest cell of a column and the next column.
+const columnPadding = 3
+
+func (p *tablePrinter) printObjects(objects []runtime.Object) error {
+	if len(objects) == 0 {
+		return nil
+	}
+	var rows [][]string
+	if p.rows == 0 {
+		var headers []string
+		for _, c := range p.columns {
+			headers = append(headers, c.header)
+		}
+		rows = append(rows, headers)
+	}
+	for _, obj := range objects {
+		var cells []string
+		for _, c := range p.columns {
+			cells = append(cells, c.value(obj))
+		}
+		rows = append(rows, cells)
+	}
+	if p.widths == nil {
+		p.widths = make([]int, len(p.columns))
+		for _, cells := range rows {
+			for i, cell := range cells {
+				if width := utf8.RuneCountInString(cell); width > p.widths[i] {
+					p.widths[i] = width
+				}
+			}
+		}
+	}
+
+	var line strings.Builder
+	for _, cells := range rows {
+		line.Reset()
+		for i, cell := range cells {
+			line.WriteString(cell)
+			if i < len(cells)-1 {
+				padding := p.widths[i] - utf8.RuneCountInString(cell)
+				if padding < 0 {
+					padding = 0
+				}
+				line.WriteString(strings.Repeat(" ", padding+columnPadding))
+			}
+		}
+		if _, err := fmt.Fprintln(p.out, line.String()); err != nil {
+			return err
+		}
+	}
+	p.rows += len(objects)
+	return nil
+}
+
+func (p *tablePrinter) flush() error {
+	if p.rows == 0 {
+		log.Printf("No resources found.")
+	}
+	return nil
+}
+
+// listPrinter collects the objects into a List, written as JSON or YAML or through a JSONPath template at the end.
+type listPrinter struct {
+	out      io.Writer
+	format   string
+	template *jsonpath.JSONPath
+	items    []interface{}
+}
+
+func (p *listPrinter) printObjects(objects []runtime.Object) error {
+	for _, obj := range objects {
+		content, err := objectContent(obj)
+		if err != nil {
+			return fmt.Errorf("cannot render %v: %w", objectKind(obj).Kind, err)
+		}
+		p.items = append(p.items, content)
+	}
+	return nil
+}
+
+func (p *listPrinter) flush() error {
+	items := p.items
+	if items == nil {
+		items = []interface{}{}
+	}
+	list := map[string]interface{}{"apiVersion": "v1", "kind": "List", "items": items}
+	switch p.format {
+	case "json":
+		data, err := json.MarshalIndent(list, "", "    ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(p.out, string(data))
+		return err
+	case "yaml":
+		data, err := yaml.Marshal(list)
+		if err != nil {
+			return err
+		}
+		_, err = p.out.Write(data)
+		return err
+	}
+	if err := p.template.Execute(p.out, list); err != nil {
+		return fmt.Errorf("cannot execute the JSONPath template: %w", err)
+	}
+	_, err := fmt.Fprintln(p.out)
+	return err
+}
+
+// namePrinter writes kind/name of each object, e.g. "pod/web-0", the way kubectl -o name does.
+type namePrinter struct {
+	out io.Writer
+}
+
+func (p *namePrinter) printObjects(objects []runtime.Object) error {
+	for _, obj := range objects {
+		accessor, err := meta.Accessor(obj)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintln(p.out, describeObject(objectKind(obj), accessor.GetName())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *namePrinter) flush() error {
+	return nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newSamplePods() []v1.Pod {
+	created := metav1.NewTime(time.Now().Add(-72 * time.Hour))
+	web := newPod("default", "web-0")
+	web.CreationTimestamp = created
+	web.Spec = v1.PodSpec{NodeName: "node-1", Containers: []v1.Container{{Name: "web"}, {Name: "sidecar"}}}
+	web.Status = v1.PodStatus{
+		Phase: v1.PodRunning,
+		PodIP: "10.0.0.7",
+		ContainerStatuses: []v1.ContainerStatus{
+			{Name: "web", Ready: true, RestartCount: 2},
+			{Name: "sidecar", RestartCount: 1, State: v1.ContainerState{
+				Waiting: &v1.ContainerStateWaiting{Reason: "CrashLoopBackOff"},
+			}},
+		},
+	}
+	pending := newPod("kube-system", "coredns-0")
+	pending.CreationTimestamp = created
+	return []v1.Pod{*web, *pending}
+}
+
+func TestPrintPodFormats(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"", "NAMESPACE     NAME        READY   STATUS             RESTARTS   AGE\n" +
+			"default       web-0       1/2     CrashLoopBackOff   3          3d\n" +
+			"kube-system   coredns-0   0/0     Pending            0          3d\n"},
+		{"wide", "default       web-0       1/2     CrashLoopBackOff   3          3d    10.0.0.7   node-1\n"},
+		{"name", "pod/web-0\npod/coredns-0\n"},
+		{"jsonpath={.items[*].metadata.name}", "web-0 coredns-0\n"},
+		{"jsonpath=.items[0].spec.nodeName", "node-1\n"},
+		{"custom-columns=NAME:.metadata.name,NODE:.spec.nodeName", "NAME        NODE\nweb-0       node-1\ncoredns-0   <none>\n"},
+		{"json", `"kind": "Pod",`},
+		{"yaml", "kind: List\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.format, func(t *testing.T) {
+			var out bytes.Buffer
+			printer, err := newPrinter(&out, test.format, podColumns)
+			if err != nil {
+				t.Fatalf("Cannot create printer: %v", err.Error())
+			}
+			if err := printer.printObjects(podObjects(newSamplePods())); err != nil {
+				t.Fatalf("Cannot print pods: %v", err.Error())
+			}
+			if err := printer.flush(); err != nil {
+				t.Fatalf("Cannot flush printer: %v", err.Error())
+			}
+			if !strings.Contains(out.String(), test.want) {
+				t.Errorf("Output, got:\n%v\nwant it to contain:\n%v", out.String(), test.want)
+			}
+		})
+	}
+}
+
+func TestPrinterFormatErrors(t *testing.T) {
+	for _, format := range []string{"table", "jsonpath={.items[", "custom-columns=NAME"} {
+		if _, err := newPrinter(&bytes.Buffer{}, format, podColumns); err == nil {
+			t.Errorf("Printer of format %q was created, want an error.", format)
+		}
+	}
+	if got := runSubcommand([]string{"view", "-o", "table"}); got != exitUsage {
+		t.Errorf("Exit code of view with an unsupported format, got: %d, want: %d.", got, exitUsage)
+	}
+}
+
+func TestPrintObjectsInBatches(t *testing.T) {
+	var out bytes.Buffer
+	printer, err := newPrinter(&out, "", podColumns)
+	if err != nil {
+		t.Fatalf("Cannot create printer: %v", err.Error())
+	}
+	pods := newSamplePods()
+	for i := range pods {
+		if err := printer.printObjects([]runtime.Object{&pods[i]}); err != nil {
+			t.Fatalf("Cannot print batch %d: %v", i, err.Error())
+		}
+	}
+	if err := printer.flush(); err != nil {
+		t.Fatalf("Cannot flush printer: %v", err.Error())
+	}
+	if got := strings.Count(out.String(), "NAMESPACE"); got != 1 {
+		t.Errorf("Headers of a table printed in batches, got: %d, want: %d.", got, 1)
+	}
+
+	// The rows of a later batch line up with the first one, even when their cells are narrower.
+	out.Reset()
+	printer, _ = newPrinter(&out, "", podColumns)
+	if err := printer.printObjects([]runtime.Object{&pods[0], &pods[1]}); err != nil {
+		t.Fatalf("Cannot print the first batch: %v", err.Error())
+	}
+	if err := printer.printObjects([]runtime.Object{&pods[0]}); err != nil {
+		t.Fatalf("Cannot print the second batch: %v", err.Error())
+	}
+	want := "NAMESPACE     NAME        READY   STATUS             RESTARTS   AGE\n" +
+		"default       web-0       1/2     CrashLoopBackOff   3          3d\n" +
+		"kube-system   coredns-0   0/0     Pending            0          3d\n" +
+		"default       web-0       1/2     CrashLoopBackOff   3          3d\n"
+	if out.String() != want {
+		t.Errorf("Table printed in batches, got:\n%v\nwant:\n%v", out.String(), want)
+	}
+}
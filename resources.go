@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"log"
+	"path"
+	"strings"
+	"text/tabwriter"
+)
+
+// resourceType is a type of resource served by the API server, in the preferred version of its group.
+type resourceType struct {
+	schema.GroupVersionResource
+	kind       string
+	singular   string
+	shortNames []string
+	namespaced bool
+}
+
+// podsResource is the type viewed when no other one is asked for.
+var podsResource = resourceType{
+	GroupVersionResource: v1.SchemeGroupVersion.WithResource("pods"),
+	kind:                 "Pod",
+	singular:             "pod",
+	shortNames:           []string{"po"},
+	namespaced:           true,
+}
+
+// String names the type the way kubectl does, e.g. "pods" or "deployments.apps".
+func (r resourceType) String() string {
+	if r.Group == "" {
+		return r.Resource
+	}
+	return r.Resource + "." + r.Group
+}
+
+func (r resourceType) isPods() bool {
+	return r.GroupResource() == podsResource.GroupResource()
+}
+
+// listOptions checks the selectors of objects of the type, see resourceListOptions, and the fields pods can be
+// selected by for pods.
+func (r resourceType) listOptions(labelSelector string, fieldSelector string) (metav1.ListOptions, error) {
+	if r.isPods() {
+		return podListOptions(labelSelector, fieldSelector)
+	}
+	return resourceListOptions(labelSelector, fieldSelector)
+}
+
+// getResourceTypes discovers the types of resources that the API server can list, in the order of their groups. Groups
+// that cannot be discovered, e.g. because their aggregated API server is down, are left out with a warning.
+func getResourceTypes(client discovery.DiscoveryInterface) ([]resourceType, error) {
+	lists, err := client.ServerPreferredResources()
+	if discovery.IsGroupDiscoveryFailedError(err) {
+		log.Printf("Leaving out resource types that cannot be discovered: %v", err)
+	} else if err != nil {
+		return nil, fmt.Errorf("cannot discover resource types: %w", err)
+	}
+	var types []resourceType
+	for _, list := range lists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, r := range list.APIResources {
+			if !sets.NewString(r.Verbs...).Has("list") {
+				continue
+			}
+			singular := r.SingularName
+			if singular == "" {
+				singular = strings.ToLower(r.Kind)
+			}
+			types = append(types, resourceType{
+				GroupVersionResource: gv.WithResource(r.Name),
+				kind:                 r.Kind,
+				singular:             singular,
+				shortNames:           r.ShortNames,
+				namespaced:           r.Namespaced,
+			})
+		}
+	}
+	return types, nil
+}
+
+// resolveResource finds the type of resource that a name given by the user refers to, the way kubectl does: by its
+// plural or singular name first, then by a short name, then by its kind, case-insensitively. The name may be
+// qualified by the group, e.g. "deployments.apps" or "deploy.apps", to choose between groups serving the same name.
+// Otherwise the first group wins, the core group coming first.
+func resolveResource(client discovery.DiscoveryInterface, name string) (resourceType, error) {
+	types, err := getResourceTypes(client)
+	if err != nil {
+		return resourceType{}, err
+	}
+	name = strings.ToLower(strings.TrimSpace(name))
+	matchers := []func(r resourceType, name string) bool{
+		func(r resourceType, name string) bool { return r.Resource == name || r.singular == name },
+		func(r resourceType, name string) bool { return sets.NewString(r.shortNames...).Has(name) },
+		func(r resourceType, name string) bool { return strings.ToLower(r.kind) == name },
+	}
+	for _, matches := range matchers {
+		for _, r := range types {
+			if matches(r, name) {
+				return r, nil
+			}
+		}
+		// A qualified name is the resource followed by its group.
+		for _, r := range types {
+			if group := "." + r.Group; r.Group != "" && strings.HasSuffix(name, group) &&
+				matches(r, strings.TrimSuffix(name, group)) {
+				return r, nil
+			}
+		}
+	}
+	return resourceType{}, apierrors.NewBadRequest(fmt.Sprintf(
+		"the server does not have a resource type %q, the api-resources task lists them", name))
+}
+
+// printResourceTypes prints the types of resources the API server can list, like kubectl api-resources.
+func printResourceTypes(out io.Writer, types []resourceType) error {
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSHORTNAMES\tAPIVERSION\tNAMESPACED\tKIND")
+	for _, r := range types {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%t\t%s\n", r.Resource, strings.Join(r.shortNames, ","),
+			r.GroupVersion().String(), r.namespaced, r.kind)
+	}
+	return w.Flush()
+}
+
+// resourceListOptions checks a label selector such as "app=demo,tier!=db" and a field selector such as
+// "metadata.name=demo", either of which may be empty, and returns the options listing the objects they select.
+func resourceListOptions(labelSelector string, fieldSelector string) (metav1.ListOptions, error) {
+	var options metav1.ListOptions
+	if labelSelector != "" {
+		selector, err := labels.Parse(labelSelector)
+		if err != nil {
+			return options, apierrors.NewBadRequest(fmt.Sprintf("invalid label selector %q: %v", labelSelector, err))
+		}
+		options.LabelSelector = selector.String()
+	}
+	if fieldSelector != "" {
+		selector, err := fields.ParseSelector(fieldSelector)
+		if err != nil {
+			return options, apierrors.NewBadRequest(fmt.Sprintf("invalid field selector %q: %v", fieldSelector, err))
+		}
+		options.FieldSelector = selector.String()
+	}
+	return options, nil
+}
+
+// viewResources lists the objects of a type in a namespace, or in every namespace when it is empty or the type is not
+// namespaced, and writes them in an output format page by page as they arrive. Pods are listed the way viewPods does.
+func viewResources(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	resource resourceType,
+	namespace string,
+	options metav1.ListOptions,
+	out io.Writer,
+	format string) error {
+	if resource.isPods() {
+		return viewPods(ctx, clientset, namespace, options, out, format)
+	}
+	printer, err := newPrinter(out, format, resourceColumns(resource))
+	if err != nil {
+		return err
+	}
+	if err := listResources(ctx, clientset, resource, namespace, options, printer.printObjects); err != nil {
+		return err
+	}
+	return printer.flush()
+}
+
+// listResources lists the objects of a type page by page, following the continue token, and hands each page to
+// handle. The objects are of their Go type when the client knows their kind, and unstructured otherwise.
+func listResources(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	resource resourceType,
+	namespace string,
+	options metav1.ListOptions,
+	handle func(objects []runtime.Object) error) error {
+	segments := []string{"/apis", resource.Group, resource.Version}
+	if resource.Group == "" {
+		segments = []string{"/api", resource.Version}
+	}
+	if resource.namespaced && namespace != "" {
+		segments = append(segments, "namespaces", namespace)
+	}
+	segments = append(segments, resource.Resource)
+	where := "all namespaces"
+	if !resource.namespaced {
+		where = "the cluster"
+	} else if namespace != "" {
+		where = "namespace " + namespace
+	}
+
+	limit := options.Limit
+	if limit == 0 {
+		limit = defaultPageSize
+	}
+	next := ""
+	for {
+		request := clientset.Discovery().RESTClient().Get().AbsPath(path.Join(segments...)).
+			Param("limit", fmt.Sprint(limit))
+		if options.LabelSelector != "" {
+			request = request.Param("labelSelector", options.LabelSelector)
+		}
+		if options.FieldSelector != "" {
+			request = request.Param("fieldSelector", options.FieldSelector)
+		}
+		if next != "" {
+			request = request.Param("continue", next)
+		}
+		data, err := request.Do(ctx).Raw()
+		if err != nil {
+			return fmt.Errorf("cannot get %v of %v: %w", resource, where, err)
+		}
+		var list unstructured.UnstructuredList
+		if err := list.UnmarshalJSON(data); err != nil {
+			return fmt.Errorf("cannot decode %v of %v: %w", resource, where, err)
+		}
+		objects := make([]runtime.Object, 0, len(list.Items))
+		for i := range list.Items {
+			obj, err := typedObject(&list.Items[i])
+			if err != nil {
+				return fmt.Errorf("cannot decode %v %v: %w", resource.singular, list.Items[i].GetName(), err)
+			}
+			objects = append(objects, obj)
+		}
+		if err := handle(objects); err != nil {
+			return err
+		}
+		if next = list.GetContinue(); next == "" {
+			return nil
+		}
+	}
+}
+
+// typedObject converts an object to its Go type when the client knows its kind, so that its columns can read it.
+func typedObject(u *unstructured.Unstructured) (runtime.Object, error) {
+	obj, err := scheme.Scheme.New(u.GroupVersionKind())
+	if runtime.IsNotRegisteredError(err) {
+		return u, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
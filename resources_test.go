@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestResolveResource(t *testing.T) {
+	clientset, _ := newFakeClientset(t)
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"pods", "pods"},
+		{"pod", "pods"},
+		{"po", "pods"},
+		{"deploy", "deployments.apps"},
+		{"Deployments", "deployments.apps"},
+		{"Deployment", "deployments.apps"},
+		{"deployments.apps", "deployments.apps"},
+		{"deploy.apps", "deployments.apps"},
+		{"svc", "services"},
+		{"no", "nodes"},
+		{"ing", "ingresses.networking.k8s.io"},
+		{"cronjob", "cronjobs.batch"},
+	}
+	for _, test := range tests {
+		resource, err := resolveResource(clientset.Discovery(), test.name)
+		if err != nil {
+			t.Errorf("Cannot resolve %q: %v", test.name, err)
+		} else if resource.String() != test.want {
+			t.Errorf("Resource type of %q, got: %v, want: %v.", test.name, resource, test.want)
+		}
+	}
+
+	for _, name := range []string{"widgets", "deployments.batch", "scale"} {
+		if _, err := resolveResource(clientset.Discovery(), name); !apierrors.IsBadRequest(err) {
+			t.Errorf("Error resolving %q, got: %v, want: a bad request.", name, err)
+		}
+	}
+}
+
+func TestPrintResourceTypes(t *testing.T) {
+	clientset, _ := newFakeClientset(t)
+	types, err := getResourceTypes(clientset.Discovery())
+	if err != nil {
+		t.Fatalf("Cannot discover resource types: %v", err.Error())
+	}
+	var out bytes.Buffer
+	if err := printResourceTypes(&out, types); err != nil {
+		t.Fatalf("Cannot print resource types: %v", err.Error())
+	}
+	for _, want := range []string{
+		`(?m)^NAME +SHORTNAMES +APIVERSION +NAMESPACED +KIND$`,
+		`(?m)^deployments +deploy +apps/v1 +true +Deployment$`,
+		`(?m)^nodes +no +v1 +false +Node$`,
+	} {
+		if !regexp.MustCompile(want).MatchString(out.String()) {
+			t.Errorf("Resource types do not match %q, got:\n%v", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "scale") {
+		t.Errorf("Resource types contain a subresource, got:\n%v", out.String())
+	}
+}
+
+func TestViewResources(t *testing.T) {
+	replicas := int32(3)
+	deployment := func(name string) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: &replicas,
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": name}},
+				Template: v1.PodTemplateSpec{Spec: v1.PodSpec{Containers: []v1.Container{{Name: "web", Image: "nginx"}}}},
+			},
+			Status: appsv1.DeploymentStatus{ReadyReplicas: 2, UpdatedReplicas: 3, AvailableReplicas: 2},
+		}
+	}
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-1", Labels: map[string]string{"node-role.kubernetes.io/control-plane": ""}},
+		Spec:       v1.NodeSpec{Unschedulable: true},
+		Status: v1.NodeStatus{
+			Conditions: []v1.NodeCondition{{Type: v1.NodeReady, Status: v1.ConditionTrue}},
+			Addresses:  []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: "10.0.0.1"}},
+			NodeInfo:   v1.NodeSystemInfo{KubeletVersion: "v1.21.2"},
+		},
+	}
+	clientset, server := newFakeClientset(t, newNamespace("default"), deployment("web"), deployment("api"), node)
+
+	tests := []struct {
+		kind   string
+		format string
+		want   []string
+	}{
+		{"deploy", "", []string{
+			`(?m)^NAMESPACE +NAME +READY +UP-TO-DATE +AVAILABLE +AGE$`,
+			`(?m)^default +api +2/3 +3 +2 +\S+$`,
+			`(?m)^default +web +2/3 +3 +2 +\S+$`,
+		}},
+		{"deployments", "wide", []string{
+			`(?m)^default +web +2/3 +3 +2 +\S+ +web +nginx +app=web$`,
+		}},
+		{"nodes", "wide", []string{
+			`(?m)^NAME +STATUS +ROLES +AGE +VERSION +INTERNAL-IP +EXTERNAL-IP +OS-IMAGE +KERNEL-VERSION +CONTAINER-RUNTIME$`,
+			`(?m)^node-1 +Ready,SchedulingDisabled +control-plane +\S+ +v1.21.2 +10.0.0.1 +<none>`,
+		}},
+		{"deploy", "name", []string{`(?m)^deployment.apps/api\ndeployment.apps/web$`}},
+	}
+	for _, test := range tests {
+		resource, err := resolveResource(clientset.Discovery(), test.kind)
+		if err != nil {
+			t.Fatalf("Cannot resolve %q: %v", test.kind, err)
+		}
+		var out bytes.Buffer
+		err = viewResources(context.TODO(), clientset, resource, "", metav1.ListOptions{Limit: 1}, &out, test.format)
+		if err != nil {
+			t.Fatalf("Cannot view %v: %v", test.kind, err)
+		}
+		for _, want := range test.want {
+			if !regexp.MustCompile(want).MatchString(out.String()) {
+				t.Errorf("View of %v -o %q does not match %q, got:\n%v", test.kind, test.format, want, out.String())
+			}
+		}
+	}
+	if got := len(server.requestsOf("GET", "deployments")); got != 3*2 {
+		t.Errorf("Lists of deployment pages, got: %d, want: %d.", got, 3*2)
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"log"
+	"strconv"
+)
+
+// revisionAnnotation numbers the ReplicaSets of a deployment, the highest one being the current rollout.
+const revisionAnnotation = "deployment.kubernetes.io/revision"
+
+// stuckContainerReasons are the reasons of waiting containers that a rollout does not get over by itself.
+var stuckContainerReasons = sets.NewString("ImagePullBackOff", "CrashLoopBackOff", "InvalidImageName")
+
+// rolloutError tells why a rollout cannot complete, naming the stuck pod when there is one.
+type rolloutError struct {
+	deployment string
+	pod        string
+	reason     string
+	message    string
+}
+
+func (e *rolloutError) Error() string {
+	if e.pod != "" {
+		return fmt.Sprintf("rollout of deployment %v failed, pod %v is in %v: %v", e.deployment, e.pod, e.reason, e.message)
+	}
+	return fmt.Sprintf("rollout of deployment %v failed with %v: %v", e.deployment, e.reason, e.message)
+}
+
+// waitForRollout watches the deployment and its pods until the rollout completes, or until the controller observed a
+// paused deployment, logging the progress whenever it changes. It fails as soon as the progress deadline of the
+// deployment is exceeded or a pod of the current ReplicaSet is stuck pulling its image or crashing.
+func waitForRollout(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	namespace string,
+	deploymentName string) error {
+	deploymentsClient := clientset.AppsV1().Deployments(namespace)
+	deployment, err := deploymentsClient.Get(ctx, deploymentName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("cannot get deployment %v in namespace %v: %w", deploymentName, namespace, err)
+	}
+	podOptions, err := selectorListOptions(deployment.Spec.Selector)
+	if err != nil {
+		return fmt.Errorf("invalid selector of deployment %v: %w", deploymentName, err)
+	}
+
+	watchDeployment := func() (watch.Interface, error) {
+		return deploymentsClient.Watch(ctx, metav1.ListOptions{
+			FieldSelector:   fields.OneTermEqualSelector("metadata.name", deploymentName).String(),
+			ResourceVersion: deployment.ResourceVersion,
+		})
+	}
+	watchPods := func() (watch.Interface, error) {
+		return clientset.CoreV1().Pods(namespace).Watch(ctx, podOptions)
+	}
+	deploymentWatch, err := watchDeployment()
+	if err != nil {
+		return fmt.Errorf("cannot watch deployment %v: %w", deploymentName, err)
+	}
+	defer func() { deploymentWatch.Stop() }()
+	podWatch, err := watchPods()
+	if err != nil {
+		return fmt.Errorf("cannot watch pods of deployment %v: %w", deploymentName, err)
+	}
+	defer func() { podWatch.Stop() }()
+
+	progress := ""
+	incomplete := func() error {
+		return fmt.Errorf("rollout of deployment %v did not complete, last %v: %w", deploymentName, progress, ctx.Err())
+	}
+	// Each watch is restarted after a growing delay, from the start again once it delivers an event.
+	deploymentBackoff, podBackoff := watchRestartBackoff, watchRestartBackoff
+	for {
+		done, current, err := rolloutStatus(deployment)
+		if err != nil {
+			return err
+		}
+		if done && deployment.Spec.Paused {
+			log.Printf("Deployment %v settled, %v.", deploymentName, current)
+			return nil
+		} else if done {
+			log.Printf("Deployment %v successfully rolled out, %v.", deploymentName, current)
+			return nil
+		}
+		if current != progress {
+			log.Printf("Waiting for deployment %v: %v.", deploymentName, current)
+			progress = current
+		}
+
+		select {
+		case event, ok := <-deploymentWatch.ResultChan():
+			if !ok || event.Type == watch.Error {
+				// The API server ends watches after a while, or the resource version is too old to resume from.
+				deploymentWatch.Stop()
+				if err := waitForBackoff(ctx, &deploymentBackoff); err != nil {
+					return incomplete()
+				}
+				if deployment, err = deploymentsClient.Get(ctx, deploymentName, metav1.GetOptions{}); err != nil {
+					return fmt.Errorf("cannot get deployment %v in namespace %v: %w", deploymentName, namespace, err)
+				}
+				if deploymentWatch, err = watchDeployment(); err != nil {
+					return fmt.Errorf("cannot watch deployment %v: %w", deploymentName, err)
+				}
+				continue
+			}
+			deploymentBackoff = watchRestartBackoff
+			if event.Type == watch.Deleted {
+				return fmt.Errorf("deployment %v was deleted during the rollout", deploymentName)
+			} else if d, ok := event.Object.(*appsv1.Deployment); ok {
+				deployment = d
+			}
+		case event, ok := <-podWatch.ResultChan():
+			if !ok || event.Type == watch.Error {
+				podWatch.Stop()
+				if err := waitForBackoff(ctx, &podBackoff); err != nil {
+					return incomplete()
+				}
+				if podWatch, err = watchPods(); err != nil {
+					return fmt.Errorf("cannot watch pods of deployment %v: %w", deploymentName, err)
+				}
+				continue
+			}
+			podBackoff = watchRestartBackoff
+			if pod, ok := event.Object.(*v1.Pod); ok && event.Type != watch.Deleted {
+				if err := checkRolloutPod(ctx, clientset, deployment, pod); err != nil {
+					return err
+				}
+			}
+		case <-ctx.Done():
+			return incomplete()
+		}
+	}
+}
+
+// rolloutStatus tells whether the rollout of the deployment is complete, like kubectl rollout status, together with
+// its progress such as "2/4 replicas ready".
+func rolloutStatus(deployment *appsv1.Deployment) (bool, string, error) {
+	replicas := replicasOrDefault(deployment.Spec.Replicas)
+	status := deployment.Status
+	progress := fmt.Sprintf("%d/%d replicas ready", status.ReadyReplicas, replicas)
+	if deployment.Generation > status.ObservedGeneration {
+		return false, progress + ", waiting for the controller to observe the new spec", nil
+	}
+	if deployment.Spec.Paused {
+		// A paused deployment does not roll out until it is resumed, so it is as settled as it gets.
+		return true, progress + ", rollout paused", nil
+	}
+
+	for _, c := range status.Conditions {
+		if c.Type == appsv1.DeploymentProgressing && c.Reason == "ProgressDeadlineExceeded" {
+			return false, progress, &rolloutError{deployment: deployment.Name, reason: c.Reason, message: c.Message}
+		}
+	}
+	if status.UpdatedReplicas < replicas {
+		return false, fmt.Sprintf("%v, %d/%d updated", progress, status.UpdatedReplicas, replicas), nil
+	}
+	if status.Replicas > status.UpdatedReplicas {
+		return false, fmt.Sprintf("%v, %d old replicas pending termination", progress,
+			status.Replicas-status.UpdatedReplicas), nil
+	}
+	if status.AvailableReplicas < status.UpdatedReplicas {
+		return false, fmt.Sprintf("%v, %d/%d available", progress, status.AvailableReplicas, replicas), nil
+	}
+	for _, c := range status.Conditions {
+		if c.Type == appsv1.DeploymentAvailable && c.Status == v1.ConditionTrue {
+			return true, progress, nil
+		}
+	}
+	return false, progress + ", waiting for the deployment to become available", nil
+}
+
+// checkRolloutPod fails the rollout when a container of the pod is stuck and the pod belongs to the current
+// ReplicaSet of the deployment, as pods of older ReplicaSets are on their way out anyway.
+func checkRolloutPod(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	deployment *appsv1.Deployment,
+	pod *v1.Pod) error {
+	reason, message := stuckContainer(pod)
+	if reason == "" {
+		return nil
+	}
+	replicaSets, err := getOwnedReplicaSets(ctx, clientset, deployment)
+	if err != nil {
+		return err
+	}
+	current := currentReplicaSet(replicaSets)
+	if current == nil || !metav1.IsControlledBy(pod, current) {
+		return nil
+	}
+	return &rolloutError{deployment: deployment.Name, pod: pod.Name, reason: reason, message: message}
+}
+
+// stuckContainer returns the reason and message of the first container of the pod that waits for a reason in
+// stuckContainerReasons, or an empty reason if there is none.
+func stuckContainer(pod *v1.Pod) (string, string) {
+	for _, s := range containerStatuses(pod) {
+		if s.State.Waiting != nil && stuckContainerReasons.Has(s.State.Waiting.Reason) {
+			message := s.State.Waiting.Message
+			if message == "" {
+				message = "container " + s.Name
+			}
+			return s.State.Waiting.Reason, message
+		}
+	}
+	return "", ""
+}
+
+// currentReplicaSet returns the ReplicaSet with the highest revision, or nil if there is none.
+func currentReplicaSet(replicaSets []appsv1.ReplicaSet) *appsv1.ReplicaSet {
+	var current *appsv1.ReplicaSet
+	for i := range replicaSets {
+		if current == nil || replicaSetRevision(&replicaSets[i]) > replicaSetRevision(current) {
+			current = &replicaSets[i]
+		}
+	}
+	return current
+}
+
+func replicaSetRevision(rs *appsv1.ReplicaSet) int64 {
+	revision, err := strconv.ParseInt(rs.Annotations[revisionAnnotation], 10, 64)
+	if err != nil {
+		return 0
+	}
+	return revision
+}
+
+// isRolloutError tells whether a rollout failed for a reason the user has to fix, as opposed to an API error.
+func isRolloutError(err error) bool {
+	var rollout *rolloutError
+	return errors.As(err, &rollout)
+}
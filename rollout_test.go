@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startRolloutWait waits for the rollout of the sample deployment in the background, returning once it watches.
+func startRolloutWait(t *testing.T, clientset kubernetes.Interface, server *fakeAPIServer) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		done <- waitForRollout(context.TODO(), clientset, "default", "demo")
+	}()
+	server.waitForWatches("deployments", 1)
+	server.waitForWatches("pods", 1)
+	return done
+}
+
+func setDeploymentStatus(t *testing.T, server *fakeAPIServer, status appsv1.DeploymentStatus) {
+	deployment := getSampleDeployment(t, server, "demo")
+	deployment.Status = status
+	server.seed(deployment)
+}
+
+func newStuckPod(rs *appsv1.ReplicaSet, name string, reason string) *v1.Pod {
+	pod := newOwnedPod(rs, name)
+	pod.Status.ContainerStatuses = []v1.ContainerStatus{{
+		Name:  "kubernetes-bootcamp",
+		State: v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: reason, Message: "Back-off " + reason}},
+	}}
+	return pod
+}
+
+func TestWaitForRollout(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	clientset, server := newFakeClientset(t, newNamespace("default"))
+	if err := createSampleDeployment(clientset, "demo", "demo"); err != nil {
+		t.Fatalf("Cannot create deployment: %v", err.Error())
+	}
+	done := startRolloutWait(t, clientset, server)
+
+	setDeploymentStatus(t, server, appsv1.DeploymentStatus{
+		ObservedGeneration: 1, Replicas: 4, UpdatedReplicas: 4, ReadyReplicas: 2, AvailableReplicas: 2,
+	})
+	setDeploymentStatus(t, server, appsv1.DeploymentStatus{
+		ObservedGeneration: 1, Replicas: 4, UpdatedReplicas: 4, ReadyReplicas: 4, AvailableReplicas: 4,
+		Conditions: []appsv1.DeploymentCondition{{Type: appsv1.DeploymentAvailable, Status: v1.ConditionTrue}},
+	})
+	if err := <-done; err != nil {
+		t.Fatalf("Rollout failed: %v", err.Error())
+	}
+	for _, want := range []string{"0/4 replicas ready", "2/4 replicas ready", "successfully rolled out, 4/4 replicas ready"} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("Progress of the rollout does not contain %q, got:\n%v", want, logs.String())
+		}
+	}
+}
+
+func TestWaitForRolloutBacksOffDroppedWatches(t *testing.T) {
+	clientset, server := newFakeClientset(t, newNamespace("default"))
+	if err := createSampleDeployment(clientset, "demo", "demo"); err != nil {
+		t.Fatalf("Cannot create deployment: %v", err.Error())
+	}
+	done := startRolloutWait(t, clientset, server)
+
+	// A watch the API server ends is not restarted right away, but it is restarted.
+	server.endWatches("pods")
+	time.Sleep(100 * time.Millisecond)
+	if watches := len(server.requestsOf("WATCH", "pods")); watches != 1 {
+		t.Errorf("Watches of pods right after one was dropped, got: %d, want: 1.", watches)
+	}
+	server.waitForWatches("pods", 1)
+	setDeploymentStatus(t, server, appsv1.DeploymentStatus{
+		ObservedGeneration: 1, Replicas: 4, UpdatedReplicas: 4, ReadyReplicas: 4, AvailableReplicas: 4,
+		Conditions: []appsv1.DeploymentCondition{{Type: appsv1.DeploymentAvailable, Status: v1.ConditionTrue}},
+	})
+	if err := <-done; err != nil {
+		t.Fatalf("Rollout failed: %v", err.Error())
+	}
+	if watches := len(server.requestsOf("WATCH", "pods")); watches != 2 {
+		t.Errorf("Watches of pods, got: %d, want: 2.", watches)
+	}
+}
+
+func TestWaitForRolloutFailsFast(t *testing.T) {
+	t.Run("StuckPod", func(t *testing.T) {
+		clientset, server := newFakeClientset(t, newNamespace("default"))
+		if err := createSampleDeployment(clientset, "demo", "demo"); err != nil {
+			t.Fatalf("Cannot create deployment: %v", err.Error())
+		}
+		deployment := getSampleDeployment(t, server, "demo")
+		old := newOwnedReplicaSet(deployment, "demo-old")
+		old.Annotations = map[string]string{revisionAnnotation: "1"}
+		current := newOwnedReplicaSet(deployment, "demo-new")
+		current.Annotations = map[string]string{revisionAnnotation: "2"}
+		server.seed(old)
+		server.seed(current)
+		done := startRolloutWait(t, clientset, server)
+
+		server.seed(newStuckPod(old, "demo-old-abcde", "CrashLoopBackOff"))
+		server.seed(newStuckPod(current, "demo-new-fghij", "ImagePullBackOff"))
+		err := <-done
+		var rollout *rolloutError
+		if !errors.As(err, &rollout) {
+			t.Fatalf("Error of a stuck rollout, got: %v, want a rollout error.", err)
+		}
+		if rollout.pod != "demo-new-fghij" || rollout.reason != "ImagePullBackOff" {
+			t.Errorf("Stuck pod, got: %v in %v, want: %v in %v.", rollout.pod, rollout.reason,
+				"demo-new-fghij", "ImagePullBackOff")
+		}
+		if exitCodeForError(err) != exitRolloutFailed {
+			t.Errorf("Exit code of a stuck rollout, got: %d, want: %d.", exitCodeForError(err), exitRolloutFailed)
+		}
+	})
+
+	t.Run("ProgressDeadlineExceeded", func(t *testing.T) {
+		clientset, server := newFakeClientset(t, newNamespace("default"))
+		if err := createSampleDeployment(clientset, "demo", "demo"); err != nil {
+			t.Fatalf("Cannot create deployment: %v", err.Error())
+		}
+		done := startRolloutWait(t, clientset, server)
+
+		setDeploymentStatus(t, server, appsv1.DeploymentStatus{
+			ObservedGeneration: 1, Replicas: 4, UpdatedReplicas: 4,
+			Conditions: []appsv1.DeploymentCondition{{
+				Type:    appsv1.DeploymentProgressing,
+				Status:  v1.ConditionFalse,
+				Reason:  "ProgressDeadlineExceeded",
+				Message: `ReplicaSet "demo-5d4f" has timed out progressing.`,
+			}},
+		})
+		err := <-done
+		if !isRolloutError(err) || !strings.Contains(err.Error(), "ProgressDeadlineExceeded") {
+			t.Errorf("Error of a rollout past its deadline, got: %v, want a rollout error.", err)
+		}
+	})
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+	"log"
+	"time"
+)
+
+// restartedAtAnnotation is stamped on the pod template to restart the pods, the same one kubectl rollout restart
+// uses. Changing the template makes the controller replace every pod with a rolling update.
+const restartedAtAnnotation = "kubectl.kubernetes.io/restartedAt"
+
+// replicaCounts summarizes the replicas of a deployment, e.g. "4 desired, 4 updated, 3 ready, 3 available".
+func replicaCounts(deployment *appsv1.Deployment) string {
+	status := deployment.Status
+	return fmt.Sprintf("%d desired, %d updated, %d ready, %d available",
+		replicasOrDefault(deployment.Spec.Replicas), status.UpdatedReplicas, status.ReadyReplicas, status.AvailableReplicas)
+}
+
+// changeDeployment runs a change of the deployment and logs its replicas before and after. When wait is set, the
+// replicas after are those once the deployment settled.
+func changeDeployment(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	namespace string,
+	deploymentName string,
+	wait bool,
+	change func(deployment *appsv1.Deployment) error) error {
+	deploymentsClient := clientset.AppsV1().Deployments(namespace)
+	before, err := deploymentsClient.Get(ctx, deploymentName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("cannot get deployment %v in namespace %v: %w", deploymentName, namespace, err)
+	}
+	log.Printf("Replicas of deployment %v before: %v.", deploymentName, replicaCounts(before))
+	if err := change(before); err != nil {
+		return err
+	}
+	if wait {
+		if err := waitForRollout(ctx, clientset, namespace, deploymentName); err != nil {
+			return err
+		}
+	}
+	after, err := deploymentsClient.Get(ctx, deploymentName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("cannot get deployment %v in namespace %v: %w", deploymentName, namespace, err)
+	}
+	log.Printf("Replicas of deployment %v after: %v.", deploymentName, replicaCounts(after))
+	return nil
+}
+
+// scaleK8sDeployment sets the replicas of the deployment through its scale subresource, which leaves the rest of the
+// spec alone. It reads the scale again and retries when it was modified in the meantime.
+func scaleK8sDeployment(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	namespace string,
+	deploymentName string,
+	replicas int32,
+	wait bool) error {
+	if replicas < 0 {
+		return invalidDeployment(deploymentName, field.ErrorList{
+			field.Invalid(replicasPath, replicas, "must be greater than or equal to 0"),
+		})
+	}
+	return changeDeployment(ctx, clientset, namespace, deploymentName, wait, func(*appsv1.Deployment) error {
+		deploymentsClient := clientset.AppsV1().Deployments(namespace)
+		var previous int32
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			scale, err := deploymentsClient.GetScale(ctx, deploymentName, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			previous = scale.Spec.Replicas
+			scale.Spec.Replicas = replicas
+			_, err = deploymentsClient.UpdateScale(ctx, deploymentName, scale, metav1.UpdateOptions{})
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("cannot scale deployment %v in namespace %v: %w", deploymentName, namespace, err)
+		}
+		log.Printf("Scaled deployment %v from %d to %d replicas.", deploymentName, previous, replicas)
+		return nil
+	})
+}
+
+// pauseK8sDeployment pauses or resumes the rollout of the deployment. While it is paused, changes of the pod template
+// are recorded but not rolled out, and scaling still applies.
+func pauseK8sDeployment(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	namespace string,
+	deploymentName string,
+	paused bool,
+	wait bool) error {
+	verb, state := "pause", "paused"
+	if !paused {
+		verb, state = "resume", "running"
+	}
+	return changeDeployment(ctx, clientset, namespace, deploymentName, wait, func(deployment *appsv1.Deployment) error {
+		if deployment.Spec.Paused == paused {
+			log.Printf("Rollout of deployment %v is already %v.", deploymentName, state)
+			return nil
+		}
+		patch := []byte(fmt.Sprintf(`{"spec":{"paused":%t}}`, paused))
+		_, err := clientset.AppsV1().Deployments(namespace).Patch(ctx, deploymentName, types.StrategicMergePatchType,
+			patch, metav1.PatchOptions{})
+		if err != nil {
+			return fmt.Errorf("cannot %v deployment %v in namespace %v: %w", verb, deploymentName, namespace, err)
+		}
+		log.Printf("Rollout of deployment %v is %v.", deploymentName, state)
+		return nil
+	})
+}
+
+// restartK8sDeployment restarts the pods of the deployment with a rolling update by stamping the current time on its
+// pod template. A paused deployment has to be resumed first, as it would not roll out the change.
+func restartK8sDeployment(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	namespace string,
+	deploymentName string,
+	wait bool) error {
+	return changeDeployment(ctx, clientset, namespace, deploymentName, wait, func(deployment *appsv1.Deployment) error {
+		if deployment.Spec.Paused {
+			return invalidDeployment(deploymentName, field.ErrorList{
+				field.Forbidden(field.NewPath("spec", "paused"), "cannot restart a paused deployment, resume it first"),
+			})
+		}
+		patch := []byte(fmt.Sprintf(`{"spec":{"template":{"metadata":{"annotations":{%q:%q}}}}}`,
+			restartedAtAnnotation, time.Now().Format(time.RFC3339)))
+		result, err := clientset.AppsV1().Deployments(namespace).Patch(ctx, deploymentName, types.StrategicMergePatchType,
+			patch, metav1.PatchOptions{})
+		if err != nil {
+			return fmt.Errorf("cannot restart deployment %v in namespace %v: %w", deploymentName, namespace, err)
+		}
+		log.Printf("Restarting deployment %v (generation %d).", deploymentName, result.Generation)
+		return nil
+	})
+}
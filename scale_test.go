@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScale(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	clientset, server := newFakeClientset(t, newNamespace("default"))
+	if err := createSampleDeployment(clientset, "demo", "demo"); err != nil {
+		t.Fatalf("Cannot create deployment: %v", err.Error())
+	}
+	server.failNext("PUT", "deployments/scale", apierrors.NewConflict(
+		schema.GroupResource{Group: "apps", Resource: "deployments"}, "demo", errors.New("the object has been modified")))
+
+	if err := scaleK8sDeployment(context.TODO(), clientset, "default", "demo", 2, false); err != nil {
+		t.Fatalf("Cannot scale deployment: %v", err.Error())
+	}
+	if got := *getSampleDeployment(t, server, "demo").Spec.Replicas; got != 2 {
+		t.Errorf("Replicas after scaling, got: %d, want: %d.", got, 2)
+	}
+	if got := len(server.requestsOf("PUT", "deployments/scale")); got != 2 {
+		t.Errorf("Updates of the scale with one conflict, got: %d, want: %d.", got, 2)
+	}
+	if got := len(server.requestsOf("PUT", "deployments")); got != 0 {
+		t.Errorf("Updates of the whole deployment, got: %d, want the scale subresource only.", got)
+	}
+	for _, want := range []string{
+		"Replicas of deployment demo before: 4 desired",
+		"Scaled deployment demo from 4 to 2 replicas.",
+		"Replicas of deployment demo after: 2 desired",
+	} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("Output of scale does not contain %q, got:\n%v", want, logs.String())
+		}
+	}
+
+	err := scaleK8sDeployment(context.TODO(), clientset, "default", "demo", -1, false)
+	if exitCodeForError(err) != exitInvalid {
+		t.Errorf("Error of scaling to negative replicas, got: %v, want an invalid input.", err)
+	}
+}
+
+func TestPauseAndResume(t *testing.T) {
+	clientset, server := newFakeClientset(t, newNamespace("default"))
+	if err := createSampleDeployment(clientset, "demo", "demo"); err != nil {
+		t.Fatalf("Cannot create deployment: %v", err.Error())
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := pauseK8sDeployment(context.TODO(), clientset, "default", "demo", true, false); err != nil {
+			t.Fatalf("Cannot pause deployment: %v", err.Error())
+		}
+	}
+	if !getSampleDeployment(t, server, "demo").Spec.Paused {
+		t.Errorf("Deployment is not paused.")
+	}
+	if got := len(server.requestsOf("PATCH", "deployments")); got != 1 {
+		t.Errorf("Patches of pausing twice, got: %d, want: %d.", got, 1)
+	}
+
+	err := restartK8sDeployment(context.TODO(), clientset, "default", "demo", false)
+	if exitCodeForError(err) != exitInvalid {
+		t.Errorf("Error of restarting a paused deployment, got: %v, want an invalid input.", err)
+	}
+
+	if err := pauseK8sDeployment(context.TODO(), clientset, "default", "demo", false, false); err != nil {
+		t.Fatalf("Cannot resume deployment: %v", err.Error())
+	}
+	if getSampleDeployment(t, server, "demo").Spec.Paused {
+		t.Errorf("Deployment is still paused after resuming it.")
+	}
+}
+
+func TestRestartWaitsUntilSettled(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	clientset, server := newFakeClientset(t, newNamespace("default"))
+	if err := createSampleDeployment(clientset, "demo", "demo"); err != nil {
+		t.Fatalf("Cannot create deployment: %v", err.Error())
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- restartK8sDeployment(context.TODO(), clientset, "default", "demo", true)
+	}()
+	server.waitForWatches("deployments", 1)
+	server.waitForWatches("pods", 1)
+	deployment := getSampleDeployment(t, server, "demo")
+	if deployment.Spec.Template.Annotations[restartedAtAnnotation] == "" {
+		t.Errorf("Pod template has no %v annotation after restart.", restartedAtAnnotation)
+	}
+	setDeploymentStatus(t, server, appsv1.DeploymentStatus{
+		ObservedGeneration: deployment.Generation, Replicas: 4, UpdatedReplicas: 4, ReadyReplicas: 4, AvailableReplicas: 4,
+		Conditions: []appsv1.DeploymentCondition{{Type: appsv1.DeploymentAvailable, Status: v1.ConditionTrue}},
+	})
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Cannot restart deployment: %v", err.Error())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Restart did not end after the deployment settled.")
+	}
+	want := "Replicas of deployment demo after: 4 desired, 4 updated, 4 ready, 4 available."
+	if !strings.Contains(logs.String(), want) {
+		t.Errorf("Output of restart does not contain %q, got:\n%v", want, logs.String())
+	}
+}
+
+func TestScaleCommandRequiresReplicas(t *testing.T) {
+	if got := runSubcommand([]string{"scale", "--name", "demo"}); got != exitUsage {
+		t.Errorf("Exit code of scale without --replicas, got: %d, want: %d.", got, exitUsage)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"k8s.io/client-go/kubernetes"
+	"os"
+	"sort"
+	"text/tabwriter"
+)
+
+// session is the connection of the interactive prompt, which can be switched to another context of the kubeconfig
+// without restarting.
+type session struct {
+	options   connectionOptions
+	clientset kubernetes.Interface
+	context   string
+	cluster   string
+	namespace string
+}
+
+func newSession(options connectionOptions) (*session, error) {
+	s := &session{}
+	if err := s.connect(options); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// connect replaces the connection of the session, leaving the session untouched if the new one cannot be set up.
+func (s *session) connect(options connectionOptions) error {
+	clientConfig := newClientConfig(options)
+	clientset, err := newClientset(clientConfig)
+	if err != nil {
+		return err
+	}
+
+	contextName, cluster := "in-cluster", "in-cluster"
+	if raw, err := clientConfig.RawConfig(); err == nil && (raw.CurrentContext != "" || options.context != "") {
+		contextName = raw.CurrentContext
+		if options.context != "" {
+			contextName = options.context
+		}
+		if c, ok := raw.Contexts[contextName]; ok {
+			cluster = c.Cluster
+		}
+	}
+	if options.cluster != "" {
+		cluster = options.cluster
+	}
+	namespace, _, err := clientConfig.Namespace()
+	if err != nil || namespace == "" {
+		namespace = "default"
+	}
+
+	s.options = options
+	s.clientset = clientset
+	s.context = contextName
+	s.cluster = cluster
+	s.namespace = namespace
+	return nil
+}
+
+// useContext switches the session to another context of the loaded kubeconfig.
+func (s *session) useContext(contextName string) error {
+	raw, err := newClientConfig(s.options).RawConfig()
+	if err != nil {
+		return fmt.Errorf("cannot load kubeconfig: %w", err)
+	}
+	if _, ok := raw.Contexts[contextName]; !ok {
+		return fmt.Errorf("context %q does not exist in the kubeconfig", contextName)
+	}
+	options := s.options
+	options.context = contextName
+	options.cluster = ""
+	return s.connect(options)
+}
+
+// run runs the API calls of a task, see runCancellable.
+func (s *session) run(command func(ctx context.Context) error) error {
+	return runCancellable(s.options.timeout, command)
+}
+
+// printNamespaces prints the existing namespaces as a hint for the namespace prompt.
+func (s *session) printNamespaces() {
+	_ = s.run(func(ctx context.Context) error {
+		printNamespaces(ctx, s.clientset)
+		return nil
+	})
+}
+
+// prompt describes where the tasks of the session run, e.g. "[prod-cluster/prod/default]".
+func (s *session) prompt() string {
+	return fmt.Sprintf("[%s/%s/%s]", s.cluster, s.context, s.namespace)
+}
+
+func (s *session) printContexts() error {
+	raw, err := newClientConfig(s.options).RawConfig()
+	if err != nil {
+		return fmt.Errorf("cannot load kubeconfig: %w", err)
+	}
+	if len(raw.Contexts) == 0 {
+		fmt.Println("No contexts in the loaded kubeconfig.")
+		return nil
+	}
+
+	var names []string
+	for name := range raw.Contexts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "CURRENT\tNAME\tCLUSTER\tNAMESPACE")
+	for _, name := range names {
+		marker := ""
+		if name == s.context {
+			marker = "*"
+		}
+		c := raw.Contexts[name]
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", marker, name, c.Cluster, c.Namespace)
+	}
+	return w.Flush()
+}
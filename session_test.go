@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+func TestSessionUseContext(t *testing.T) {
+	_, staging := newFakeClientset(t, newNamespace("default"))
+	_, prod := newFakeClientset(t, newNamespace("default"))
+	path := writeKubeconfig(t, "staging", map[string]string{"staging": staging.URL, "prod": prod.URL})
+
+	s, err := newSession(connectionOptions{kubeconfig: path})
+	if err != nil {
+		t.Fatalf("Cannot start session: %v", err.Error())
+	}
+	if got := s.prompt(); got != "[staging/staging/default]" {
+		t.Errorf("Prompt, got: %v, want: %v.", got, "[staging/staging/default]")
+	}
+
+	if err := s.useContext("missing"); err == nil {
+		t.Errorf("Switching to a missing context succeeded, want an error.")
+	}
+	if s.context != "staging" {
+		t.Errorf("Context after a failed switch, got: %v, want: %v.", s.context, "staging")
+	}
+
+	reader := bufio.NewReader(strings.NewReader("use-context\nprod\ncreate\n\ndemo\ndemo\ndemo\nnginx\n\n\n\n\n"))
+	handleK8sCommand(reader, s)
+	if got := s.prompt(); got != "[prod/prod/default]" {
+		t.Errorf("Prompt after switching, got: %v, want: %v.", got, "[prod/prod/default]")
+	}
+	handleK8sCommand(reader, s)
+	if prod.get("deployments", "default", "demo") == nil {
+		t.Errorf("Deployment was not created in the switched context.")
+	}
+	if staging.get("deployments", "default", "demo") != nil {
+		t.Errorf("Deployment was created in the previous context.")
+	}
+}
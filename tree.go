@@ -0,0 +1,396 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	"sort"
+	"strings"
+)
+
+// treeNode is an object in the tree of the objects that another one owns or selects, with whether it is ready and
+// a summary of its status.
+type treeNode struct {
+	kind     string
+	name     string
+	ready    bool
+	status   string
+	children []*treeNode
+}
+
+func (n *treeNode) String() string {
+	marker := "✓"
+	if !n.ready {
+		marker = "✗"
+	}
+	return fmt.Sprintf("%s %s/%s: %s", marker, n.kind, n.name, n.status)
+}
+
+// writeTree writes a tree with one object per line, e.g.
+//
+//	✓ Deployment/web: 2/2 ready, 2 updated, 2 available
+//	└── ✓ ReplicaSet/web-5d8f: revision 1, 2/2 ready
+//	    ├── ✓ Pod/web-5d8f-a: Running, 1/1 ready, on node-1
+//	    └── ✓ Pod/web-5d8f-b: Running, 1/1 ready, on node-2
+func writeTree(out io.Writer, root *treeNode) {
+	fmt.Fprintln(out, root)
+	writeTreeChildren(out, root.children, "")
+}
+
+func writeTreeChildren(out io.Writer, children []*treeNode, indent string) {
+	for i, child := range children {
+		branch, next := "├── ", "│   "
+		if i == len(children)-1 {
+			branch, next = "└── ", "    "
+		}
+		fmt.Fprintf(out, "%s%s%v\n", indent, branch, child)
+		writeTreeChildren(out, child.children, indent+next)
+	}
+}
+
+// printTree writes the tree of the objects an object owns, through their owner references, or selects, for a
+// service through its endpoint slices. The object is a Deployment, StatefulSet, DaemonSet, ReplicaSet, Job, CronJob
+// or Service.
+func printTree(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	resource resourceType,
+	namespace string,
+	name string,
+	out io.Writer) error {
+	root, err := getTree(ctx, clientset, resource, namespace, name)
+	if err != nil {
+		return err
+	}
+	writeTree(out, root)
+	return nil
+}
+
+// getTree gets an object and the objects under it, those of each level being listed at the same time.
+func getTree(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	resource resourceType,
+	namespace string,
+	name string) (*treeNode, error) {
+	switch resource.GroupResource() {
+	case appsv1.Resource("deployments"):
+		return getDeploymentTree(ctx, clientset, namespace, name)
+	case appsv1.Resource("statefulsets"):
+		sts, err := clientset.AppsV1().StatefulSets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("cannot get stateful set %v in namespace %v: %w", name, namespace, err)
+		}
+		replicas := replicasOrDefault(sts.Spec.Replicas)
+		root := &treeNode{kind: "StatefulSet", name: name, ready: sts.Status.ReadyReplicas == replicas,
+			status: fmt.Sprintf("%d/%d ready", sts.Status.ReadyReplicas, replicas)}
+		return root, addOwnedPods(ctx, clientset, root, sts, sts.Spec.Selector)
+	case appsv1.Resource("daemonsets"):
+		ds, err := clientset.AppsV1().DaemonSets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("cannot get daemon set %v in namespace %v: %w", name, namespace, err)
+		}
+		root := &treeNode{kind: "DaemonSet", name: name,
+			ready:  ds.Status.NumberReady == ds.Status.DesiredNumberScheduled,
+			status: fmt.Sprintf("%d/%d ready", ds.Status.NumberReady, ds.Status.DesiredNumberScheduled)}
+		return root, addOwnedPods(ctx, clientset, root, ds, ds.Spec.Selector)
+	case appsv1.Resource("replicasets"):
+		rs, err := clientset.AppsV1().ReplicaSets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("cannot get replica set %v in namespace %v: %w", name, namespace, err)
+		}
+		root := replicaSetNode(rs)
+		return root, addOwnedPods(ctx, clientset, root, rs, rs.Spec.Selector)
+	case batchv1.Resource("jobs"):
+		job, err := clientset.BatchV1().Jobs(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("cannot get job %v in namespace %v: %w", name, namespace, err)
+		}
+		root := jobNode(job)
+		return root, addOwnedPods(ctx, clientset, root, job, job.Spec.Selector)
+	case batchv1.Resource("cronjobs"):
+		return getCronJobTree(ctx, clientset, namespace, name)
+	case v1.Resource("services"):
+		return getServiceTree(ctx, clientset, namespace, name)
+	}
+	return nil, apierrors.NewBadRequest(fmt.Sprintf("a tree starts from a deployment, stateful set, daemon set, "+
+		"replica set, job, cron job or service, not from %v", resource))
+}
+
+// getDeploymentTree returns the deployment with its ReplicaSets from the newest revision to the oldest, each with
+// its pods.
+func getDeploymentTree(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	namespace string,
+	name string) (*treeNode, error) {
+	deployment, err := clientset.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("cannot get deployment %v in namespace %v: %w", name, namespace, err)
+	}
+	var replicaSets []appsv1.ReplicaSet
+	var pods []v1.Pod
+	err = gather(ctx,
+		func(ctx context.Context) error {
+			var err error
+			replicaSets, err = getOwnedReplicaSets(ctx, clientset, deployment)
+			return err
+		},
+		func(ctx context.Context) error {
+			var err error
+			pods, err = listSelectedPods(ctx, clientset, namespace, deployment.Spec.Selector)
+			return err
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	replicas := replicasOrDefault(deployment.Spec.Replicas)
+	status := deployment.Status
+	root := &treeNode{kind: "Deployment", name: name,
+		ready: status.ReadyReplicas == replicas && status.UpdatedReplicas == replicas &&
+			status.AvailableReplicas == replicas,
+		status: fmt.Sprintf("%d/%d ready, %d updated, %d available", status.ReadyReplicas, replicas,
+			status.UpdatedReplicas, status.AvailableReplicas)}
+	sort.Slice(replicaSets, func(i, j int) bool {
+		return replicaSetRevision(&replicaSets[i]) > replicaSetRevision(&replicaSets[j])
+	})
+	for i := range replicaSets {
+		node := replicaSetNode(&replicaSets[i])
+		node.children = ownedPodNodes(pods, &replicaSets[i])
+		root.children = append(root.children, node)
+	}
+	return root, nil
+}
+
+// getCronJobTree returns the cron job with the jobs it started from the newest to the oldest, each with its pods.
+func getCronJobTree(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	namespace string,
+	name string) (*treeNode, error) {
+	cronJob, err := clientset.BatchV1().CronJobs(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("cannot get cron job %v in namespace %v: %w", name, namespace, err)
+	}
+	var jobs []batchv1.Job
+	var pods []v1.Pod
+	err = gather(ctx,
+		func(ctx context.Context) error {
+			list, err := clientset.BatchV1().Jobs(namespace).List(ctx, metav1.ListOptions{})
+			if err != nil {
+				return fmt.Errorf("cannot get jobs of cron job %v: %w", name, err)
+			}
+			jobs = list.Items
+			return nil
+		},
+		func(ctx context.Context) error {
+			// The job controller labels the pods of every job with its name.
+			list, err := clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: "job-name"})
+			if err != nil {
+				return fmt.Errorf("cannot get pods of cron job %v: %w", name, err)
+			}
+			pods = list.Items
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	status := "schedule " + cronJob.Spec.Schedule
+	suspended := cronJob.Spec.Suspend != nil && *cronJob.Spec.Suspend
+	if suspended {
+		status += ", suspended"
+	} else if last := cronJob.Status.LastScheduleTime; last != nil {
+		status += ", last scheduled " + age(last.Time) + " ago"
+	}
+	root := &treeNode{kind: "CronJob", name: name, ready: !suspended, status: status}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[j].CreationTimestamp.Before(&jobs[i].CreationTimestamp) })
+	for i := range jobs {
+		if metav1.IsControlledBy(&jobs[i], cronJob) {
+			node := jobNode(&jobs[i])
+			node.children = ownedPodNodes(pods, &jobs[i])
+			root.children = append(root.children, node)
+		}
+	}
+	return root, nil
+}
+
+// getServiceTree returns the service with its endpoint slices, each with the pods behind its endpoints. The
+// readiness of a pod is that of its endpoint, which decides whether the service sends it traffic.
+func getServiceTree(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	namespace string,
+	name string) (*treeNode, error) {
+	service, err := clientset.CoreV1().Services(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("cannot get service %v in namespace %v: %w", name, namespace, err)
+	}
+	var slices []discoveryv1.EndpointSlice
+	pods := map[string]*v1.Pod{}
+	err = gather(ctx,
+		func(ctx context.Context) error {
+			list, err := clientset.DiscoveryV1().EndpointSlices(namespace).List(ctx, metav1.ListOptions{
+				LabelSelector: labels.SelectorFromSet(labels.Set{discoveryv1.LabelServiceName: name}).String(),
+			})
+			if err != nil {
+				return fmt.Errorf("cannot get endpoint slices of service %v: %w", name, err)
+			}
+			slices = list.Items
+			return nil
+		},
+		func(ctx context.Context) error {
+			if len(service.Spec.Selector) == 0 {
+				return nil
+			}
+			list, err := listSelectedPods(ctx, clientset, namespace, &metav1.LabelSelector{MatchLabels: service.Spec.Selector})
+			for i := range list {
+				pods[list[i].Name] = &list[i]
+			}
+			return err
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	root := &treeNode{kind: "Service", name: name, ready: service.Spec.Type == v1.ServiceTypeExternalName,
+		status: fmt.Sprintf("%v, cluster IP %v", service.Spec.Type, orNone(service.Spec.ClusterIP))}
+	sort.Slice(slices, func(i, j int) bool { return slices[i].Name < slices[j].Name })
+	for _, slice := range slices {
+		node := &treeNode{kind: "EndpointSlice", name: slice.Name}
+		ready := 0
+		for _, endpoint := range slice.Endpoints {
+			child := endpointNode(endpoint, pods)
+			if child.ready {
+				ready++
+			}
+			node.children = append(node.children, child)
+		}
+		node.ready = ready == len(slice.Endpoints) && ready > 0
+		node.status = fmt.Sprintf("%d/%d endpoints ready, ports %v", ready, len(slice.Endpoints),
+			endpointPorts(slice.Ports))
+		root.ready = root.ready || ready > 0
+		root.children = append(root.children, node)
+	}
+	return root, nil
+}
+
+// endpointNode returns the pod behind an endpoint, or the endpoint itself when it is not a pod.
+func endpointNode(endpoint discoveryv1.Endpoint, pods map[string]*v1.Pod) *treeNode {
+	ready := endpoint.Conditions.Ready == nil || *endpoint.Conditions.Ready
+	addresses := strings.Join(endpoint.Addresses, ",")
+	if ref := endpoint.TargetRef; ref == nil || ref.Kind != "Pod" {
+		return &treeNode{kind: "Endpoint", name: addresses, ready: ready, status: "not a pod"}
+	} else if pod := pods[ref.Name]; pod == nil {
+		return &treeNode{kind: "Pod", name: ref.Name, ready: ready, status: addresses + ", pod not found"}
+	} else {
+		node := podNode(pod)
+		node.ready = ready
+		node.status = addresses + ", " + node.status
+		return node
+	}
+}
+
+func endpointPorts(ports []discoveryv1.EndpointPort) string {
+	var entries []string
+	for _, p := range ports {
+		entry := "?"
+		if p.Port != nil {
+			entry = fmt.Sprint(*p.Port)
+		}
+		if p.Protocol != nil {
+			entry += "/" + string(*p.Protocol)
+		}
+		if p.Name != nil && *p.Name != "" {
+			entry = *p.Name + ":" + entry
+		}
+		entries = append(entries, entry)
+	}
+	return orNone(strings.Join(entries, ","))
+}
+
+// addOwnedPods adds the pods that the owner controls under its node, listing those its selector matches.
+func addOwnedPods(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	node *treeNode,
+	owner metav1.Object,
+	selector *metav1.LabelSelector) error {
+	pods, err := listSelectedPods(ctx, clientset, owner.GetNamespace(), selector)
+	if err != nil {
+		return err
+	}
+	node.children = ownedPodNodes(pods, owner)
+	return nil
+}
+
+// listSelectedPods lists the pods of a namespace matching the label selector of a workload or service.
+func listSelectedPods(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	namespace string,
+	selector *metav1.LabelSelector) ([]v1.Pod, error) {
+	options, err := selectorListOptions(selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector %v: %w", metav1.FormatLabelSelector(selector), err)
+	}
+	list, err := clientset.CoreV1().Pods(namespace).List(ctx, options)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get pods matching %v: %w", options.LabelSelector, err)
+	}
+	return list.Items, nil
+}
+
+// ownedPodNodes returns the pods controlled by the owner, by name.
+func ownedPodNodes(pods []v1.Pod, owner metav1.Object) []*treeNode {
+	var nodes []*treeNode
+	for i := range pods {
+		if metav1.IsControlledBy(&pods[i], owner) {
+			nodes = append(nodes, podNode(&pods[i]))
+		}
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].name < nodes[j].name })
+	return nodes
+}
+
+func podNode(pod *v1.Pod) *treeNode {
+	status := fmt.Sprintf("%v, %v ready", podStatus(pod), podReady(pod))
+	if restarts := podRestarts(pod); restarts > 0 {
+		status += ", " + plural(int(restarts), "restart")
+	}
+	if pod.Spec.NodeName != "" {
+		status += ", on " + pod.Spec.NodeName
+	}
+	return &treeNode{kind: "Pod", name: pod.Name, ready: isPodHealthy(pod), status: status}
+}
+
+func replicaSetNode(rs *appsv1.ReplicaSet) *treeNode {
+	replicas := replicasOrDefault(rs.Spec.Replicas)
+	return &treeNode{kind: "ReplicaSet", name: rs.Name, ready: rs.Status.ReadyReplicas == replicas,
+		status: fmt.Sprintf("revision %d, %d/%d ready", replicaSetRevision(rs), rs.Status.ReadyReplicas, replicas)}
+}
+
+// jobNode tells whether the job completed or failed, or how far it got.
+func jobNode(job *batchv1.Job) *treeNode {
+	node := &treeNode{kind: "Job", name: job.Name, ready: true,
+		status: fmt.Sprintf("%d active, %d succeeded, %d failed", job.Status.Active, job.Status.Succeeded,
+			job.Status.Failed)}
+	for _, c := range job.Status.Conditions {
+		if c.Type == batchv1.JobComplete && c.Status == v1.ConditionTrue {
+			node.status = "complete, " + node.status
+		} else if c.Type == batchv1.JobFailed && c.Status == v1.ConditionTrue {
+			node.ready = false
+			node.status = fmt.Sprintf("failed (%v), %v", orNone(c.Reason), node.status)
+		}
+	}
+	return node
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"testing"
+)
+
+// treeOf resolves the kind and writes the tree of the object.
+func treeOf(t *testing.T, clientset kubernetes.Interface, kind string, name string) string {
+	t.Helper()
+	resource, err := resolveResource(clientset.Discovery(), kind)
+	if err != nil {
+		t.Fatalf("Cannot resolve %q: %v", kind, err)
+	}
+	var out bytes.Buffer
+	if err := printTree(context.TODO(), clientset, resource, "default", name, &out); err != nil {
+		t.Fatalf("Cannot print the tree of %v %v: %v", kind, name, err)
+	}
+	return out.String()
+}
+
+func TestDeploymentTree(t *testing.T) {
+	clientset, server := newFakeClientset(t, newNamespace("default"))
+	if err := createSampleDeployment(clientset, "demo", "kubernetes-bootcamp"); err != nil {
+		t.Fatalf("Cannot create the sample deployment: %v", err.Error())
+	}
+	deployment := getSampleDeployment(t, server, "kubernetes-bootcamp")
+	old := newRevision(deployment, "kubernetes-bootcamp-1", "1", 0)
+	current := newRevision(deployment, "kubernetes-bootcamp-2", "2", 1)
+	ready := newOwnedPod(current, "kubernetes-bootcamp-2-a")
+	ready.Spec = newRunningPod("default", "").Spec
+	ready.Spec.NodeName = "node-1"
+	ready.Status = newRunningPod("default", "").Status
+	crashing := newOwnedPod(current, "kubernetes-bootcamp-2-b")
+	crashing.Spec = ready.Spec
+	crashing.Status = newWaitingPod("CrashLoopBackOff", "").Status
+	crashing.Status.ContainerStatuses[0].RestartCount = 3
+	stray := newPod("default", "stray")
+	stray.Labels = deployment.Spec.Selector.MatchLabels
+	for _, obj := range []runtime.Object{old, current, ready, crashing, stray} {
+		server.seed(obj)
+	}
+
+	want := "✗ Deployment/kubernetes-bootcamp: 0/4 ready, 0 updated, 0 available\n" +
+		"├── ✗ ReplicaSet/kubernetes-bootcamp-2: revision 2, 1/4 ready\n" +
+		"│   ├── ✓ Pod/kubernetes-bootcamp-2-a: Running, 1/1 ready, on node-1\n" +
+		"│   └── ✗ Pod/kubernetes-bootcamp-2-b: CrashLoopBackOff, 0/1 ready, 3 restarts, on node-1\n" +
+		"└── ✗ ReplicaSet/kubernetes-bootcamp-1: revision 1, 0/4 ready\n"
+	if got := treeOf(t, clientset, "deploy", "kubernetes-bootcamp"); got != want {
+		t.Errorf("Tree of a deployment, got:\n%v\nwant:\n%v", got, want)
+	}
+}
+
+func TestServiceTree(t *testing.T) {
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+		Spec: v1.ServiceSpec{
+			Type:      v1.ServiceTypeClusterIP,
+			ClusterIP: "10.96.0.10",
+			Selector:  map[string]string{"app": "web"},
+		},
+	}
+	running := newRunningPod("default", "web-0")
+	running.Labels = service.Spec.Selector
+	starting := newWaitingPod("ContainerCreating", "")
+	starting.Name = "web-1"
+	starting.Labels = service.Spec.Selector
+	ready, notReady := true, false
+	port, protocol, portName := int32(8080), v1.ProtocolTCP, "http"
+	slice := &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      "web-x7k2p",
+			Labels:    map[string]string{discoveryv1.LabelServiceName: "web"},
+		},
+		AddressType: discoveryv1.AddressTypeIPv4,
+		Endpoints: []discoveryv1.Endpoint{
+			{Addresses: []string{"10.0.0.5"}, Conditions: discoveryv1.EndpointConditions{Ready: &ready},
+				TargetRef: &v1.ObjectReference{Kind: "Pod", Name: "web-0"}},
+			{Addresses: []string{"10.0.0.6"}, Conditions: discoveryv1.EndpointConditions{Ready: &notReady},
+				TargetRef: &v1.ObjectReference{Kind: "Pod", Name: "web-1"}},
+		},
+		Ports: []discoveryv1.EndpointPort{{Name: &portName, Port: &port, Protocol: &protocol}},
+	}
+	other := slice.DeepCopy()
+	other.Name = "api-9d8s1"
+	other.Labels = map[string]string{discoveryv1.LabelServiceName: "api"}
+	clientset, _ := newFakeClientset(t, newNamespace("default"), service, running, starting, slice, other)
+
+	want := "✓ Service/web: ClusterIP, cluster IP 10.96.0.10\n" +
+		"└── ✗ EndpointSlice/web-x7k2p: 1/2 endpoints ready, ports http:8080/TCP\n" +
+		"    ├── ✓ Pod/web-0: 10.0.0.5, Running, 1/1 ready\n" +
+		"    └── ✗ Pod/web-1: 10.0.0.6, ContainerCreating, 0/1 ready\n"
+	if got := treeOf(t, clientset, "svc", "web"); got != want {
+		t.Errorf("Tree of a service, got:\n%v\nwant:\n%v", got, want)
+	}
+}
+
+func TestCronJobTree(t *testing.T) {
+	cronJob := &batchv1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "backup", UID: types.UID("uid-backup")},
+		Spec:       batchv1.CronJobSpec{Schedule: "0 3 * * *"},
+	}
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      "backup-27000000",
+			UID:       types.UID("uid-backup-27000000"),
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(cronJob, batchv1.SchemeGroupVersion.WithKind("CronJob")),
+			},
+		},
+		Status: batchv1.JobStatus{
+			Failed: 1,
+			Conditions: []batchv1.JobCondition{
+				{Type: batchv1.JobFailed, Status: v1.ConditionTrue, Reason: "BackoffLimitExceeded"},
+			},
+		},
+	}
+	pod := newPod("default", "backup-27000000-abcde")
+	pod.Labels = map[string]string{"job-name": job.Name}
+	pod.OwnerReferences = []metav1.OwnerReference{
+		*metav1.NewControllerRef(job, batchv1.SchemeGroupVersion.WithKind("Job")),
+	}
+	pod.Spec.Containers = []v1.Container{{Name: "backup"}}
+	pod.Status.Phase = v1.PodFailed
+	clientset, _ := newFakeClientset(t, newNamespace("default"), cronJob, job, pod)
+
+	want := "✓ CronJob/backup: schedule 0 3 * * *\n" +
+		"└── ✗ Job/backup-27000000: failed (BackoffLimitExceeded), 0 active, 0 succeeded, 1 failed\n" +
+		"    └── ✗ Pod/backup-27000000-abcde: Failed, 0/1 ready\n"
+	if got := treeOf(t, clientset, "cronjob", "backup"); got != want {
+		t.Errorf("Tree of a cron job, got:\n%v\nwant:\n%v", got, want)
+	}
+}
+
+func TestTreeOfUnsupportedKind(t *testing.T) {
+	clientset, _ := newFakeClientset(t, newNamespace("default"))
+	resource, err := resolveResource(clientset.Discovery(), "cm")
+	if err != nil {
+		t.Fatalf("Cannot resolve %q: %v", "cm", err)
+	}
+	err = printTree(context.TODO(), clientset, resource, "default", "settings", &bytes.Buffer{})
+	if !apierrors.IsBadRequest(err) {
+		t.Errorf("Error of the tree of a config map, got: %v, want a bad request.", err)
+	}
+}
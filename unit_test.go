@@ -1,134 +1,131 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/json"
 	"k8s.io/client-go/kubernetes"
-	"log"
-	"os"
-	"strings"
 	"testing"
-	"time"
 )
 
 func Test(t *testing.T) {
-	clientset := connectToK8s()
+	clientset, server := newFakeClientset(t,
+		newNamespace("default"),
+		newNamespace("kube-system"),
+		newPod("default", "web-0"),
+		newPod("default", "web-1"),
+		newPod("kube-system", "coredns-0"))
 	deploymentName := "kubernetes-bootcamp"
-	var newPods map[string]bool
 
 	t.Run("Create", func(t *testing.T) {
-		newPods = createUnitTest(t, clientset, deploymentName)
+		createUnitTest(t, clientset, deploymentName)
 	})
 
-	// Test log: https://stackoverflow.com/questions/44119951/how-to-check-a-log-output-in-go-test
 	t.Run("View", func(t *testing.T) {
-		viewUnitTest(t, clientset, newPods)
+		viewUnitTest(t, clientset)
 	})
 
 	t.Run("Delete", func(t *testing.T) {
-		deletedPods := deleteUnitTest(t, clientset, deploymentName)
-		checkIsValidDelete(t, deletedPods, newPods)
+		deleteUnitTest(t, clientset, server, deploymentName)
 	})
 }
 
-func checkIsValidDelete(t *testing.T, deletedPods map[string]bool, newPods map[string]bool) {
-	t.Logf("Deleted pods are %v", deletedPods)
-	for k, _ := range newPods {
-		if _, ok := deletedPods[k]; !ok {
-			t.Errorf("The pod %v created but not deleted.", k)
-		}
+func newNamespace(name string) *v1.Namespace {
+	return &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}
+}
+
+func newPod(namespace string, name string) *v1.Pod {
+	return &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}}
+}
+
+func viewUnitTest(t *testing.T, clientset kubernetes.Interface) {
+	podsOfNamespace, err := getPodsOfNamespace(context.TODO(), clientset, "default", metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("Cannot get pods of namespace default: %v", err.Error())
 	}
-	for k, _ := range deletedPods {
-		if _, ok := newPods[k]; !ok {
-			t.Errorf("The pod %v deleted but was not created in test.", k)
-		}
+	pods := convertPodListToMapOfName(podsOfNamespace)
+	checkPodNames(t, pods, "default/web-0", "default/web-1")
+
+	allPods, err := getPods(context.TODO(), clientset, "", metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("Cannot get pods of all namespaces: %v", err.Error())
 	}
+	pods = convertPodListToMapOfName(allPods)
+	checkPodNames(t, pods, "default/web-0", "default/web-1", "kube-system/coredns-0")
 }
 
-func viewUnitTest(t *testing.T, clientset *kubernetes.Clientset, newPods map[string]bool) {
-	var buf bytes.Buffer
-	log.SetOutput(&buf)
-	defer func() {
-		log.SetOutput(os.Stderr)
-	}()
-
-	getPodsOfNamespace(clientset, "default")
-	time.Sleep(10 * time.Second)
-
-	logs := strings.Split(buf.String(), "\n")
-	pods := make(map[string]bool)
-	for _, l := range logs {
-		if l == "" {
-			break
-		}
-		index := strings.LastIndex(l, ":")
-		pods[strings.Trim(l[(index+1):], " \n")] = true
+func checkPodNames(t *testing.T, pods map[string]bool, want ...string) {
+	if len(pods) != len(want) {
+		t.Errorf("Number of pods, got: %d, want: %d.", len(pods), len(want))
 	}
-
-	t.Logf("New pods are %v", newPods)
-	t.Logf("Pods are %v", pods)
-	for k, _ := range newPods {
-		if _, ok := pods[k]; !ok {
-			t.Errorf("Failed to get the pod %v", k)
+	for _, name := range want {
+		if _, ok := pods[name]; !ok {
+			t.Errorf("Failed to get the pod %v, got: %v.", name, pods)
 		}
 	}
 }
 
-func createUnitTest(t *testing.T, clientset *kubernetes.Clientset, deploymentName string) map[string]bool {
-	result := make(map[string]bool)
+func createUnitTest(t *testing.T, clientset kubernetes.Interface, deploymentName string) {
 	originalDeployments := getDeploymentsOfDefaultNamespace(t, clientset)
-	existingDeployments := convertDeploymentListToMapOfName(originalDeployments)
-	originalPods := getPodsOfDefaultNamespace(t, clientset)
-	existingPods := convertPodListToMapOfName(originalPods)
 
-	createSampleDeployment(clientset, "demo", deploymentName)
-	time.Sleep(40*time.Second)
+	if err := createSampleDeployment(clientset, "demo", deploymentName); err != nil {
+		t.Fatalf("Cannot create deployment: %v", err.Error())
+	}
 
 	deployments := getDeploymentsOfDefaultNamespace(t, clientset)
 	numOfNewDeployments := len(deployments) - len(originalDeployments)
 	if numOfNewDeployments != 1 {
 		t.Errorf("Number of deployments newly created, got: %d, want: %d.", numOfNewDeployments, 1)
 	}
-	pods := getPodsOfDefaultNamespace(t, clientset)
-	numOfNewPods := len(pods) - len(originalPods)
-	if numOfNewPods != 4 {
-		t.Errorf("Number of pods newly created, got: %d, want: %d.", numOfNewPods, 4)
+	if _, ok := convertDeploymentListToMapOfName(deployments)[deploymentName]; !ok {
+		t.Fatalf("Deployment %v was not created, got: %v.", deploymentName, deployments)
 	}
 
-	for _, d := range deployments {
-		if _, ok := existingDeployments[d.Name]; !ok {
-			t.Logf("The new deployment is: %s", d.Name)
-			if d.Name != deploymentName {
-				t.Errorf("Deployment newly created, got: \"%s\", want: \"%s\".", d.Name, deploymentName)
-			}
-		}
+	d, err := clientset.AppsV1().Deployments("default").Get(context.TODO(), deploymentName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Cannot retrieve the created deployment: %v", err.Error())
 	}
-	for _, p := range pods {
-		if _, ok := existingPods[p.Name]; !ok {
-			t.Logf("New pod: %s", p.Name)
-			result[p.Name] = true
-			if !strings.HasPrefix(p.Name, deploymentName) {
-				t.Errorf("Pod newly created, got: \"%s\", want: \"^%s.*\" (regex).", p.Name, deploymentName)
-			}
-		}
+	if *d.Spec.Replicas != 4 {
+		t.Errorf("Replicas of deployment, got: %d, want: %d.", *d.Spec.Replicas, 4)
+	}
+	if d.Spec.Selector.MatchLabels["app"] != "demo" || d.Spec.Template.Labels["app"] != "demo" {
+		t.Errorf("App label of deployment, got: %v and %v, want: \"demo\".",
+			d.Spec.Selector.MatchLabels, d.Spec.Template.Labels)
+	}
+	containers := d.Spec.Template.Spec.Containers
+	if len(containers) != 1 {
+		t.Fatalf("Number of containers, got: %d, want: %d.", len(containers), 1)
+	}
+	if containers[0].Name != "kubernetes-bootcamp" {
+		t.Errorf("Container name, got: \"%s\", want: \"%s\".", containers[0].Name, "kubernetes-bootcamp")
+	}
+	if containers[0].Image != "gcr.io/google-samples/kubernetes-bootcamp:v1" {
+		t.Errorf("Container image, got: \"%s\", want: \"%s\".",
+			containers[0].Image, "gcr.io/google-samples/kubernetes-bootcamp:v1")
+	}
+	if len(containers[0].Ports) != 1 || containers[0].Ports[0].ContainerPort != 80 {
+		t.Errorf("Container ports, got: %v, want: port 80.", containers[0].Ports)
 	}
-	return result
 }
 
-func createSampleDeployment(clientset *kubernetes.Clientset, appName string, deploymentName string) {
-	launchK8sDeployment(
+func createSampleDeployment(clientset kubernetes.Interface, appName string, deploymentName string) error {
+	return launchK8sDeployment(
+		context.TODO(),
 		clientset,
-		"default",
-		appName,
-		deploymentName,
-		"kubernetes-bootcamp",
-		"gcr.io/google-samples/kubernetes-bootcamp:appsv1")
+		deploymentInput{
+			namespace:      "default",
+			appName:        appName,
+			deploymentName: deploymentName,
+			containerName:  "kubernetes-bootcamp",
+			image:          "gcr.io/google-samples/kubernetes-bootcamp:v1",
+			replicas:       4,
+			ports:          []v1.ContainerPort{{Name: "http", Protocol: v1.ProtocolTCP, ContainerPort: 80}},
+		})
 }
 
-func getDeploymentsOfDefaultNamespace(t *testing.T, clientset *kubernetes.Clientset) []appsv1.Deployment {
+func getDeploymentsOfDefaultNamespace(t *testing.T, clientset kubernetes.Interface) []appsv1.Deployment {
 	deployments, err := clientset.AppsV1().Deployments("default").List(context.TODO(), metav1.ListOptions{})
 	if err != nil {
 		t.Fatalf("Cannot retrieve deployments of default namespace: %v", err.Error())
@@ -144,66 +141,48 @@ func convertDeploymentListToMapOfName(originalDeployments []appsv1.Deployment) m
 	return result
 }
 
-func getPodsOfDefaultNamespace(t *testing.T, clientset *kubernetes.Clientset) []v1.Pod {
-	pods, err := clientset.CoreV1().Pods("default").List(context.TODO(), metav1.ListOptions{})
-	if err != nil {
-		t.Fatalf("Cannot retrieve pods of default namespace: %v", err.Error())
-	}
-	return pods.Items
-}
-
 func convertPodListToMapOfName(originalPods []v1.Pod) map[string]bool {
 	result := make(map[string]bool)
 	for _, p := range originalPods {
-		result[p.Name] = true
+		result[p.Namespace+"/"+p.Name] = true
 	}
 	return result
 }
 
-func deleteUnitTest(t *testing.T, clientset *kubernetes.Clientset, deploymentName string) map[string]bool {
-	result := make(map[string]bool)
+func deleteUnitTest(t *testing.T, clientset kubernetes.Interface, server *fakeAPIServer, deploymentName string) {
 	originalDeployments := getDeploymentsOfDefaultNamespace(t, clientset)
-	originalPods := getPodsOfDefaultNamespace(t, clientset)
 
-	deleteSampleDeployment(clientset, deploymentName)
-	time.Sleep(90*time.Second)
+	if err := deleteSampleDeployment(clientset, deploymentName); err != nil {
+		t.Fatalf("Cannot delete deployment: %v", err.Error())
+	}
 
 	deployments := getDeploymentsOfDefaultNamespace(t, clientset)
-	remainingDeployments := convertDeploymentListToMapOfName(deployments)
 	numOfDeletedDeployments := len(originalDeployments) - len(deployments)
 	if numOfDeletedDeployments != 1 {
 		t.Errorf("Number of deployments deleted, got: %d, want: %d.", numOfDeletedDeployments, 1)
 	}
-	pods := getPodsOfDefaultNamespace(t, clientset)
-	remainingPods := convertPodListToMapOfName(pods)
-	numOfDeletedPods := len(originalPods) - len(pods)
-	if numOfDeletedPods != 4 {
-		t.Errorf("Number of pods deleted, got: %d, want: %d.", numOfDeletedPods, 4)
+	if _, ok := convertDeploymentListToMapOfName(deployments)[deploymentName]; ok {
+		t.Errorf("Deployment %v was not deleted.", deploymentName)
 	}
 
-	for _, d := range originalDeployments {
-		if _, ok := remainingDeployments[d.Name]; !ok {
-			t.Logf("Deployment %v is deleted.", d.Name)
-			if d.Name != deploymentName {
-				t.Errorf("Deployment deleted, got: \"%s\", want: \"%s\".", d.Name, deploymentName)
-			}
-		}
+	requests := server.requestsOf("DELETE", "deployments")
+	if len(requests) != 1 {
+		t.Fatalf("Number of delete requests, got: %d, want: %d.", len(requests), 1)
 	}
-	for _, p := range originalPods {
-		if _, ok := remainingPods[p.Name]; !ok {
-			t.Logf("Deleted pod %v.", p.Name)
-			result[p.Name] = true
-			if !strings.HasPrefix(p.Name, deploymentName) {
-				t.Errorf("Pod deleted, got: \"%s\", want: \"^%s.*\" (regex).", p.Name, deploymentName)
-			}
-		}
+	var options metav1.DeleteOptions
+	if err := json.Unmarshal(requests[0].body, &options); err != nil {
+		t.Fatalf("Cannot decode delete options: %v", err.Error())
+	}
+	if options.PropagationPolicy == nil || *options.PropagationPolicy != metav1.DeletePropagationForeground {
+		t.Errorf("Propagation policy of delete, got: %v, want: %v.",
+			options.PropagationPolicy, metav1.DeletePropagationForeground)
 	}
-	return result
 }
 
-func deleteSampleDeployment(clientset *kubernetes.Clientset, deploymentName string) {
-	deleteK8sDeployment(
+func deleteSampleDeployment(clientset kubernetes.Interface, deploymentName string) error {
+	return deleteK8sDeployment(
+		context.TODO(),
 		clientset,
 		"default",
 		deploymentName)
-}
\ No newline at end of file
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+	"log"
+	"sort"
+	"strings"
+)
+
+// changeCauseAnnotation records why a deployment changed, which the rollout history shows for each revision.
+const changeCauseAnnotation = "kubernetes.io/change-cause"
+
+// updateInput holds the changes of the update task. Only the fields that are set are patched, env variables and
+// resources are merged into those the container already has.
+type updateInput struct {
+	namespace      string
+	deploymentName string
+	// containerName may be left empty when the pod template has a single container.
+	containerName string
+	image         string
+	env           []v1.EnvVar
+	requests      v1.ResourceList
+	limits        v1.ResourceList
+}
+
+// targetNamespace is the namespace of the deployment, "default" unless the input says otherwise.
+func (in updateInput) targetNamespace() string {
+	if in.namespace == "" {
+		return "default"
+	}
+	return in.namespace
+}
+
+// validate checks the input of the update task, which has to change at least one thing.
+func (in updateInput) validate() field.ErrorList {
+	var errs field.ErrorList
+	for _, msg := range validation.IsDNS1123Subdomain(in.deploymentName) {
+		errs = append(errs, field.Invalid(field.NewPath("metadata", "name"), in.deploymentName, msg))
+	}
+	containerPath := field.NewPath("spec", "template", "spec", "containers").Index(0)
+	if in.containerName != "" {
+		for _, msg := range validation.IsDNS1123Label(in.containerName) {
+			errs = append(errs, field.Invalid(containerPath.Child("name"), in.containerName, msg))
+		}
+	}
+	if strings.TrimSpace(in.image) == "" && len(in.env) == 0 && len(in.requests) == 0 && len(in.limits) == 0 {
+		errs = append(errs, field.Required(containerPath, "nothing to update, give an image, env or resources"))
+	}
+	return errs
+}
+
+// changeCause describes the update for the rollout history, e.g. "update image to nginx:1.21, env LOG_LEVEL=debug".
+func (in updateInput) changeCause() string {
+	var changes []string
+	if in.image != "" {
+		changes = append(changes, "image to "+in.image)
+	}
+	if len(in.env) > 0 {
+		var env []string
+		for _, e := range in.env {
+			env = append(env, e.Name+"="+e.Value)
+		}
+		changes = append(changes, "env "+strings.Join(env, ","))
+	}
+	if len(in.requests) > 0 {
+		changes = append(changes, "requests "+formatResources(in.requests))
+	}
+	if len(in.limits) > 0 {
+		changes = append(changes, "limits "+formatResources(in.limits))
+	}
+	return "update " + strings.Join(changes, ", ")
+}
+
+// formatResources writes resources the way they are parsed, e.g. "cpu=250m,memory=128Mi".
+func formatResources(resources v1.ResourceList) string {
+	var entries []string
+	for name, quantity := range resources {
+		entries = append(entries, fmt.Sprintf("%v=%v", name, quantity.String()))
+	}
+	sort.Strings(entries)
+	return strings.Join(entries, ",")
+}
+
+// updatePatch builds the strategic merge patch of the update for the given container. The resource version makes the
+// API server reject the patch with a Conflict if the deployment changed since it was read.
+func updatePatch(in updateInput, containerName string, resourceVersion string) ([]byte, error) {
+	container := map[string]interface{}{"name": containerName}
+	if in.image != "" {
+		container["image"] = in.image
+	}
+	if len(in.env) > 0 {
+		container["env"] = in.env
+	}
+	resources := map[string]interface{}{}
+	if len(in.requests) > 0 {
+		resources["requests"] = in.requests
+	}
+	if len(in.limits) > 0 {
+		resources["limits"] = in.limits
+	}
+	if len(resources) > 0 {
+		container["resources"] = resources
+	}
+	return json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"resourceVersion": resourceVersion,
+			"annotations":     map[string]string{changeCauseAnnotation: in.changeCause()},
+		},
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{container},
+				},
+			},
+		},
+	})
+}
+
+// updateContainerName resolves the container to update, which may be left out when the pod template has a single
+// container.
+func updateContainerName(deployment *appsv1.Deployment, name string) (string, error) {
+	containers := deployment.Spec.Template.Spec.Containers
+	path := field.NewPath("spec", "template", "spec", "containers")
+	var names []string
+	for _, c := range containers {
+		if c.Name == name {
+			return name, nil
+		}
+		names = append(names, c.Name)
+	}
+	if name == "" && len(containers) == 1 {
+		return containers[0].Name, nil
+	}
+	if name == "" {
+		return "", invalidDeployment(deployment.Name, field.ErrorList{
+			field.Required(path.Index(0).Child("name"), fmt.Sprintf("choose one of the containers %v", names)),
+		})
+	}
+	return "", invalidDeployment(deployment.Name, field.ErrorList{field.NotFound(path.Key(name), name)})
+}
+
+// patchK8sDeployment patches the deployment with the update, reading it again and retrying when it was modified in
+// the meantime. It returns the deployment before and after the patch.
+func patchK8sDeployment(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	input updateInput,
+	dryRun bool) (*appsv1.Deployment, *appsv1.Deployment, error) {
+	namespace := input.targetNamespace()
+	if errs := input.validate(); len(errs) > 0 {
+		return nil, nil, invalidDeployment(input.deploymentName, errs)
+	}
+
+	deploymentsClient := clientset.AppsV1().Deployments(namespace)
+	options := metav1.PatchOptions{}
+	if dryRun {
+		options.DryRun = []string{metav1.DryRunAll}
+	}
+	var live, result *appsv1.Deployment
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		var err error
+		if live, err = deploymentsClient.Get(ctx, input.deploymentName, metav1.GetOptions{}); err != nil {
+			return err
+		}
+		containerName, err := updateContainerName(live, input.containerName)
+		if err != nil {
+			return err
+		}
+		patch, err := updatePatch(input, containerName, live.ResourceVersion)
+		if err != nil {
+			return err
+		}
+		result, err = deploymentsClient.Patch(ctx, input.deploymentName, types.StrategicMergePatchType, patch, options)
+		return err
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot update deployment %v in namespace %v: %w", input.deploymentName, namespace, err)
+	}
+	return live, result, nil
+}
+
+// updateK8sDeployment patches the deployment with the update, recording it as the change cause of the new revision.
+func updateK8sDeployment(ctx context.Context, clientset kubernetes.Interface, input updateInput) error {
+	live, result, err := patchK8sDeployment(ctx, clientset, input, false)
+	if err != nil {
+		return err
+	}
+	if result.Generation == live.Generation {
+		log.Printf("Deployment %v is unchanged, it already runs with %v.", result.Name,
+			strings.TrimPrefix(input.changeCause(), "update "))
+		return nil
+	}
+	log.Printf("Updated deployment %v (generation %d): %v.", result.Name, result.Generation, input.changeCause())
+	return nil
+}
+
+// previewUpdate runs the update task as a server-side dry run and shows how the live deployment would change.
+func previewUpdate(ctx context.Context, clientset kubernetes.Interface, input updateInput) (string, error) {
+	live, result, err := patchK8sDeployment(ctx, clientset, input, true)
+	if err != nil {
+		return "", err
+	}
+	return diffObjects(live, result, deploymentKind, input.deploymentName)
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"errors"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUpdateMergesIntoContainer(t *testing.T) {
+	clientset, server := newFakeClientset(t, newNamespace("default"))
+	if err := applyK8sDeployment(context.TODO(), clientset, newSampleInput(2), false); err != nil {
+		t.Fatalf("Cannot apply deployment: %v", err.Error())
+	}
+
+	first := updateInput{deploymentName: "demo", env: []v1.EnvVar{{Name: "LOG_LEVEL", Value: "debug"}}}
+	if err := updateK8sDeployment(context.TODO(), clientset, first); err != nil {
+		t.Fatalf("Cannot update env: %v", err.Error())
+	}
+	second := updateInput{
+		deploymentName: "demo",
+		image:          "nginx:1.21",
+		env:            []v1.EnvVar{{Name: "MODE", Value: "fast"}},
+		limits:         v1.ResourceList{v1.ResourceMemory: resource.MustParse("128Mi")},
+	}
+	if err := updateK8sDeployment(context.TODO(), clientset, second); err != nil {
+		t.Fatalf("Cannot update image: %v", err.Error())
+	}
+
+	deployment := getSampleDeployment(t, server, "demo")
+	container := deployment.Spec.Template.Spec.Containers[0]
+	if container.Image != "nginx:1.21" {
+		t.Errorf("Image after update, got: %v, want: %v.", container.Image, "nginx:1.21")
+	}
+	if len(container.Env) != 2 || container.Env[0].Name != "LOG_LEVEL" || container.Env[1].Name != "MODE" {
+		t.Errorf("Env after two updates, got: %v, want LOG_LEVEL and MODE.", container.Env)
+	}
+	if got := container.Resources.Limits[v1.ResourceMemory]; got.String() != "128Mi" {
+		t.Errorf("Memory limit after update, got: %v, want: %v.", got.String(), "128Mi")
+	}
+	if len(container.Ports) != 1 {
+		t.Errorf("Ports after update, got: %v, want them kept.", container.Ports)
+	}
+	want := "update image to nginx:1.21, env MODE=fast, limits memory=128Mi"
+	if got := deployment.Annotations[changeCauseAnnotation]; got != want {
+		t.Errorf("Change cause, got: %q, want: %q.", got, want)
+	}
+	if deployment.Generation != 3 {
+		t.Errorf("Generation after two updates, got: %d, want: %d.", deployment.Generation, 3)
+	}
+}
+
+func TestUpdateRetriesOnConflict(t *testing.T) {
+	clientset, server := newFakeClientset(t, newNamespace("default"))
+	if err := createSampleDeployment(clientset, "demo", "demo"); err != nil {
+		t.Fatalf("Cannot create deployment: %v", err.Error())
+	}
+	server.failNext("PATCH", "deployments", apierrors.NewConflict(
+		schema.GroupResource{Group: "apps", Resource: "deployments"}, "demo",
+		errors.New("the object has been modified")))
+
+	if err := updateK8sDeployment(context.TODO(), clientset, updateInput{deploymentName: "demo", image: "nginx"}); err != nil {
+		t.Fatalf("Cannot update deployment after a conflict: %v", err.Error())
+	}
+	if got := len(server.requestsOf("PATCH", "deployments")); got != 2 {
+		t.Errorf("Patches of an update with one conflict, got: %d, want: %d.", got, 2)
+	}
+	if got := getSampleDeployment(t, server, "demo").Spec.Template.Spec.Containers[0].Image; got != "nginx" {
+		t.Errorf("Image after update, got: %v, want: %v.", got, "nginx")
+	}
+}
+
+func TestUpdateChoosesContainer(t *testing.T) {
+	clientset, server := newFakeClientset(t, newNamespace("default"))
+	if err := createSampleDeployment(clientset, "demo", "demo"); err != nil {
+		t.Fatalf("Cannot create deployment: %v", err.Error())
+	}
+	deployment := getSampleDeployment(t, server, "demo")
+	deployment.Spec.Template.Spec.Containers = append(deployment.Spec.Template.Spec.Containers,
+		v1.Container{Name: "sidecar", Image: "busybox"})
+	server.seed(deployment)
+
+	err := updateK8sDeployment(context.TODO(), clientset, updateInput{deploymentName: "demo", image: "nginx"})
+	if exitCodeForError(err) != exitInvalid || !strings.Contains(err.Error(), "sidecar") {
+		t.Errorf("Error of an update without a container among several, got: %v, want an invalid input.", err)
+	}
+	err = updateK8sDeployment(context.TODO(), clientset, updateInput{deploymentName: "demo", containerName: "missing", image: "nginx"})
+	if exitCodeForError(err) != exitInvalid {
+		t.Errorf("Error of an update of a missing container, got: %v, want an invalid input.", err)
+	}
+	if err := updateK8sDeployment(context.TODO(), clientset, updateInput{
+		deploymentName: "demo", containerName: "sidecar", image: "busybox:1.34",
+	}); err != nil {
+		t.Fatalf("Cannot update the sidecar: %v", err.Error())
+	}
+	containers := getSampleDeployment(t, server, "demo").Spec.Template.Spec.Containers
+	if containers[0].Image == "busybox:1.34" || containers[1].Image != "busybox:1.34" {
+		t.Errorf("Images after updating the sidecar, got: %v and %v.", containers[0].Image, containers[1].Image)
+	}
+}
+
+func TestUpdateCommandFollowsRollout(t *testing.T) {
+	_, server := newFakeClientset(t, newNamespace("default"))
+	path := writeKubeconfig(t, "fake", map[string]string{"fake": server.URL})
+	if got := runSubcommand([]string{"create", "--kubeconfig", path, "--name", "demo", "--image", "nginx"}); got != exitOK {
+		t.Fatalf("Exit code of create, got: %d, want: %d.", got, exitOK)
+	}
+
+	done := make(chan int, 1)
+	go func() {
+		done <- runSubcommand([]string{"update", "--kubeconfig", path, "--name", "demo", "--image", "nginx:1.21",
+			"--env", "LOG_LEVEL=debug", "--requests", "cpu=250m,memory=64Mi"})
+	}()
+	server.waitForWatches("deployments", 1)
+	select {
+	case code := <-done:
+		t.Fatalf("Update ended before the rollout, exit code: %d.", code)
+	default:
+	}
+	setDeploymentStatus(t, server, appsv1.DeploymentStatus{
+		ObservedGeneration: 2, Replicas: 4, UpdatedReplicas: 4, ReadyReplicas: 4, AvailableReplicas: 4,
+		Conditions: []appsv1.DeploymentCondition{{Type: appsv1.DeploymentAvailable, Status: v1.ConditionTrue}},
+	})
+	select {
+	case code := <-done:
+		if code != exitOK {
+			t.Errorf("Exit code of update, got: %d, want: %d.", code, exitOK)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Update did not end after the rollout completed.")
+	}
+	requests := getSampleDeployment(t, server, "demo").Spec.Template.Spec.Containers[0].Resources.Requests
+	if got := requests[v1.ResourceCPU]; got.String() != "250m" {
+		t.Errorf("CPU request after update, got: %v, want: %v.", got.String(), "250m")
+	}
+
+	invalid := []string{"update", "--kubeconfig", path, "--name", "demo", "--env", "1BAD=x"}
+	if got := runSubcommand(invalid); got != exitInvalid {
+		t.Errorf("Exit code of update with an invalid env variable, got: %d, want: %d.", got, exitInvalid)
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultReplicas = 4
+	defaultPorts    = "http:80/TCP"
+)
+
+var supportedProtocols = []string{string(v1.ProtocolTCP), string(v1.ProtocolUDP), string(v1.ProtocolSCTP)}
+
+var (
+	replicasPath = field.NewPath("spec", "replicas")
+	portsPath    = field.NewPath("spec", "template", "spec", "containers").Index(0).Child("ports")
+	envPath      = field.NewPath("spec", "template", "spec", "containers").Index(0).Child("env")
+	requestsPath = field.NewPath("spec", "template", "spec", "containers").Index(0).Child("resources", "requests")
+	limitsPath   = field.NewPath("spec", "template", "spec", "containers").Index(0).Child("resources", "limits")
+	revisionPath = field.NewPath("metadata", "annotations").Key(revisionAnnotation)
+)
+
+// parseReplicas parses the replica count typed at the prompt, where an empty input stands for the default.
+func parseReplicas(input string) (int32, field.ErrorList) {
+	if input == "" {
+		return defaultReplicas, nil
+	}
+	replicas, err := strconv.ParseInt(input, 10, 32)
+	if err != nil {
+		return 0, field.ErrorList{field.Invalid(replicasPath, input, "must be an integer")}
+	}
+	return int32(replicas), nil
+}
+
+// parseScaleReplicas parses the replica count to scale a deployment to typed at the prompt. Unlike at creation there
+// is no default, so that pressing Enter by mistake does not resize a running deployment.
+func parseScaleReplicas(input string) (int32, field.ErrorList) {
+	if input == "" {
+		return 0, field.ErrorList{field.Required(replicasPath, "the number of replicas to scale to")}
+	}
+	return parseReplicas(input)
+}
+
+// parseRevision parses the revision to roll back to typed at the prompt, where an empty input stands for the
+// previous revision.
+func parseRevision(input string) (int64, field.ErrorList) {
+	if input == "" {
+		return 0, nil
+	}
+	revision, err := strconv.ParseInt(input, 10, 64)
+	if err != nil || revision < 0 {
+		return 0, field.ErrorList{field.Invalid(revisionPath, input, "must be a positive integer")}
+	}
+	return revision, nil
+}
+
+// parsePorts parses container ports written as name:port/protocol, e.g. "http:8080/TCP" or "metrics:9090". The
+// name and the protocol are optional, the protocol defaulting to TCP. Entries may also be separated by commas.
+func parsePorts(entries []string) ([]v1.ContainerPort, field.ErrorList) {
+	var ports []v1.ContainerPort
+	var errs field.ErrorList
+	for _, spec := range splitEntries(entries) {
+		port, err := parsePort(spec, portsPath.Index(len(ports)))
+		if err != nil {
+			errs = append(errs, err)
+		}
+		ports = append(ports, port)
+	}
+	return ports, errs
+}
+
+func parsePort(spec string, path *field.Path) (v1.ContainerPort, *field.Error) {
+	port := v1.ContainerPort{Protocol: v1.ProtocolTCP}
+	rest := spec
+	if i := strings.LastIndex(rest, "/"); i >= 0 {
+		port.Protocol = v1.Protocol(strings.ToUpper(rest[i+1:]))
+		rest = rest[:i]
+	}
+	if i := strings.Index(rest, ":"); i >= 0 {
+		port.Name = rest[:i]
+		rest = rest[i+1:]
+	}
+	number, err := strconv.ParseInt(rest, 10, 32)
+	if err != nil {
+		return port, field.Invalid(path, spec, "must be written as name:port/protocol, e.g. http:8080/TCP")
+	}
+	port.ContainerPort = int32(number)
+	return port, nil
+}
+
+// parseEnv parses environment variables written as NAME=value, e.g. "LOG_LEVEL=debug". Entries may also be
+// separated by commas, so values cannot contain any.
+func parseEnv(entries []string) ([]v1.EnvVar, field.ErrorList) {
+	var env []v1.EnvVar
+	var errs field.ErrorList
+	for _, spec := range splitEntries(entries) {
+		path := envPath.Index(len(env))
+		i := strings.Index(spec, "=")
+		if i < 0 {
+			errs = append(errs, field.Invalid(path, spec, "must be written as NAME=value, e.g. LOG_LEVEL=debug"))
+			continue
+		}
+		name := spec[:i]
+		for _, msg := range validation.IsEnvVarName(name) {
+			errs = append(errs, field.Invalid(path.Child("name"), name, msg))
+		}
+		env = append(env, v1.EnvVar{Name: name, Value: spec[i+1:]})
+	}
+	return env, errs
+}
+
+// parseResources parses resource quantities written as name=quantity, e.g. "cpu=250m,memory=128Mi".
+func parseResources(entries []string, path *field.Path) (v1.ResourceList, field.ErrorList) {
+	var resources v1.ResourceList
+	var errs field.ErrorList
+	for _, spec := range splitEntries(entries) {
+		i := strings.Index(spec, "=")
+		if i < 0 {
+			errs = append(errs, field.Invalid(path, spec, "must be written as name=quantity, e.g. cpu=250m"))
+			continue
+		}
+		name := v1.ResourceName(spec[:i])
+		for _, msg := range validation.IsQualifiedName(string(name)) {
+			errs = append(errs, field.Invalid(path.Key(string(name)), name, msg))
+		}
+		quantity, err := resource.ParseQuantity(spec[i+1:])
+		if err != nil {
+			errs = append(errs, field.Invalid(path.Key(string(name)), spec[i+1:], err.Error()))
+			continue
+		}
+		if resources == nil {
+			resources = v1.ResourceList{}
+		}
+		resources[name] = quantity
+	}
+	return resources, errs
+}
+
+// splitEntries splits comma separated entries, dropping the empty ones.
+func splitEntries(entries []string) []string {
+	var result []string
+	for _, entry := range entries {
+		for _, spec := range strings.Split(entry, ",") {
+			if spec = strings.TrimSpace(spec); spec != "" {
+				result = append(result, spec)
+			}
+		}
+	}
+	return result
+}
+
+// validate checks the input of the create task before the Deployment is built.
+func (in deploymentInput) validate() field.ErrorList {
+	var errs field.ErrorList
+	for _, msg := range validation.IsDNS1123Subdomain(in.deploymentName) {
+		errs = append(errs, field.Invalid(field.NewPath("metadata", "name"), in.deploymentName, msg))
+	}
+	labelPath := field.NewPath("spec", "template", "metadata", "labels").Key("app")
+	for _, msg := range validation.IsValidLabelValue(in.appName) {
+		errs = append(errs, field.Invalid(labelPath, in.appName, msg))
+	}
+	containerPath := field.NewPath("spec", "template", "spec", "containers").Index(0)
+	for _, msg := range validation.IsDNS1123Label(in.containerName) {
+		errs = append(errs, field.Invalid(containerPath.Child("name"), in.containerName, msg))
+	}
+	if strings.TrimSpace(in.image) == "" {
+		errs = append(errs, field.Required(containerPath.Child("image"), "an image is required"))
+	}
+	if in.replicas < 0 {
+		errs = append(errs, field.Invalid(replicasPath, in.replicas, "must be greater than or equal to 0"))
+	}
+
+	names := sets.NewString()
+	numbers := sets.NewString()
+	for i, port := range in.ports {
+		path := portsPath.Index(i)
+		if port.Name != "" {
+			for _, msg := range validation.IsValidPortName(port.Name) {
+				errs = append(errs, field.Invalid(path.Child("name"), port.Name, msg))
+			}
+			if names.Has(port.Name) {
+				errs = append(errs, field.Duplicate(path.Child("name"), port.Name))
+			}
+			names.Insert(port.Name)
+		}
+		for _, msg := range validation.IsValidPortNum(int(port.ContainerPort)) {
+			errs = append(errs, field.Invalid(path.Child("containerPort"), port.ContainerPort, msg))
+		}
+		if !sets.NewString(supportedProtocols...).Has(string(port.Protocol)) {
+			errs = append(errs, field.NotSupported(path.Child("protocol"), port.Protocol, supportedProtocols))
+		}
+		number := fmt.Sprintf("%d/%s", port.ContainerPort, port.Protocol)
+		if numbers.Has(number) {
+			errs = append(errs, field.Duplicate(path.Child("containerPort"), number))
+		}
+		numbers.Insert(number)
+	}
+	return errs
+}
+
+// invalidDeployment turns validation errors into an Invalid error, like the API server would return.
+func invalidDeployment(deploymentName string, errs field.ErrorList) error {
+	return apierrors.NewInvalid(appsv1.SchemeGroupVersion.WithKind("Deployment").GroupKind(), deploymentName, errs)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"reflect"
+	"testing"
+)
+
+func TestParsePorts(t *testing.T) {
+	ports, errs := parsePorts([]string{"http:8080/TCP,metrics:9090", "dns:53/udp", "7000"})
+	if len(errs) > 0 {
+		t.Fatalf("Cannot parse ports: %v", errs.ToAggregate())
+	}
+	want := []v1.ContainerPort{
+		{Name: "http", ContainerPort: 8080, Protocol: v1.ProtocolTCP},
+		{Name: "metrics", ContainerPort: 9090, Protocol: v1.ProtocolTCP},
+		{Name: "dns", ContainerPort: 53, Protocol: v1.ProtocolUDP},
+		{ContainerPort: 7000, Protocol: v1.ProtocolTCP},
+	}
+	if !reflect.DeepEqual(ports, want) {
+		t.Errorf("Parsed ports, got: %v, want: %v.", ports, want)
+	}
+
+	if _, errs := parsePorts([]string{"http:eighty/TCP"}); len(errs) != 1 {
+		t.Errorf("Errors of a malformed port, got: %v, want: 1 error.", errs)
+	}
+}
+
+func TestParseScaleReplicas(t *testing.T) {
+	if replicas, errs := parseScaleReplicas("2"); len(errs) > 0 || replicas != 2 {
+		t.Errorf("Replicas to scale to, got: %d %v, want: 2.", replicas, errs)
+	}
+	if _, errs := parseScaleReplicas(""); len(errs) != 1 {
+		t.Errorf("Errors of scaling without replicas, got: %v, want: 1 error.", errs)
+	}
+}
+
+func TestParseEnvAndResources(t *testing.T) {
+	env, errs := parseEnv([]string{"LOG_LEVEL=debug,EMPTY=", "URL=http://example.com/?a=b"})
+	if len(errs) > 0 {
+		t.Fatalf("Cannot parse env: %v", errs.ToAggregate())
+	}
+	wantEnv := []v1.EnvVar{{Name: "LOG_LEVEL", Value: "debug"}, {Name: "EMPTY"}, {Name: "URL", Value: "http://example.com/?a=b"}}
+	if !reflect.DeepEqual(env, wantEnv) {
+		t.Errorf("Parsed env, got: %v, want: %v.", env, wantEnv)
+	}
+	if _, errs := parseEnv([]string{"LOG_LEVEL", "1BAD=x"}); len(errs) != 2 {
+		t.Errorf("Errors of malformed env, got: %v, want: 2 errors.", errs)
+	}
+
+	resources, errs := parseResources([]string{"cpu=250m,memory=128Mi"}, requestsPath)
+	if len(errs) > 0 {
+		t.Fatalf("Cannot parse resources: %v", errs.ToAggregate())
+	}
+	if cpu := resources[v1.ResourceCPU]; cpu.MilliValue() != 250 || len(resources) != 2 {
+		t.Errorf("Parsed resources, got: %v, want cpu=250m and memory=128Mi.", resources)
+	}
+	if _, errs := parseResources([]string{"cpu=lots", "memory"}, limitsPath); len(errs) != 2 {
+		t.Errorf("Errors of malformed resources, got: %v, want: 2 errors.", errs)
+	}
+}
+
+func TestValidateDeploymentInput(t *testing.T) {
+	valid := []v1.ContainerPort{{Name: "http", ContainerPort: 8080, Protocol: v1.ProtocolTCP}}
+	cases := []struct {
+		description string
+		replicas    int32
+		ports       []v1.ContainerPort
+		wantErrors  int
+	}{
+		{"valid input", 2, valid, 0},
+		{"no ports", 0, nil, 0},
+		{"negative replicas", -1, valid, 1},
+		{"port out of range", 1, []v1.ContainerPort{{ContainerPort: 70000, Protocol: v1.ProtocolTCP}}, 1},
+		{"unsupported protocol", 1, []v1.ContainerPort{{ContainerPort: 80, Protocol: "ICMP"}}, 1},
+		{"invalid port name", 1, []v1.ContainerPort{{Name: "Not_Valid", ContainerPort: 80, Protocol: v1.ProtocolTCP}}, 1},
+		{"duplicate port name", 1, []v1.ContainerPort{
+			{Name: "http", ContainerPort: 80, Protocol: v1.ProtocolTCP},
+			{Name: "http", ContainerPort: 81, Protocol: v1.ProtocolTCP},
+		}, 1},
+		{"same port for TCP and UDP", 1, []v1.ContainerPort{
+			{ContainerPort: 53, Protocol: v1.ProtocolTCP},
+			{ContainerPort: 53, Protocol: v1.ProtocolUDP},
+		}, 0},
+	}
+	for _, c := range cases {
+		input := deploymentInput{
+			appName:        "demo",
+			deploymentName: "demo",
+			containerName:  "demo",
+			image:          "nginx",
+			replicas:       c.replicas,
+			ports:          c.ports,
+		}
+		errs := input.validate()
+		if len(errs) != c.wantErrors {
+			t.Errorf("Errors of %v, got: %v, want: %d errors.", c.description, errs, c.wantErrors)
+		}
+	}
+}
+
+func TestCreateWithReplicasAndPorts(t *testing.T) {
+	_, server := newFakeClientset(t, newNamespace("default"))
+	path := writeKubeconfig(t, "fake", map[string]string{"fake": server.URL})
+
+	args := []string{"create", "--kubeconfig", path, "--name", "demo", "--image", "nginx",
+		"--replicas", "2", "--ports", "http:8080/TCP,metrics:9090", "--ports", "dns:53/UDP"}
+	if got := runSubcommand(args); got != exitOK {
+		t.Fatalf("Exit code of create, got: %d, want: %d.", got, exitOK)
+	}
+	d := server.get("deployments", "default", "demo")
+	if d == nil {
+		t.Fatalf("Deployment demo was not created.")
+	}
+	replicas, _, _ := unstructured.NestedInt64(d.Object, "spec", "replicas")
+	if replicas != 2 {
+		t.Errorf("Replicas, got: %d, want: %d.", replicas, 2)
+	}
+	containers, _, _ := unstructured.NestedSlice(d.Object, "spec", "template", "spec", "containers")
+	ports := containers[0].(map[string]interface{})["ports"].([]interface{})
+	if len(ports) != 3 {
+		t.Errorf("Number of ports, got: %d, want: %d.", len(ports), 3)
+	}
+
+	invalid := []string{"create", "--kubeconfig", path, "--name", "other", "--image", "nginx", "--ports", "http:0/TCP"}
+	if got := runSubcommand(invalid); got != exitInvalid {
+		t.Errorf("Exit code of create with an invalid port, got: %d, want: %d.", got, exitInvalid)
+	}
+	if server.get("deployments", "default", "other") != nil {
+		t.Errorf("Deployment with an invalid port was sent to the API server.")
+	}
+}
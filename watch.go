@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// podWatch follows the pods of a namespace, or of every namespace when it is empty, and writes a line for each
+// change of the pods it knows.
+type podWatch struct {
+	clientset kubernetes.Interface
+	namespace string
+	options   metav1.ListOptions
+	out       io.Writer
+	// pods are the last known pods by namespace/name.
+	pods map[string]*v1.Pod
+	// resourceVersion is the last one seen, from which the watch resumes.
+	resourceVersion string
+}
+
+// watchPods lists the pods as a table in the given format, "" or "wide", and then keeps watching them until the
+// context is done, writing each pod that is added, changed or deleted. The watch resumes from the last resource
+// version seen when the API server ends it, and lists the pods again when that version is too old to resume from.
+func watchPods(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	namespace string,
+	options metav1.ListOptions,
+	out io.Writer,
+	format string) error {
+	if format != "" && format != "wide" {
+		return fmt.Errorf("cannot watch pods with output format %q, only with the table or wide", format)
+	}
+	printer, err := newPrinter(out, format, podColumns)
+	if err != nil {
+		return err
+	}
+	w := &podWatch{clientset: clientset, namespace: namespace, options: options, out: out, pods: map[string]*v1.Pod{}}
+	w.resourceVersion, err = listPodPages(ctx, clientset, namespace, options, func(pods []v1.Pod) error {
+		for i := range pods {
+			w.pods[podKey(&pods[i])] = &pods[i]
+		}
+		return printer.printObjects(podObjects(pods))
+	})
+	if err != nil {
+		return err
+	}
+	if err := printer.flush(); err != nil {
+		return err
+	}
+	return w.run(ctx)
+}
+
+// run watches the pods until the context is done. A watch that made progress is resumed right away, while one that
+// failed or ended without any event is retried after a growing delay. Errors that retrying cannot fix, such as
+// missing permissions, end the watch.
+func (w *podWatch) run(ctx context.Context) error {
+	options := w.options
+	options.Limit, options.Continue = 0, ""
+	options.AllowWatchBookmarks = true
+	backoff := watchRestartBackoff
+	for {
+		from := w.resourceVersion
+		options.ResourceVersion = from
+		watcher, err := w.clientset.CoreV1().Pods(w.namespace).Watch(ctx, options)
+		if ctx.Err() != nil {
+			return nil
+		} else if isExpired(err) {
+			err = w.relist(ctx)
+		} else if err == nil {
+			err = w.follow(ctx, watcher)
+			watcher.Stop()
+		}
+		if ctx.Err() != nil {
+			return nil
+		} else if isPermanent(err) {
+			return fmt.Errorf("cannot watch pods: %w", err)
+		} else if err != nil {
+			log.Printf("Watch of pods failed, retrying: %v", err)
+		} else if w.resourceVersion != from {
+			backoff = watchRestartBackoff
+			continue
+		}
+		if waitForBackoff(ctx, &backoff) != nil {
+			return nil
+		}
+	}
+}
+
+// follow handles the events of a watch until it ends, listing the pods again when it ends because the resource
+// version is too old.
+func (w *podWatch) follow(ctx context.Context, watcher watch.Interface) error {
+	for event := range watcher.ResultChan() {
+		switch event.Type {
+		case watch.Error:
+			err := apierrors.FromObject(event.Object)
+			if isExpired(err) {
+				return w.relist(ctx)
+			}
+			return err
+		case watch.Bookmark:
+			if object, err := meta.Accessor(event.Object); err == nil {
+				w.resourceVersion = object.GetResourceVersion()
+			}
+		case watch.Added, watch.Modified, watch.Deleted:
+			pod, ok := event.Object.(*v1.Pod)
+			if !ok {
+				continue
+			}
+			w.resourceVersion = pod.ResourceVersion
+			previous := w.pods[podKey(pod)]
+			if event.Type == watch.Deleted {
+				delete(w.pods, podKey(pod))
+				w.report(watch.Deleted, previous, pod)
+			} else {
+				w.pods[podKey(pod)] = pod
+				w.report(event.Type, previous, pod)
+			}
+		}
+	}
+	if ctx.Err() == nil {
+		log.Printf("Watch of pods ended, resuming from resource version %v.", w.resourceVersion)
+	}
+	return nil
+}
+
+// relist lists the pods again after changes were missed, reporting the difference to the pods known before.
+func (w *podWatch) relist(ctx context.Context) error {
+	log.Printf("Resource version %v is too old to resume watching from, listing the pods again.", w.resourceVersion)
+	pods := map[string]*v1.Pod{}
+	resourceVersion, err := listPodPages(ctx, w.clientset, w.namespace, w.options, func(page []v1.Pod) error {
+		for i := range page {
+			pods[podKey(&page[i])] = &page[i]
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	var keys []string
+	for key := range pods {
+		keys = append(keys, key)
+	}
+	for key := range w.pods {
+		if pods[key] == nil {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		previous, pod := w.pods[key], pods[key]
+		if pod == nil {
+			w.report(watch.Deleted, previous, previous)
+		} else if previous == nil {
+			w.report(watch.Added, nil, pod)
+		} else {
+			w.report(watch.Modified, previous, pod)
+		}
+	}
+	w.pods, w.resourceVersion = pods, resourceVersion
+	return nil
+}
+
+// report writes a line for an event, e.g. "12:04:05 MODIFIED default/web-0: phase Pending -> Running". Changes of a
+// pod that neither its phase, its node nor the state of its containers tell about are left out.
+func (w *podWatch) report(eventType watch.EventType, previous *v1.Pod, pod *v1.Pod) {
+	detail := fmt.Sprintf("%v, %v ready", podStatus(pod), podReady(pod))
+	if eventType != watch.Deleted && previous == nil {
+		eventType = watch.Added
+	} else if eventType != watch.Deleted {
+		changes := podChanges(previous, pod)
+		if len(changes) == 0 {
+			return
+		}
+		detail = strings.Join(changes, ", ")
+	}
+	fmt.Fprintf(w.out, "%s %-8s %s: %s\n", time.Now().Format("15:04:05"), eventType, podKey(pod), detail)
+}
+
+// podChanges describes how a pod changed, e.g. "phase Pending -> Running" or
+// "container web waiting: ContainerCreating -> running, ready".
+func podChanges(previous *v1.Pod, pod *v1.Pod) []string {
+	var changes []string
+	if previous.Status.Phase != pod.Status.Phase {
+		changes = append(changes, fmt.Sprintf("phase %v -> %v", orNone(string(previous.Status.Phase)),
+			orNone(string(pod.Status.Phase))))
+	}
+	if previous.Spec.NodeName == "" && pod.Spec.NodeName != "" {
+		changes = append(changes, "scheduled on "+pod.Spec.NodeName)
+	}
+	if previous.DeletionTimestamp == nil && pod.DeletionTimestamp != nil {
+		changes = append(changes, "terminating")
+	}
+	states := map[string]string{}
+	for _, c := range containerStatuses(previous) {
+		states[c.Name] = containerState(c)
+	}
+	for _, c := range containerStatuses(pod) {
+		before, known := states[c.Name]
+		if after := containerState(c); !known {
+			changes = append(changes, fmt.Sprintf("container %v %v", c.Name, after))
+		} else if before != after {
+			changes = append(changes, fmt.Sprintf("container %v %v -> %v", c.Name, before, after))
+		}
+	}
+	return changes
+}
+
+// containerState describes the state of a container, e.g. "waiting: CrashLoopBackOff", "running, ready" or
+// "terminated: OOMKilled (exit code 137)", counting its restarts.
+func containerState(c v1.ContainerStatus) string {
+	var state string
+	switch {
+	case c.State.Running != nil && c.Ready:
+		state = "running, ready"
+	case c.State.Running != nil:
+		state = "running"
+	case c.State.Terminated != nil:
+		state = fmt.Sprintf("terminated: %v (exit code %d)", orNone(c.State.Terminated.Reason),
+			c.State.Terminated.ExitCode)
+	case c.State.Waiting != nil:
+		state = "waiting: " + orNone(c.State.Waiting.Reason)
+	default:
+		state = "unknown"
+	}
+	if c.RestartCount > 0 {
+		state += ", " + plural(int(c.RestartCount), "restart")
+	}
+	return state
+}
+
+// containerStatuses returns the statuses of the init containers of the pod followed by those of its containers.
+func containerStatuses(pod *v1.Pod) []v1.ContainerStatus {
+	var statuses []v1.ContainerStatus
+	statuses = append(statuses, pod.Status.InitContainerStatuses...)
+	return append(statuses, pod.Status.ContainerStatuses...)
+}
+
+func podKey(pod *v1.Pod) string {
+	return pod.Namespace + "/" + pod.Name
+}
+
+// isPermanent tells whether an error of a watch would come back however often the watch is retried, e.g. because
+// the user may not watch the pods or the namespace does not exist. Other errors, such as a dropped connection or an
+// overloaded API server, may go away.
+func isPermanent(err error) bool {
+	return apierrors.IsForbidden(err) || apierrors.IsUnauthorized(err) || apierrors.IsNotFound(err) ||
+		apierrors.IsBadRequest(err) || apierrors.IsInvalid(err) || apierrors.IsMethodNotSupported(err)
+}
+
+// isExpired tells whether the resource version of a watch is too old to resume from, which the API server answers
+// with 410 Gone.
+func isExpired(err error) bool {
+	return apierrors.IsResourceExpired(err) || apierrors.IsGone(err)
+}
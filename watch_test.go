@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"log"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// lineWriter hands each complete line written to it to the test, so that the test can wait for the output of a
+// watch while it is still running.
+type lineWriter struct {
+	mu      sync.Mutex
+	pending bytes.Buffer
+	lines   chan string
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pending.Write(p)
+	for {
+		line, err := w.pending.ReadString('\n')
+		if err != nil {
+			w.pending.WriteString(line)
+			return len(p), nil
+		}
+		w.lines <- strings.TrimSuffix(line, "\n")
+	}
+}
+
+// expectLine waits for a line containing want, skipping the lines before it.
+func expectLine(t *testing.T, lines <-chan string, want string) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case line := <-lines:
+			if strings.Contains(line, want) {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("No line containing %q was written.", want)
+		}
+	}
+}
+
+func newRunningPod(namespace string, name string) *v1.Pod {
+	pod := newPod(namespace, name)
+	pod.Spec.Containers = []v1.Container{{Name: "web"}}
+	pod.Status.Phase = v1.PodRunning
+	pod.Status.ContainerStatuses = []v1.ContainerStatus{{
+		Name:  "web",
+		Ready: true,
+		State: v1.ContainerState{Running: &v1.ContainerStateRunning{}},
+	}}
+	return pod
+}
+
+func TestWatchPods(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	pending := newPod("default", "web-0")
+	pending.Status.Phase = v1.PodPending
+	clientset, server := newFakeClientset(t, newNamespace("default"), pending)
+	out := &lineWriter{lines: make(chan string, 100)}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- watchPods(ctx, clientset, "default", metav1.ListOptions{}, out, "")
+	}()
+
+	expectLine(t, out.lines, "web-0")
+	server.waitForWatches("pods", 1)
+	server.seed(newRunningPod("default", "web-0"))
+	expectLine(t, out.lines, "MODIFIED default/web-0: phase Pending -> Running, container web running, ready")
+
+	// A dropped watch resumes from the last resource version, without missing the changes in between.
+	server.endWatches("pods")
+	server.seed(newRunningPod("default", "web-1"))
+	expectLine(t, out.lines, "ADDED    default/web-1: Running, 1/1 ready")
+	for _, r := range server.requestsOf("WATCH", "pods") {
+		if url.Values(r.query).Get("resourceVersion") == "" {
+			t.Errorf("Query of a watch, got: %v, want a resource version to resume from.", r.query)
+		}
+	}
+
+	// A resource version too old to resume from makes it list the pods again.
+	server.failNext("WATCH", "pods", apierrors.NewResourceExpired("too old resource version"))
+	server.endWatches("pods")
+	server.waitForWatches("pods", 1)
+	server.remove("pods", "default", "web-0")
+	expectLine(t, out.lines, "DELETED  default/web-0: Running, 1/1 ready")
+	if got := len(server.requestsOf("GET", "pods")); got != 2 {
+		t.Errorf("Lists of pods, got: %d, want: %d.", got, 2)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Error of a watch ended by the user, got: %v, want: nil.", err)
+	}
+	if !strings.Contains(logs.String(), "listing the pods again") {
+		t.Errorf("Logs of the watch do not tell about the new list, got:\n%v", logs.String())
+	}
+}
+
+func TestWatchPodsRetriesTransientErrors(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	clientset, server := newFakeClientset(t, newNamespace("default"), newRunningPod("default", "web-0"))
+	out := &lineWriter{lines: make(chan string, 100)}
+	done := make(chan error, 1)
+	go func() {
+		done <- watchPods(context.TODO(), clientset, "default", metav1.ListOptions{}, out, "")
+	}()
+	expectLine(t, out.lines, "web-0")
+	server.waitForWatches("pods", 1)
+
+	// An overloaded API server is retried after a delay, resuming from where the watch was.
+	server.failNext("WATCH", "pods", apierrors.NewInternalError(errors.New("etcd leader changed")))
+	server.endWatches("pods")
+	server.waitForWatches("pods", 1)
+	server.seed(newRunningPod("default", "web-1"))
+	expectLine(t, out.lines, "ADDED    default/web-1: Running, 1/1 ready")
+	if !strings.Contains(logs.String(), "Watch of pods failed, retrying") {
+		t.Errorf("Logs of the watch do not tell about the retry, got:\n%v", logs.String())
+	}
+
+	// Missing permissions do not go away by retrying.
+	server.failNext("WATCH", "pods", apierrors.NewForbidden(schema.GroupResource{Resource: "pods"}, "",
+		errors.New("access revoked")))
+	server.endWatches("pods")
+	select {
+	case err := <-done:
+		if !apierrors.IsForbidden(err) {
+			t.Errorf("Error of a forbidden watch, got: %v, want: Forbidden.", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Watch did not end after it was forbidden.")
+	}
+}
+
+func TestPodChanges(t *testing.T) {
+	crashing := newRunningPod("default", "web-0")
+	crashing.Status.ContainerStatuses[0] = v1.ContainerStatus{
+		Name:         "web",
+		RestartCount: 1,
+		State:        v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+	}
+	crashing.Spec.NodeName = "node-1"
+	want := []string{"scheduled on node-1", "container web running, ready -> waiting: CrashLoopBackOff, 1 restart"}
+	if got := podChanges(newRunningPod("default", "web-0"), crashing); !reflect.DeepEqual(got, want) {
+		t.Errorf("Changes of a crashing pod, got: %v, want: %v.", got, want)
+	}
+	if got := podChanges(crashing, crashing.DeepCopy()); len(got) != 0 {
+		t.Errorf("Changes of an unchanged pod, got: %v, want: none.", got)
+	}
+}